@@ -0,0 +1,95 @@
+package typegen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// CBORAppender is implemented by types that can append their CBOR encoding
+// directly to a byte slice.
+type CBORAppender interface {
+	AppendCBOR([]byte) ([]byte, error)
+}
+
+// AppendMajorTypeHeader appends the header for a value of major type t with
+// extra value l to dst. It encodes the same bytes as WriteMajorTypeHeader.
+func AppendMajorTypeHeader(dst []byte, t byte, l uint64) []byte {
+	switch {
+	case l < 24:
+		return append(dst, (t<<5)|byte(l))
+	case l < (1 << 8):
+		return append(dst, (t<<5)|24, byte(l))
+	case l < (1 << 16):
+		var b [3]byte
+		b[0] = (t << 5) | 25
+		binary.BigEndian.PutUint16(b[1:3], uint16(l))
+		return append(dst, b[:]...)
+	case l < (1 << 32):
+		var b [5]byte
+		b[0] = (t << 5) | 26
+		binary.BigEndian.PutUint32(b[1:5], uint32(l))
+		return append(dst, b[:]...)
+	default:
+		var b [9]byte
+		b[0] = (t << 5) | 27
+		binary.BigEndian.PutUint64(b[1:], l)
+		return append(dst, b[:]...)
+	}
+}
+
+// AppendInt64 appends v to dst as a CBOR unsigned or negative integer.
+func AppendInt64(dst []byte, v int64) []byte {
+	if v >= 0 {
+		return AppendMajorTypeHeader(dst, MajUnsignedInt, uint64(v))
+	}
+	return AppendMajorTypeHeader(dst, MajNegativeInt, uint64(-v)-1)
+}
+
+// AppendString appends s to dst as a CBOR text string.
+func AppendString(dst []byte, s string) []byte {
+	dst = AppendMajorTypeHeader(dst, MajTextString, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// AppendByteString appends b to dst as a CBOR byte string.
+func AppendByteString(dst []byte, b []byte) []byte {
+	dst = AppendMajorTypeHeader(dst, MajByteString, uint64(len(b)))
+	return append(dst, b...)
+}
+
+// AppendBool appends b to dst as a CBOR boolean.
+func AppendBool(dst []byte, b bool) []byte {
+	return append(dst, EncodeBool(b)...)
+}
+
+// AppendCid appends c to dst as a tag 42 CID, like WriteCid.
+func AppendCid(dst []byte, c cid.Cid) ([]byte, error) {
+	dst = AppendMajorTypeHeader(dst, MajTag, 42)
+	if c == cid.Undef {
+		return nil, fmt.Errorf("undefined cid")
+	}
+
+	key := c.KeyString()
+	dst = AppendMajorTypeHeader(dst, MajByteString, uint64(len(key)+1))
+
+	// that binary multibase prefix...
+	dst = append(dst, 0)
+	return append(dst, key...), nil
+}
+
+// AppendMarshaler appends the CBOR encoding of v to dst. It uses v's
+// AppendCBOR method if it has one, and falls back to MarshalCBOR otherwise.
+func AppendMarshaler(dst []byte, v CBORMarshaler) ([]byte, error) {
+	if a, ok := v.(CBORAppender); ok {
+		return a.AppendCBOR(dst)
+	}
+
+	buf := bytes.NewBuffer(dst)
+	if err := v.MarshalCBOR(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,59 @@
+package typegen
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestAppendMajorTypeHeader(t *testing.T) {
+	for _, l := range []uint64{0, 23, 24, math.MaxUint8, math.MaxUint8 + 1, math.MaxUint16, math.MaxUint16 + 1, math.MaxUint32, math.MaxUint32 + 1, math.MaxUint64} {
+		for _, maj := range []byte{MajUnsignedInt, MajByteString, MajMap, MajOther} {
+			var buf bytes.Buffer
+			if err := WriteMajorTypeHeader(&buf, maj, l); err != nil {
+				t.Fatal(err)
+			}
+			out := AppendMajorTypeHeader([]byte{1, 2}, maj, l)
+			if !bytes.Equal(out[:2], []byte{1, 2}) || !bytes.Equal(out[2:], buf.Bytes()) {
+				t.Fatalf("header for major type %d, length %d: expected %x, got %x", maj, l, buf.Bytes(), out[2:])
+			}
+		}
+	}
+}
+
+func TestAppendInt64(t *testing.T) {
+	for _, v := range []int64{0, 1, -1, 24, -25, math.MaxInt64, math.MinInt64} {
+		var buf bytes.Buffer
+		if err := CborInt(v).MarshalCBOR(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if out := AppendInt64(nil, v); !bytes.Equal(out, buf.Bytes()) {
+			t.Fatalf("%d: expected %x, got %x", v, buf.Bytes(), out)
+		}
+	}
+}
+
+func TestAppendCid(t *testing.T) {
+	c, err := cid.Parse("bafy2bzacecnamqgqmifpluoeldx7zzglxcljo6oja4vrmtj7432rphldpdmm2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCid(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	out, err := AppendCid(nil, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, buf.Bytes()) {
+		t.Fatalf("expected %x, got %x", buf.Bytes(), out)
+	}
+
+	if _, err := AppendCid(nil, cid.Undef); err == nil {
+		t.Fatal("expected an error appending an undefined cid")
+	}
+}
@@ -0,0 +1,80 @@
+package typegen
+
+import (
+	"bufio"
+	"io"
+)
+
+// sliceReader is a BytePeeker over a byte slice that can also hand out
+// sub-slices of its input without copying.
+type sliceReader struct {
+	buf []byte
+	off int
+
+	// end is where reading stops, and endErr what is returned for reads past
+	// it instead of io.EOF, if it's before the end of buf.
+	end    int
+	endErr error
+}
+
+var _ BytePeeker = (*sliceReader)(nil)
+
+func (sr *sliceReader) Read(p []byte) (int, error) {
+	if sr.off >= sr.end {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, sr.eof()
+	}
+	n := copy(p, sr.buf[sr.off:sr.end])
+	sr.off += n
+	return n, nil
+}
+
+func (sr *sliceReader) ReadByte() (byte, error) {
+	if sr.off >= sr.end {
+		return 0, sr.eof()
+	}
+	b := sr.buf[sr.off]
+	sr.off++
+	return b, nil
+}
+
+func (sr *sliceReader) UnreadByte() error {
+	if sr.off == 0 {
+		return bufio.ErrInvalidUnreadByte
+	}
+	sr.off--
+	return nil
+}
+
+// next returns the next n bytes of the input, without copying them. It
+// follows the io.ReadFull conventions for short input.
+func (sr *sliceReader) next(n uint64) ([]byte, error) {
+	remaining := uint64(sr.end - sr.off)
+	if n > remaining {
+		sr.off = sr.end
+		if sr.endErr != nil {
+			return nil, sr.endErr
+		}
+		if remaining == 0 {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := sr.buf[sr.off : sr.off+int(n) : sr.off+int(n)]
+	sr.off += int(n)
+	return b, nil
+}
+
+func (sr *sliceReader) eof() error {
+	if sr.endErr != nil {
+		return sr.endErr
+	}
+	return io.EOF
+}
+
+func (sr *sliceReader) discard(n int) error {
+	_, err := sr.next(uint64(n))
+	return err
+}
@@ -0,0 +1,185 @@
+package typegen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// MapKeyOrder is the order Canonicalize sorts map keys in. Keys are compared
+// by their canonical encoding.
+type MapKeyOrder int
+
+const (
+	// LengthFirstOrder sorts shorter keys first, and keys of the same length
+	// bytewise, as RFC 7049 section 3.9 and DAG-CBOR do.
+	LengthFirstOrder MapKeyOrder = iota
+
+	// BytewiseOrder sorts keys bytewise, as RFC 8949 section 4.2.1 does.
+	BytewiseOrder
+)
+
+// Canonicalize re-encodes the single CBOR value in as canonical CBOR, with
+// map keys in LengthFirstOrder. See CanonicalizeOrder.
+func Canonicalize(in []byte) ([]byte, error) {
+	return CanonicalizeOrder(in, LengthFirstOrder)
+}
+
+// CanonicalizeOrder re-encodes the single CBOR value in so that values that
+// are the same are encoded the same, whatever encoded them:
+//
+//   - headers use the shortest encoding of their argument
+//   - strings, arrays and maps are of definite length, and the chunks of
+//     strings are joined
+//   - map keys are sorted in order, and must not repeat
+//   - floats are in the shortest of 16, 32 and 64 bits that holds their value
+//     exactly, and NaNs are all encoded as 0xf97e00
+//
+// Other values, such as tags, are kept as they are. Strings may be no longer
+// than ByteArrayMaxLen, arrays and maps have no more than MaxLength items, and
+// they and tags may be nested no deeper than MaxNesting.
+func CanonicalizeOrder(in []byte, order MapKeyOrder) ([]byte, error) {
+	// Rewrite the headers first, leaving only the floats and the map keys
+	// to be dealt with.
+	cr := NewCborReaderBytesWithOptions(in, DecodeOptions{
+		MaxDepth:        MaxNesting,
+		AllowIndefinite: true,
+		AllowNonMinimal: true,
+	})
+	norm := bytes.NewBuffer(make([]byte, 0, len(in)))
+	if err := normalizeValue(cr, norm, cr.opts); err != nil {
+		return nil, err
+	}
+	if rest := cr.Remaining(); len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected %d unread bytes", len(rest))
+	}
+
+	c := &canonicalizer{
+		r:     bytes.NewReader(norm.Bytes()),
+		order: order,
+	}
+	c.scratch = make([]byte, maxHeaderSize)
+
+	var buf bytes.Buffer
+	buf.Grow(norm.Len())
+	if err := c.value(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalizer reads a value normalized by normalizeValue and writes it
+// canonically.
+type canonicalizer struct {
+	r       *bytes.Reader
+	scratch []byte
+	order   MapKeyOrder
+}
+
+// mapEntry is the canonical encoding of a map key and its value.
+type mapEntry struct {
+	key, value []byte
+}
+
+func (c *canonicalizer) value(w *bytes.Buffer) error {
+	maj, low, extra, err := readHeaderAnyLength(c.r, c.scratch)
+	if err != nil {
+		return err
+	}
+
+	switch maj {
+	case MajUnsignedInt, MajNegativeInt:
+		return WriteMajorTypeHeaderBuf(c.scratch, w, maj, extra)
+	case MajByteString, MajTextString:
+		if err := WriteMajorTypeHeaderBuf(c.scratch, w, maj, extra); err != nil {
+			return err
+		}
+		_, err := io.CopyN(w, c.r, int64(extra))
+		return err
+	case MajArray:
+		if err := WriteMajorTypeHeaderBuf(c.scratch, w, maj, extra); err != nil {
+			return err
+		}
+		for i := uint64(0); i < extra; i++ {
+			if err := c.value(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case MajMap:
+		return c.mapValue(w, extra)
+	case MajTag:
+		if err := WriteMajorTypeHeaderBuf(c.scratch, w, maj, extra); err != nil {
+			return err
+		}
+		return c.value(w)
+	default:
+		switch low {
+		case 25:
+			writeShortestFloat(w, halfToFloat64(uint16(extra)))
+		case 26:
+			writeShortestFloat(w, float64(math.Float32frombits(uint32(extra))))
+		case 27:
+			writeShortestFloat(w, math.Float64frombits(extra))
+		default:
+			return WriteMajorTypeHeaderBuf(c.scratch, w, maj, extra)
+		}
+		return nil
+	}
+}
+
+func (c *canonicalizer) mapValue(w *bytes.Buffer, n uint64) error {
+	// The entries are written to one buffer, and then copied to w in order.
+	var buf bytes.Buffer
+	ends := make([]int, 0, 2*n)
+	for i := uint64(0); i < 2*n; i++ {
+		if err := c.value(&buf); err != nil {
+			return err
+		}
+		ends = append(ends, buf.Len())
+	}
+	b := buf.Bytes()
+	entries := make([]mapEntry, n)
+	start := 0
+	for i := range entries {
+		entries[i] = mapEntry{key: b[start:ends[2*i]], value: b[ends[2*i]:ends[2*i+1]]}
+		start = ends[2*i+1]
+	}
+
+	less := func(a, b []byte) bool {
+		if c.order == LengthFirstOrder && len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return bytes.Compare(a, b) < 0
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i].key, entries[j].key)
+	})
+
+	if err := WriteMajorTypeHeaderBuf(c.scratch, w, MajMap, n); err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if i > 0 && bytes.Equal(e.key, entries[i-1].key) {
+			return fmt.Errorf("duplicate map key %x", e.key)
+		}
+		w.Write(e.key)
+		w.Write(e.value)
+	}
+	return nil
+}
+
+// writeShortestFloat writes f in the fewest bits that hold it exactly.
+func writeShortestFloat(w *bytes.Buffer, f float64) {
+	if h, ok := float64ToHalf(f); ok {
+		writeHeaderLow(w, MajOther, 25, uint64(h))
+		return
+	}
+	if f32 := float32(f); float64(f32) == f {
+		writeHeaderLow(w, MajOther, 26, uint64(math.Float32bits(f32)))
+		return
+	}
+	writeHeaderLow(w, MajOther, 27, math.Float64bits(f))
+}
@@ -0,0 +1,87 @@
+package typegen
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	for _, tc := range []struct {
+		in, out, bytewise string
+	}{
+		{`1_3`, `1`, ``},
+		{`[_ -1_1, (_ "a", "b"), h''_2, 1_0(2)]`, `[-1, "ab", h'', 1(2)]`, ``},
+		{`{"bb": 1, "a": 2, 10: 3}`, `{10: 3, "a": 2, "bb": 1}`, ``},
+		{`{"aa": 1, "b": 2}`, `{"b": 2, "aa": 1}`, ``},
+		{`{"a": 1, h'00': 2}`, `{h'00': 2, "a": 1}`, ``},
+		{`{_ 100: 1, -1: 2}`, `{-1: 2, 100: 1}`, `{100: 1, -1: 2}`},
+		{`{[2]: {"b": 1, "a": 2}, [1]: 0}`, `{[1]: 0, [2]: {"a": 2, "b": 1}}`, ``},
+		{`[1.5_3, 100000.0_3, 1.1_3, 0.0_2, -Infinity_3, NaN_3]`, `[1.5_1, 100000.0_2, 1.1_3, 0.0_1, -Infinity_1, NaN_1]`, ``},
+		{`[true, null, simple(255)]`, `[true, null, simple(255)]`, ``},
+	} {
+		if tc.bytewise == "" {
+			tc.bytewise = tc.out
+		}
+		for _, c := range []struct {
+			order MapKeyOrder
+			out   string
+		}{
+			{LengthFirstOrder, tc.out},
+			{BytewiseOrder, tc.bytewise},
+		} {
+			got, err := CanonicalizeOrder(MustParseDiagnostic(tc.in), c.order)
+			if err != nil {
+				t.Errorf("%s: %s", tc.in, err)
+				continue
+			}
+			if want := MustParseDiagnostic(c.out); !bytes.Equal(got, want) {
+				t.Errorf("%s in order %d: expected %x, got %x", tc.in, c.order, MustParseDiagnostic(c.out), got)
+			}
+		}
+	}
+
+	got, err := Canonicalize(MustParseDiagnostic(`{"bb": 1, "a": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := MustParseDiagnostic(`{"a": 2, "bb": 1}`); !bytes.Equal(got, want) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+
+	for _, tc := range []struct {
+		in  []byte
+		err error
+	}{
+		{nil, io.EOF},
+		{[]byte{0x82, 0x01}, io.ErrUnexpectedEOF},
+		{[]byte{0x9f, 0x01}, io.ErrUnexpectedEOF},
+		{[]byte{0x5f, 0x41}, io.ErrUnexpectedEOF},
+		{MustParseDiagnostic(`{"a": 1, "a"_0: 2}`), nil},
+		{[]byte{0x5f, 0x61, 0x61, 0xff}, nil},
+		{[]byte{0xff}, nil},
+		{[]byte{0x01, 0x02}, nil},
+	} {
+		if _, err := Canonicalize(tc.in); err == nil || (tc.err != nil && err != tc.err) {
+			t.Errorf("%x: expected %v, got %v", tc.in, tc.err, err)
+		}
+	}
+
+	// Nesting is limited, rather than overflowing the stack.
+	deep := append(bytes.Repeat([]byte{0x9f}, MaxNesting), bytes.Repeat([]byte{0xff}, MaxNesting)...)
+	got, err = Canonicalize(deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(bytes.Repeat([]byte{0x81}, MaxNesting-1), 0x80); !bytes.Equal(got, want) {
+		t.Fatal("deeply nested arrays canonicalized wrong")
+	}
+	for _, in := range [][]byte{
+		append(bytes.Repeat([]byte{0x81}, MaxNesting+1), 0x01),
+		append(bytes.Repeat([]byte{0xc1}, 1000000), 0x01),
+	} {
+		if _, err := Canonicalize(in); err != ErrDecodeTooDeep {
+			t.Errorf("expected ErrDecodeTooDeep, got %v", err)
+		}
+	}
+}
@@ -12,6 +12,28 @@ func (c CborCid) MarshalCBOR(w io.Writer) error {
 	return WriteCid(w, cid.Cid(c))
 }
 
+func (c CborCid) CBORSize() int {
+	return CidSize(cid.Cid(c))
+}
+
+func (c CborCid) AppendCBOR(b []byte) ([]byte, error) {
+	return AppendCid(b, cid.Cid(c))
+}
+
+func (c CborCid) ForEachLink(fn func(cid.Cid) error) error {
+	if !cid.Cid(c).Defined() {
+		return nil
+	}
+	return fn(cid.Cid(c))
+}
+
+func (c CborCid) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if !cid.Cid(c).Defined() {
+		return nil
+	}
+	return fn(prefix, cid.Cid(c))
+}
+
 func (c *CborCid) UnmarshalCBOR(r io.Reader) error {
 	oc, err := ReadCid(r)
 	if err != nil {
@@ -0,0 +1,192 @@
+package typegen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// DagCBORError is returned by ValidateDagCBOR for input that isn't valid
+// DAG-CBOR.
+type DagCBORError struct {
+	// Offset is the byte offset in the input of the item breaking the rules.
+	Offset int
+	Err    error
+}
+
+func (e *DagCBORError) Error() string {
+	return fmt.Sprintf("invalid DAG-CBOR at byte %d: %s", e.Offset, e.Err)
+}
+
+func (e *DagCBORError) Unwrap() error {
+	return e.Err
+}
+
+// dagFrame is an array or map being validated.
+type dagFrame struct {
+	isMap     bool
+	remaining uint64
+
+	// prevKey is the encoding of the previous key of a map.
+	prevKey []byte
+}
+
+// ValidateDagCBOR validates that b is a single CBOR value, as ValidateCBOR
+// does, that also follows the rules of DAG-CBOR:
+//
+//   - headers use the shortest encoding of their argument, and strings,
+//     arrays and maps are of definite length
+//   - text strings are valid UTF-8
+//   - map keys are text strings, sorted by length and then bytewise, with no
+//     duplicates
+//   - the only tag is 42, which holds a byte string of a CID preceded by 0x00
+//   - floats are 64-bit, and neither NaN nor infinite
+//   - the only simple values are false, true and null
+//
+// It returns a DagCBORError giving the offset of the first violation.
+func ValidateDagCBOR(b []byte) error {
+	br := bytes.NewReader(b)
+	scratch := make([]byte, maxHeaderSize)
+
+	var start int
+	fail := func(err error) error {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return &DagCBORError{Offset: start, Err: err}
+	}
+	readHeader := func() (byte, byte, uint64, error) {
+		start = len(b) - br.Len()
+		maj, low, extra, err := readHeaderAnyLength(br, scratch)
+		if err != nil {
+			return 0, 0, 0, fail(err)
+		}
+		switch {
+		case low == 31:
+			return 0, 0, 0, fail(fmt.Errorf("indefinite length item"))
+		case maj == MajOther && low == 24 && extra < 32:
+			return 0, 0, 0, fail(fmt.Errorf("invalid simple value %d in two bytes", extra))
+		case maj != MajOther && !headerIsShortest(low, extra):
+			return 0, 0, 0, fail(fmt.Errorf("%w (argument %d not in its shortest encoding)", ErrNonCanonical, extra))
+		}
+		return maj, low, extra, nil
+	}
+	readString := func(extra uint64) ([]byte, error) {
+		if extra > ByteArrayMaxLen {
+			return nil, fail(&ErrTooLong{Length: extra, Limit: ByteArrayMaxLen})
+		}
+		if uint64(br.Len()) < extra {
+			return nil, fail(io.ErrUnexpectedEOF)
+		}
+		off := len(b) - br.Len()
+		s := b[off : off+int(extra)]
+		_, _ = br.Seek(int64(extra), io.SeekCurrent)
+		return s, nil
+	}
+
+	stack := []dagFrame{{remaining: 1}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.remaining == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		isKey := top.isMap && top.remaining%2 == 0
+		top.remaining--
+
+		maj, low, extra, err := readHeader()
+		if err != nil {
+			return err
+		}
+
+		if isKey {
+			if maj != MajTextString {
+				return fail(fmt.Errorf("map key of type '%s', not a text string", majorTypeName(maj)))
+			}
+			key, err := readString(extra)
+			if err != nil {
+				return err
+			}
+			if !utf8.Valid(key) {
+				return fail(fmt.Errorf("map key is not valid UTF-8"))
+			}
+			key = b[start : len(b)-br.Len()]
+			if top.prevKey != nil {
+				switch {
+				case bytes.Equal(key, top.prevKey):
+					return fail(fmt.Errorf("duplicate map key %q", key[len(key)-int(extra):]))
+				case !canonicalLess(string(top.prevKey), string(key)):
+					return fail(fmt.Errorf("map key %q out of order", key[len(key)-int(extra):]))
+				}
+			}
+			top.prevKey = key
+			continue
+		}
+
+		switch maj {
+		case MajUnsignedInt, MajNegativeInt:
+		case MajByteString, MajTextString:
+			s, err := readString(extra)
+			if err != nil {
+				return err
+			}
+			if maj == MajTextString && !utf8.Valid(s) {
+				return fail(fmt.Errorf("text string is not valid UTF-8"))
+			}
+		case MajArray, MajMap:
+			if extra > MaxLength {
+				return fail(&ErrTooLong{Length: extra, Limit: MaxLength})
+			}
+			if maj == MajMap {
+				stack = append(stack, dagFrame{isMap: true, remaining: extra * 2})
+			} else {
+				stack = append(stack, dagFrame{remaining: extra})
+			}
+		case MajTag:
+			if extra != 42 {
+				return fail(fmt.Errorf("tag %d is not allowed, only 42", extra))
+			}
+			maj, _, extra, err := readHeader()
+			if err != nil {
+				return err
+			}
+			if maj != MajByteString {
+				return fail(&ErrWrongMajorType{Want: MajByteString, Got: maj})
+			}
+			s, err := readString(extra)
+			if err != nil {
+				return err
+			}
+			if len(s) == 0 || s[0] != 0 {
+				return fail(fmt.Errorf("CID is missing its 0x00 prefix"))
+			}
+			if _, err := cid.Cast(s[1:]); err != nil {
+				return fail(fmt.Errorf("invalid CID: %w", err))
+			}
+		default:
+			switch {
+			case low == 25 || low == 26:
+				return fail(fmt.Errorf("float is not 64-bit"))
+			case low == 27:
+				f := math.Float64frombits(extra)
+				if math.IsNaN(f) || math.IsInf(f, 0) {
+					return fail(fmt.Errorf("float %v is not allowed", f))
+				}
+			case extra == SimpleUndefined:
+				return fail(fmt.Errorf("undefined is not allowed"))
+			case extra != SimpleFalse && extra != SimpleTrue && extra != SimpleNull:
+				return fail(fmt.Errorf("simple value %d is not allowed", extra))
+			}
+		}
+	}
+
+	if br.Len() > 0 {
+		start = len(b) - br.Len()
+		return fail(fmt.Errorf("unexpected %d unread bytes", br.Len()))
+	}
+	return nil
+}
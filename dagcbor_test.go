@@ -0,0 +1,56 @@
+package typegen
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestValidateDagCBOR(t *testing.T) {
+	for _, in := range []string{
+		`0`,
+		`[-1, h'', "", true, false, null, 1.5, -0.0, [], {}]`,
+		`{"a": 1, "b": {"aa": [2]}, "ab": 3}`,
+		`42(cid'bafkqaaa')`,
+		`{"z": 42(cid'bafkqaaa'), "aa": 1}`,
+	} {
+		if err := ValidateDagCBOR(MustParseDiagnostic(in)); err != nil {
+			t.Errorf("%s: %s", in, err)
+		}
+	}
+
+	d := MustParseDiagnostic
+	for _, tc := range []struct {
+		in     []byte
+		offset int
+		err    error
+	}{
+		{d(`[1, 2_0]`), 2, ErrNonCanonical},
+		{d(`[1, [_ 2]]`), 2, nil},
+		{d(`(_ "a")`), 0, nil},
+		{d(`{1: 2}`), 1, nil},
+		{d(`{"b": 1, "a": 2}`), 4, nil},
+		{d(`{"aa": 1, "b": 2}`), 5, nil},
+		{d(`{"a": 1, "a": 2}`), 4, nil},
+		{d(`[1(2)]`), 1, nil},
+		{d(`42(h'0155000000')`), 2, nil},
+		{d(`42(h'000155')`), 2, nil},
+		{d(`42("a")`), 2, nil},
+		{d(`[1.5_2]`), 1, nil},
+		{d(`[1.5_1]`), 1, nil},
+		{d(`[NaN]`), 1, nil},
+		{d(`[-Infinity]`), 1, nil},
+		{d(`[undefined]`), 1, nil},
+		{d(`[simple(16)]`), 1, nil},
+		{d(`[h'01', "\xff"]`), 3, nil},
+		{d(`[1, 2]`)[:2], 2, io.ErrUnexpectedEOF},
+		{d(`[1, "ab"]`)[:4], 2, io.ErrUnexpectedEOF},
+		{append(d(`[1, 2]`), d(`3`)...), 3, nil},
+	} {
+		err := ValidateDagCBOR(tc.in)
+		var dagErr *DagCBORError
+		if !errors.As(err, &dagErr) || dagErr.Offset != tc.offset || (tc.err != nil && !errors.Is(err, tc.err)) {
+			t.Errorf("%x: expected an error at byte %d, got %v", tc.in, tc.offset, err)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package typegen
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// CBORToDagJSON reads one DAG-CBOR value from r and writes it to w as
+// DAG-JSON. Links are written as {"/": "<cid>"} and byte strings as
+// {"/": {"bytes": "<unpadded base64>"}}, and map keys are sorted bytewise.
+//
+// Values DAG-JSON can't hold are rejected: tags other than 42, map keys that
+// aren't strings, floats that are NaN or infinite, undefined, simple values,
+// and maps whose only key is "/", which would be read back as a link or
+// bytes. Arrays and maps may be nested no deeper than MaxNesting. It returns
+// io.EOF if r is empty.
+func CBORToDagJSON(r io.Reader, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	jw := &dagJSONWriter{r: GetPeeker(r), scratch: make([]byte, maxCidLength)}
+	if err := jw.value(bw, 0); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+type dagJSONWriter struct {
+	r       BytePeeker
+	scratch []byte
+}
+
+// value converts the next value, which is nested depth levels deep.
+func (jw *dagJSONWriter) value(w *bufio.Writer, depth int) error {
+	// Links are read whole by ReadCid, so tag 42, which DAG-CBOR encodes as
+	// 0xd82a, is left unread.
+	if b, err := jw.r.ReadByte(); err == nil {
+		if err := jw.r.UnreadByte(); err != nil {
+			return err
+		}
+		if b == 0xd8 {
+			return jw.link(w)
+		}
+	}
+
+	maj, low, extra, err := jw.header()
+	if err == io.EOF && depth > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	switch maj {
+	case MajUnsignedInt:
+		w.WriteString(strconv.FormatUint(extra, 10))
+	case MajNegativeInt:
+		n := new(big.Int).SetUint64(extra)
+		w.WriteString(n.Add(n, big.NewInt(1)).Neg(n).String())
+	case MajByteString:
+		b, err := jw.readString(extra)
+		if err != nil {
+			return err
+		}
+		w.WriteString(`{"/":{"bytes":"`)
+		w.WriteString(base64.RawStdEncoding.EncodeToString(b))
+		w.WriteString(`"}}`)
+	case MajTextString:
+		b, err := jw.readString(extra)
+		if err != nil {
+			return err
+		}
+		return writeJSONString(w, b)
+	case MajArray:
+		if extra > MaxLength {
+			return &ErrTooLong{Length: extra, Limit: MaxLength}
+		}
+		if depth >= MaxNesting {
+			return ErrDecodeTooDeep
+		}
+		w.WriteByte('[')
+		for i := uint64(0); i < extra; i++ {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := jw.value(w, depth+1); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	case MajMap:
+		return jw.mapValue(w, extra, depth)
+	case MajTag:
+		return fmt.Errorf("dag-json: cannot represent tag %d", extra)
+	case MajOther:
+		var f float64
+		switch {
+		case low == 20:
+			w.WriteString("false")
+			return nil
+		case low == 21:
+			w.WriteString("true")
+			return nil
+		case low == 22:
+			w.WriteString("null")
+			return nil
+		case low == 25:
+			f = halfToFloat64(uint16(extra))
+		case low == 26:
+			f = float64(math.Float32frombits(uint32(extra)))
+		case low == 27:
+			f = math.Float64frombits(extra)
+		default:
+			return fmt.Errorf("dag-json: cannot represent simple value %d", extra)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("dag-json: cannot represent %v", f)
+		}
+		w.WriteString(formatDiagFloat(f))
+	}
+	return nil
+}
+
+// link converts a link, or rejects a tag other than 42 with a one byte
+// argument.
+func (jw *dagJSONWriter) link(w *bufio.Writer) error {
+	c, err := ReadCid(jw.r)
+	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		var tagErr *ErrUnexpectedTag
+		if errors.As(err, &tagErr) {
+			return fmt.Errorf("dag-json: cannot represent tag %d", tagErr.Got)
+		}
+		return err
+	}
+	w.WriteString(`{"/":"`)
+	w.WriteString(c.String())
+	w.WriteString(`"}`)
+	return nil
+}
+
+// header reads the next header, which must encode its argument in the fewest
+// bytes unless it is a float.
+func (jw *dagJSONWriter) header() (maj byte, low byte, extra uint64, err error) {
+	maj, low, extra, err = readHeaderAnyLength(jw.r, jw.scratch)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if low == 31 {
+		return 0, 0, 0, fmt.Errorf("dag-json: cannot convert indefinite length values")
+	}
+	if maj != MajOther && !headerIsShortest(low, extra) {
+		return 0, 0, 0, fmt.Errorf("%w (argument %d not in its shortest encoding)", ErrNonCanonical, extra)
+	}
+	return maj, low, extra, nil
+}
+
+// mapValue converts a map with n entries. DAG-JSON orders keys bytewise
+// rather than length first, so each value is converted to a buffer of its own
+// before the entries are sorted and written.
+func (jw *dagJSONWriter) mapValue(w *bufio.Writer, n uint64, depth int) error {
+	if n > MaxLength {
+		return &ErrTooLong{Length: n, Limit: MaxLength}
+	}
+	if depth >= MaxNesting {
+		return ErrDecodeTooDeep
+	}
+
+	type entry struct {
+		key string
+		val bytes.Buffer
+	}
+	entries := make([]entry, n)
+	for i := range entries {
+		maj, _, extra, err := jw.header()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if maj != MajTextString {
+			return fmt.Errorf("dag-json: cannot represent map key of major type %d", maj)
+		}
+		key, err := jw.readString(extra)
+		if err != nil {
+			return err
+		}
+		entries[i].key = string(key)
+
+		vw := bufio.NewWriter(&entries[i].val)
+		if err := jw.value(vw, depth+1); err != nil {
+			return err
+		}
+		if err := vw.Flush(); err != nil {
+			return err
+		}
+	}
+	if n == 1 && entries[0].key == "/" {
+		return fmt.Errorf(`dag-json: cannot represent map with the single key "/"`)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	w.WriteByte('{')
+	for i := range entries {
+		if i > 0 {
+			if entries[i].key == entries[i-1].key {
+				return fmt.Errorf("dag-json: duplicate map key %q", entries[i].key)
+			}
+			w.WriteByte(',')
+		}
+		if err := writeJSONString(w, []byte(entries[i].key)); err != nil {
+			return err
+		}
+		w.WriteByte(':')
+		w.Write(entries[i].val.Bytes())
+	}
+	w.WriteByte('}')
+	return nil
+}
+
+func (jw *dagJSONWriter) readString(n uint64) ([]byte, error) {
+	if n > ByteArrayMaxLen {
+		return nil, &ErrTooLong{Length: n, Limit: ByteArrayMaxLen}
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(jw.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// writeJSONString writes b, which must be valid UTF-8, as a JSON string,
+// escaping only what JSON requires.
+func writeJSONString(w *bufio.Writer, b []byte) error {
+	if !utf8.Valid(b) {
+		return fmt.Errorf("dag-json: string is not valid UTF-8")
+	}
+	const hexDigits = "0123456789abcdef"
+	w.WriteByte('"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			w.WriteByte('\\')
+			w.WriteByte(c)
+		case c == '\n':
+			w.WriteString(`\n`)
+		case c == '\r':
+			w.WriteString(`\r`)
+		case c == '\t':
+			w.WriteString(`\t`)
+		case c < 0x20:
+			w.WriteString(`\u00`)
+			w.WriteByte(hexDigits[c>>4])
+			w.WriteByte(hexDigits[c&0xf])
+		default:
+			w.WriteByte(c)
+		}
+	}
+	w.WriteByte('"')
+	return nil
+}
+
+// DagJSONToCBOR reads one DAG-JSON value from r and writes it to w as
+// canonical DAG-CBOR, with map keys in length first order and numbers with a
+// fraction or exponent as 64-bit floats. {"/": "<cid>"} is read as a link and
+// {"/": {"bytes": "<base64>"}} as a byte string. Duplicate map keys, and
+// anything but whitespace after the value, are errors. It returns io.EOF if r
+// is empty.
+//
+// The value is converted as it is read, holding only the entries of the map
+// being read at a time to sort them. Strings may be no longer than
+// ByteArrayMaxLen, arrays and maps have no more than MaxLength items, and
+// they may be nested no deeper than MaxNesting.
+func DagJSONToCBOR(r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	jr := &dagJSONReader{dec: dec}
+
+	// Arrays are written with indefinite length, as their length isn't
+	// known until their end, and rewritten to definite length after. Floats
+	// are 64-bit, whatever their value, so are let through as they are.
+	var buf bytes.Buffer
+	if err := jr.value(NewCborWriter(&buf), 0); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("dag-json: unexpected data after value")
+		}
+		return err
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, buf.Len()))
+	if err := normalizeValue(&buf, out, DecodeOptions{AllowIndefinite: true, AllowNonMinimal: true}); err != nil {
+		return err
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
+
+type dagJSONReader struct {
+	dec *json.Decoder
+}
+
+// token reads the next token, which must be there as the value read so far
+// is incomplete unless depth is 0.
+func (jr *dagJSONReader) token(depth int) (json.Token, error) {
+	tok, err := jr.dec.Token()
+	if err == io.EOF && depth > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return tok, err
+}
+
+// value converts the next value, which is nested depth levels deep.
+func (jr *dagJSONReader) value(cw *CborWriter, depth int) error {
+	tok, err := jr.token(depth)
+	if err != nil {
+		return err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		_, err := cw.Write(CborNull)
+		return err
+	case bool:
+		_, err := cw.Write(EncodeBool(tok))
+		return err
+	case string:
+		if err := cw.WriteMajorTypeHeader(MajTextString, uint64(len(tok))); err != nil {
+			return err
+		}
+		_, err := cw.WriteString(tok)
+		return err
+	case json.Number:
+		return writeDagJSONNumber(cw, tok)
+	case json.Delim:
+		if depth >= MaxNesting {
+			return ErrDecodeTooDeep
+		}
+		if tok == '{' {
+			return jr.mapValue(cw, depth)
+		}
+		if _, err := cw.Write([]byte{MajArray<<5 | 31}); err != nil {
+			return err
+		}
+		for jr.dec.More() {
+			if err := jr.value(cw, depth+1); err != nil {
+				return err
+			}
+		}
+		if _, err := jr.token(depth + 1); err != nil {
+			return err
+		}
+		_, err := cw.Write([]byte{0xff})
+		return err
+	default:
+		return fmt.Errorf("dag-json: unexpected token %v", tok)
+	}
+}
+
+// mapValue converts the rest of a map. Its values are converted to one
+// buffer, and written after their keys once the keys are sorted.
+func (jr *dagJSONReader) mapValue(cw *CborWriter, depth int) error {
+	type entry struct {
+		key        string
+		start, end int
+	}
+	var entries []entry
+	var buf bytes.Buffer
+	vw := NewCborWriter(&buf)
+	for jr.dec.More() {
+		if len(entries) == MaxLength {
+			return &ErrTooLong{Length: MaxLength + 1, Limit: MaxLength}
+		}
+		tok, err := jr.token(depth + 1)
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("dag-json: unexpected map key %v", tok)
+		}
+		start := buf.Len()
+		if err := jr.value(vw, depth+1); err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: key, start: start, end: buf.Len()})
+	}
+	if _, err := jr.token(depth + 1); err != nil {
+		return err
+	}
+
+	if len(entries) == 1 && entries[0].key == "/" {
+		return writeDagJSONSlash(cw, buf.Bytes())
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return canonicalLess(entries[i].key, entries[j].key)
+	})
+	if err := cw.WriteMajorTypeHeader(MajMap, uint64(len(entries))); err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if i > 0 && e.key == entries[i-1].key {
+			return fmt.Errorf("dag-json: duplicate map key %q", e.key)
+		}
+		if err := cw.WriteMajorTypeHeader(MajTextString, uint64(len(e.key))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(e.key); err != nil {
+			return err
+		}
+		if _, err := cw.Write(buf.Bytes()[e.start:e.end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDagJSONSlash writes the value of a map whose only key is "/", which
+// must be a link or bytes. v is the value as converted to CBOR.
+func writeDagJSONSlash(cw *CborWriter, v []byte) error {
+	cr := NewCborReaderBytes(v)
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	switch {
+	case maj == MajTextString:
+		s := string(cr.Remaining())
+		c, err := cid.Decode(s)
+		if err != nil {
+			return fmt.Errorf("dag-json: invalid link %q: %w", s, err)
+		}
+		return WriteCid(cw, c)
+	case maj == MajMap && extra == 1:
+		key, err := ReadString(cr)
+		if err != nil || key != "bytes" {
+			break
+		}
+		maj, _, err := cr.ReadHeader()
+		if err != nil || maj != MajTextString {
+			break
+		}
+		b, err := base64.RawStdEncoding.DecodeString(trimBase64Padding(string(cr.Remaining())))
+		if err != nil {
+			return fmt.Errorf("dag-json: invalid bytes: %w", err)
+		}
+		if err := cw.WriteMajorTypeHeader(MajByteString, uint64(len(b))); err != nil {
+			return err
+		}
+		_, err = cw.Write(b)
+		return err
+	}
+	return fmt.Errorf(`dag-json: map with the single key "/" must be a link or bytes`)
+}
+
+func trimBase64Padding(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '=' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func writeDagJSONNumber(cw *CborWriter, num json.Number) error {
+	s := string(num)
+	if bytes.ContainsAny([]byte(s), ".eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("dag-json: invalid number %s: %w", s, err)
+		}
+		// DAG-CBOR floats are always 64 bits, even when shorter would do.
+		buf := [9]byte{MajOther<<5 | 27}
+		binary.BigEndian.PutUint64(buf[1:], math.Float64bits(f))
+		_, err = cw.Write(buf[:])
+		return err
+	}
+
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("dag-json: invalid number %s", s)
+	}
+	if n.Sign() >= 0 {
+		if !n.IsUint64() {
+			return fmt.Errorf("dag-json: integer %s out of range", s)
+		}
+		return cw.WriteMajorTypeHeader(MajUnsignedInt, n.Uint64())
+	}
+	n.Neg(n).Sub(n, big.NewInt(1))
+	if !n.IsUint64() {
+		return fmt.Errorf("dag-json: integer %s out of range", s)
+	}
+	return cw.WriteMajorTypeHeader(MajNegativeInt, n.Uint64())
+}
@@ -0,0 +1,148 @@
+package typegen
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDagJSONRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		diag string
+		json string
+	}{
+		{"0", "0"},
+		{"18446744073709551615", "18446744073709551615"},
+		{"-18446744073709551616", "-18446744073709551616"},
+		{"1.5", "1.5"},
+		{"1.0", "1.0"},
+		{"0.0", "0.0"},
+		{"1e+300", "1e+300"},
+		{`"a\"\\\n\u0001é"`, `"a\"\\\n\u0001é"`},
+		{"h'0102ff'", `{"/":{"bytes":"AQL/"}}`},
+		{"h''", `{"/":{"bytes":""}}`},
+		{"42(cid'bafkqaaa')", `{"/":"bafkqaaa"}`},
+		{"[1, [], {}]", "[1,[],{}]"},
+		{`{"a": [null, true, false], "b": 1, "aa": 2}`, `{"a":[null,true,false],"aa":2,"b":1}`},
+		{`{"/": 1, "a": 2}`, `{"/":1,"a":2}`},
+	} {
+		cbor := MustParseDiagnostic(tc.diag)
+
+		var js bytes.Buffer
+		if err := CBORToDagJSON(bytes.NewReader(cbor), &js); err != nil {
+			t.Errorf("%s: %s", tc.diag, err)
+			continue
+		}
+		if js.String() != tc.json {
+			t.Errorf("%s: expected %s, got %s", tc.diag, tc.json, js.String())
+		}
+
+		var back bytes.Buffer
+		if err := DagJSONToCBOR(strings.NewReader(tc.json), &back); err != nil {
+			t.Errorf("%s: %s", tc.json, err)
+			continue
+		}
+		if !bytes.Equal(back.Bytes(), cbor) {
+			t.Errorf("%s: expected %x, got %x", tc.json, cbor, back.Bytes())
+		}
+	}
+}
+
+func TestDagJSONToCBOR(t *testing.T) {
+	for _, tc := range []struct {
+		json string
+		diag string
+	}{
+		{` { "b" : 1 , "a" : 2 } `, `{"a": 2, "b": 1}`},
+		{`{"/":{"bytes":"AQL/"}}`, "h'0102ff'"},
+		{`{"/":{"bytes":"AQ=="}}`, "h'01'"},
+		{"1e2", "100.0_3"},
+		{"-1", "-1"},
+	} {
+		var out bytes.Buffer
+		if err := DagJSONToCBOR(strings.NewReader(tc.json), &out); err != nil {
+			t.Errorf("%s: %s", tc.json, err)
+			continue
+		}
+		if want := MustParseDiagnostic(tc.diag); !bytes.Equal(out.Bytes(), want) {
+			t.Errorf("%s: expected %x, got %x", tc.json, want, out.Bytes())
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"[1",
+		"1 2",
+		"18446744073709551616",
+		"-18446744073709551617",
+		`{"/":"nope"}`,
+		`{"/":{"bytes":"!"}}`,
+		`{"/":{"bytes":"AQ","x":1}}`,
+		`{"/":1}`,
+		`{"a":1,"a":2}`,
+		`{"a":1`,
+	} {
+		if err := DagJSONToCBOR(strings.NewReader(in), ioutil.Discard); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestCBORToDagJSONErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		err error
+	}{
+		{"", io.EOF},
+		{"82", io.ErrUnexpectedEOF},
+		{"a1", io.ErrUnexpectedEOF},
+		{"d82a", io.ErrUnexpectedEOF},
+		{"1801", nil},
+		{"9fff", nil},
+		{"c11a514b67b0", nil},
+		{"a10102", nil},
+		{"a1612f01", nil},
+		{"a2616101616102", nil},
+		{"f7", nil},
+		{"f97c00", nil},
+		{"fb7ff8000000000000", nil},
+		{"6180", nil},
+		{"d81801", nil},
+		{"d8", io.ErrUnexpectedEOF},
+	} {
+		in, err := hex.DecodeString(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CBORToDagJSON(bytes.NewReader(in), ioutil.Discard)
+		if err == nil || (tc.err != nil && err != tc.err) {
+			t.Errorf("%s: expected %v, got %v", tc.in, tc.err, err)
+		}
+	}
+}
+
+func TestDagJSONNesting(t *testing.T) {
+	deep := strings.Repeat("[", MaxNesting) + strings.Repeat("]", MaxNesting)
+	var out bytes.Buffer
+	if err := DagJSONToCBOR(strings.NewReader(deep), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := append(bytes.Repeat([]byte{0x81}, MaxNesting-1), 0x80); !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("expected %x, got %x", want, out.Bytes())
+	}
+	if err := CBORToDagJSON(bytes.NewReader(out.Bytes()), ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	tooDeep := strings.Repeat(`{"a":`, MaxNesting+1)
+	if err := DagJSONToCBOR(strings.NewReader(tooDeep), ioutil.Discard); err != ErrDecodeTooDeep {
+		t.Errorf("expected ErrDecodeTooDeep, got %v", err)
+	}
+	in := append(bytes.Repeat([]byte{0x81}, MaxNesting), 0x80)
+	if err := CBORToDagJSON(bytes.NewReader(in), ioutil.Discard); err != ErrDecodeTooDeep {
+		t.Errorf("expected ErrDecodeTooDeep, got %v", err)
+	}
+}
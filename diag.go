@@ -0,0 +1,412 @@
+package typegen
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+var errUnexpectedBreak = errors.New("diagnose: unexpected break in input")
+
+// DiagnoseOptions changes how Diagnose prints values.
+type DiagnoseOptions struct {
+	// Pretty puts each array element and map entry on its own line.
+	Pretty bool
+
+	// Indent is written once for each level of nesting when Pretty is set.
+	// If empty, two spaces are used.
+	Indent string
+
+	// Annotate marks values whose header doesn't use the shortest encoding
+	// of its argument with an encoding indicator, such as the _0 in 1_0 for
+	// 1 written in two bytes, as RFC 8949 section 8.1 describes. Floats are
+	// always marked with their width.
+	Annotate bool
+}
+
+// Diagnose reads one CBOR value from r and writes it to w in the diagnostic
+// notation of RFC 8949, section 8, on a single line. Tag 42 is written as
+// 42(cid'<cid>'), and bignums too large for an integer as plain numbers.
+// Arrays, maps and tags may be nested no deeper than MaxNesting. It returns
+// io.EOF if r is empty.
+func Diagnose(r io.Reader, w io.Writer) error {
+	return DiagnoseWithOptions(r, w, DiagnoseOptions{})
+}
+
+// DiagnoseWithOptions is Diagnose with options.
+func DiagnoseWithOptions(r io.Reader, w io.Writer, opts DiagnoseOptions) error {
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+
+	bw := bufio.NewWriter(w)
+	dp := &diagPrinter{r: GetPeeker(r), w: bw, opts: opts}
+	err := dp.nested(0)
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// errBreak is returned by diagPrinter.value when it reads the break that ends
+// an indefinite length value.
+var errBreak = errors.New("break")
+
+type diagPrinter struct {
+	r       BytePeeker
+	w       *bufio.Writer
+	opts    DiagnoseOptions
+	scratch [maxHeaderSize]byte
+}
+
+// indicator returns the encoding indicator for a header, if any.
+func (dp *diagPrinter) indicator(low byte, extra uint64) string {
+	if !dp.opts.Annotate || headerIsShortest(low, extra) {
+		return ""
+	}
+	return "_" + strconv.Itoa(int(low-24))
+}
+
+func (dp *diagPrinter) newline(depth int) {
+	if dp.opts.Pretty {
+		dp.w.WriteByte('\n')
+		dp.w.WriteString(strings.Repeat(dp.opts.Indent, depth))
+	}
+}
+
+// value prints the next value, which is nested depth levels deep. It
+// returns errBreak if the next byte is a break.
+func (dp *diagPrinter) value(depth int) error {
+	maj, low, extra, err := readHeaderAnyLength(dp.r, dp.scratch[:])
+	if err == io.EOF && depth > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	return dp.valueAfterHeader(maj, low, extra, depth)
+}
+
+// nested prints a value that can't be a break.
+func (dp *diagPrinter) nested(depth int) error {
+	err := dp.value(depth)
+	if err == errBreak {
+		return errUnexpectedBreak
+	}
+	return err
+}
+
+func (dp *diagPrinter) valueAfterHeader(maj, low byte, extra uint64, depth int) error {
+	switch maj {
+	case MajUnsignedInt:
+		dp.w.WriteString(strconv.FormatUint(extra, 10))
+		dp.w.WriteString(dp.indicator(low, extra))
+	case MajNegativeInt:
+		n := new(big.Int).SetUint64(extra)
+		dp.w.WriteString(n.Add(n, big.NewInt(1)).Neg(n).String())
+		dp.w.WriteString(dp.indicator(low, extra))
+	case MajByteString, MajTextString:
+		if low == 31 {
+			return dp.indefiniteString(maj)
+		}
+		b, err := dp.readString(extra)
+		if err != nil {
+			return err
+		}
+		dp.writeString(maj, b)
+		dp.w.WriteString(dp.indicator(low, extra))
+	case MajArray, MajMap:
+		if depth >= MaxNesting {
+			return ErrDecodeTooDeep
+		}
+		return dp.container(maj, low, extra, depth)
+	case MajTag:
+		if depth >= MaxNesting {
+			return ErrDecodeTooDeep
+		}
+		return dp.tag(low, extra, depth)
+	case MajOther:
+		return dp.other(low, extra)
+	}
+	return nil
+}
+
+// readString reads a string of length n, without trusting n for the size of
+// the buffer.
+func (dp *diagPrinter) readString(n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		n = math.MaxInt64
+	}
+	var buf bytes.Buffer
+	read, err := io.CopyN(&buf, dp.r, int64(n))
+	if err == io.EOF && read < int64(n) {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+func (dp *diagPrinter) writeString(maj byte, b []byte) {
+	if maj == MajByteString {
+		dp.w.WriteString("h'")
+		dp.w.WriteString(hex.EncodeToString(b))
+		dp.w.WriteString("'")
+		return
+	}
+	dp.w.WriteString(strconv.Quote(string(b)))
+}
+
+// indefiniteString prints the chunks of an indefinite length string of major
+// type maj, as (_ chunk, chunk), or as an empty string followed by _ if there
+// are none.
+func (dp *diagPrinter) indefiniteString(maj byte) error {
+	i := 0
+	for ; ; i++ {
+		cmaj, low, extra, err := readHeaderAnyLength(dp.r, dp.scratch[:])
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if cmaj == MajOther && low == 31 {
+			break
+		}
+		if cmaj != maj || low == 31 {
+			return fmt.Errorf("diagnose: invalid chunk of major type %d in indefinite length string of major type %d", cmaj, maj)
+		}
+		if i > 0 {
+			dp.w.WriteString(", ")
+		} else {
+			dp.w.WriteString("(_ ")
+		}
+		b, err := dp.readString(extra)
+		if err != nil {
+			return err
+		}
+		dp.writeString(maj, b)
+		dp.w.WriteString(dp.indicator(low, extra))
+	}
+
+	switch {
+	case i > 0:
+		dp.w.WriteString(")")
+	case maj == MajByteString:
+		dp.w.WriteString("''_")
+	default:
+		dp.w.WriteString(`""_`)
+	}
+	return nil
+}
+
+// container prints an array or a map with extra elements or entries, or an
+// indefinite number of them if low is 31.
+func (dp *diagPrinter) container(maj byte, low byte, extra uint64, depth int) error {
+	open, close := "[", "]"
+	if maj == MajMap {
+		open, close = "{", "}"
+	}
+	dp.w.WriteString(open)
+	if low == 31 {
+		dp.w.WriteString("_ ")
+	} else if ind := dp.indicator(low, extra); ind != "" {
+		dp.w.WriteString(ind + " ")
+	}
+
+	indefinite := low == 31
+	var i uint64
+	for ; indefinite || i < extra; i++ {
+		emaj, elow, eextra, err := readHeaderAnyLength(dp.r, dp.scratch[:])
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if emaj == MajOther && elow == 31 {
+			if !indefinite {
+				return errUnexpectedBreak
+			}
+			break
+		}
+
+		if i > 0 {
+			dp.w.WriteString(",")
+			if !dp.opts.Pretty {
+				dp.w.WriteString(" ")
+			}
+		}
+		dp.newline(depth + 1)
+
+		if err := dp.valueAfterHeader(emaj, elow, eextra, depth+1); err != nil {
+			return err
+		}
+		if maj == MajMap {
+			dp.w.WriteString(": ")
+			if err := dp.nested(depth + 1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if i > 0 {
+		dp.newline(depth)
+	}
+	dp.w.WriteString(close)
+	return nil
+}
+
+func (dp *diagPrinter) tag(low byte, tag uint64, depth int) error {
+	if (tag == 42 || tag == 2 || tag == 3) && headerIsShortest(low, tag) {
+		return dp.specialTag(tag, depth)
+	}
+
+	dp.w.WriteString(strconv.FormatUint(tag, 10))
+	dp.w.WriteString(dp.indicator(low, tag))
+	dp.w.WriteString("(")
+	if err := dp.nested(depth + 1); err != nil {
+		return err
+	}
+	dp.w.WriteString(")")
+	return nil
+}
+
+// specialTag prints a CID or a bignum, or the tagged value as is if it is not
+// a valid one.
+func (dp *diagPrinter) specialTag(tag uint64, depth int) error {
+	maj, low, extra, err := readHeaderAnyLength(dp.r, dp.scratch[:])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	prefix := strconv.FormatUint(tag, 10) + "("
+	if maj != MajByteString || low == 31 {
+		if maj == MajOther && low == 31 {
+			return errUnexpectedBreak
+		}
+		dp.w.WriteString(prefix)
+		if err := dp.valueAfterHeader(maj, low, extra, depth+1); err != nil {
+			return err
+		}
+		dp.w.WriteString(")")
+		return nil
+	}
+
+	b, err := dp.readString(extra)
+	if err != nil {
+		return err
+	}
+	shortest := headerIsShortest(low, extra)
+
+	switch tag {
+	case 42:
+		if len(b) > 0 && b[0] == 0 && shortest {
+			if c, err := cid.Cast(b[1:]); err == nil {
+				dp.w.WriteString(prefix + "cid'" + c.String() + "')")
+				return nil
+			}
+		}
+	case 2, 3:
+		// Smaller bignums, and those with leading zeros, would be read back
+		// as different encodings.
+		if len(b) > 8 && b[0] != 0 && shortest {
+			n := new(big.Int).SetBytes(b)
+			if tag == 3 {
+				n.Add(n, big.NewInt(1)).Neg(n)
+			}
+			dp.w.WriteString(n.String())
+			return nil
+		}
+	}
+
+	dp.w.WriteString(prefix)
+	dp.writeString(MajByteString, b)
+	dp.w.WriteString(dp.indicator(low, extra))
+	dp.w.WriteString(")")
+	return nil
+}
+
+func (dp *diagPrinter) other(low byte, extra uint64) error {
+	switch low {
+	case 20:
+		dp.w.WriteString("false")
+	case 21:
+		dp.w.WriteString("true")
+	case 22:
+		dp.w.WriteString("null")
+	case 23:
+		dp.w.WriteString("undefined")
+	case 24:
+		dp.w.WriteString("simple(" + strconv.FormatUint(extra, 10) + ")")
+	case 25, 26, 27:
+		var f float64
+		switch low {
+		case 25:
+			f = halfToFloat64(uint16(extra))
+		case 26:
+			f = float64(math.Float32frombits(uint32(extra)))
+		default:
+			f = math.Float64frombits(extra)
+		}
+		dp.w.WriteString(formatDiagFloat(f))
+		if dp.opts.Annotate {
+			dp.w.WriteString("_" + strconv.Itoa(int(low-24)))
+		}
+	case 31:
+		return errBreak
+	default:
+		dp.w.WriteString("simple(" + strconv.Itoa(int(low)) + ")")
+	}
+	return nil
+}
+
+func formatDiagFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// halfToFloat64 converts an IEEE 754 half precision float.
+func halfToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
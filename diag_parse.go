@@ -0,0 +1,575 @@
+package typegen
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// DiagSyntaxError describes a problem with diagnostic notation given to
+// ParseDiagnostic.
+type DiagSyntaxError struct {
+	// Offset is the byte offset in the input of the problem.
+	Offset int
+	Msg    string
+}
+
+func (e *DiagSyntaxError) Error() string {
+	return fmt.Sprintf("diagnostic notation at offset %d: %s", e.Offset, e.Msg)
+}
+
+// ParseDiagnostic encodes a single value written in the diagnostic notation
+// of RFC 8949, section 8, as Diagnose prints it. It supports:
+//
+//   - integers, with bignums for those beyond 64 bits, and floats, which are
+//     encoded in 64 bits unless an encoding indicator says otherwise
+//   - "text" and 'text' strings, and h'hex', b64'base64' and cid'<cid>' byte
+//     strings, where cid'<cid>' is the binary CID with the leading zero that
+//     tag 42 expects, so a link is written 42(cid'bafy...')
+//   - [arrays], {maps: ...}, tags such as 1(1363896240), true, false, null,
+//     undefined and simple(n)
+//   - indefinite lengths, written [_ ...], {_ ...} and (_ "chunk", ...), and
+//     ""_ for an empty indefinite length text string
+//   - encoding indicators such as 1_0 or [_1 ...], which choose how long the
+//     argument of a header is
+//   - /comments/
+//
+// Arrays, maps and tags may be nested no deeper than MaxNesting.
+func ParseDiagnostic(s string) ([]byte, error) {
+	p := &diagParser{s: s}
+	p.skipSpace()
+	if err := p.value(); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q after value", p.s[p.pos])
+	}
+	return p.out.Bytes(), nil
+}
+
+// MustParseDiagnostic is ParseDiagnostic for values known to be valid, such
+// as test vectors. It panics if s can't be parsed.
+func MustParseDiagnostic(s string) []byte {
+	b, err := ParseDiagnostic(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+type diagParser struct {
+	s     string
+	pos   int
+	out   bytes.Buffer
+	depth int
+}
+
+// enter counts a level of nesting, which leave undoes, and fails past
+// MaxNesting.
+func (p *diagParser) enter() error {
+	if p.depth >= MaxNesting {
+		return p.errorf("nested deeper than %d levels", MaxNesting)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *diagParser) leave() {
+	p.depth--
+}
+
+func (p *diagParser) errorf(format string, args ...interface{}) error {
+	return &DiagSyntaxError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *diagParser) skipSpace() {
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		case '/':
+			end := strings.IndexByte(p.s[p.pos+1:], '/')
+			if end < 0 {
+				return
+			}
+			p.pos += end + 2
+		default:
+			return
+		}
+	}
+}
+
+func (p *diagParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.s[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *diagParser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// indicator parses an optional encoding indicator, returning -1 if there is
+// none.
+func (p *diagParser) indicator() int {
+	if p.pos+1 < len(p.s) && p.s[p.pos] == '_' && p.s[p.pos+1] >= '0' && p.s[p.pos+1] <= '3' {
+		p.pos += 2
+		return int(p.s[p.pos-1] - '0')
+	}
+	return -1
+}
+
+// indefinite parses the _ that marks an indefinite length value, as opposed
+// to an encoding indicator.
+func (p *diagParser) indefinite() bool {
+	if p.pos < len(p.s) && p.s[p.pos] == '_' && !(p.pos+1 < len(p.s) && p.s[p.pos+1] >= '0' && p.s[p.pos+1] <= '3') {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// header writes a header with the argument encoded as indicator ind says, or
+// in the fewest bytes if ind is -1.
+func (p *diagParser) header(maj byte, val uint64, ind int) error {
+	if ind < 0 {
+		return WriteMajorTypeHeader(&p.out, maj, val)
+	}
+	size := 1 << uint(ind)
+	if size < 8 && val >= 1<<(8*uint(size)) {
+		return p.errorf("%d doesn't fit the encoding indicator _%d", val, ind)
+	}
+	p.out.WriteByte(maj<<5 | byte(24+ind))
+	for i := size - 1; i >= 0; i-- {
+		p.out.WriteByte(byte(val >> (8 * uint(i))))
+	}
+	return nil
+}
+
+func (p *diagParser) value() error {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return p.errorf("unexpected end of input")
+	}
+
+	switch c := p.s[p.pos]; {
+	case c == '[':
+		p.pos++
+		return p.container(MajArray, "]")
+	case c == '{':
+		p.pos++
+		return p.container(MajMap, "}")
+	case c == '(':
+		return p.indefiniteString()
+	case c == '"' || c == '\'':
+		return p.stringValue()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	case p.consume("h'"), p.consume("b64'"), p.consume("cid'"):
+		return p.appString()
+	case p.consume("true"):
+		p.out.WriteByte(0xf5)
+	case p.consume("false"):
+		p.out.WriteByte(0xf4)
+	case p.consume("null"):
+		p.out.WriteByte(0xf6)
+	case p.consume("undefined"):
+		p.out.WriteByte(0xf7)
+	case p.consume("NaN"):
+		return p.writeFloat(math.NaN(), p.indicator())
+	case p.consume("Infinity"):
+		return p.writeFloat(math.Inf(1), p.indicator())
+	case p.consume("simple("):
+		return p.simple()
+	default:
+		return p.errorf("unexpected %q", c)
+	}
+	return nil
+}
+
+// container parses the rest of an array or map after its opening bracket.
+func (p *diagParser) container(maj byte, close string) error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer p.leave()
+
+	// The length isn't known until the end, so the elements are written to a
+	// buffer of their own.
+	outer := p.out
+	p.out = bytes.Buffer{}
+
+	indefinite := p.indefinite()
+	ind := -1
+	if !indefinite {
+		ind = p.indicator()
+	}
+
+	var n uint64
+	for {
+		p.skipSpace()
+		if p.consume(close) {
+			break
+		}
+		if n > 0 {
+			if err := p.expect(','); err != nil {
+				return err
+			}
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+		if maj == MajMap {
+			if err := p.expect(':'); err != nil {
+				return err
+			}
+			if err := p.value(); err != nil {
+				return err
+			}
+		}
+		n++
+	}
+
+	elems := p.out
+	p.out = outer
+	if indefinite {
+		p.out.WriteByte(maj<<5 | 31)
+	} else if err := p.header(maj, n, ind); err != nil {
+		return err
+	}
+	p.out.Write(elems.Bytes())
+	if indefinite {
+		p.out.WriteByte(0xff)
+	}
+	return nil
+}
+
+// indefiniteString parses (_ chunk, chunk).
+func (p *diagParser) indefiniteString() error {
+	if !p.consume("(_") {
+		return p.errorf("expected (_")
+	}
+
+	start := p.out.Len()
+	p.out.WriteByte(0)
+	var maj byte
+	for n := 0; ; n++ {
+		p.skipSpace()
+		if p.consume(")") {
+			if n == 0 {
+				return p.errorf("indefinite length strings with no chunks are written ''_ or \"\"_")
+			}
+			break
+		}
+		if n > 0 {
+			if err := p.expect(','); err != nil {
+				return err
+			}
+			p.skipSpace()
+		}
+
+		chunk := p.out.Len()
+		if err := p.value(); err != nil {
+			return err
+		}
+		cmaj := p.out.Bytes()[chunk] >> 5
+		if p.out.Bytes()[chunk]&0x1f == 31 || (cmaj != MajByteString && cmaj != MajTextString) || (n > 0 && cmaj != maj) {
+			return p.errorf("chunks of indefinite length strings must be definite strings of the same type")
+		}
+		maj = cmaj
+	}
+
+	p.out.Bytes()[start] = maj<<5 | 31
+	p.out.WriteByte(0xff)
+	return nil
+}
+
+// stringValue parses a "text" string, or a 'text' byte string.
+func (p *diagParser) stringValue() error {
+	quote := p.s[p.pos]
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.s) && p.s[p.pos] != quote {
+		if p.s[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.s) {
+		p.pos = start
+		return p.errorf("unterminated string")
+	}
+	p.pos++
+
+	var str string
+	maj := byte(MajTextString)
+	if quote == '"' {
+		s, err := strconv.Unquote(p.s[start:p.pos])
+		if err != nil {
+			p.pos = start
+			return p.errorf("invalid string: %s", err)
+		}
+		str = s
+	} else {
+		maj = MajByteString
+		str = strings.NewReplacer(`\\`, `\`, `\'`, `'`).Replace(p.s[start+1 : p.pos-1])
+	}
+
+	if p.indefinite() {
+		if str != "" {
+			return p.errorf("only empty strings can be written with _ for indefinite length")
+		}
+		p.out.WriteByte(maj<<5 | 31)
+		p.out.WriteByte(0xff)
+		return nil
+	}
+	return p.stringBytes(maj, []byte(str), p.indicator())
+}
+
+// stringBytes writes a string of major type maj.
+func (p *diagParser) stringBytes(maj byte, b []byte, ind int) error {
+	if err := p.header(maj, uint64(len(b)), ind); err != nil {
+		return err
+	}
+	p.out.Write(b)
+	return nil
+}
+
+// appString parses the rest of h'...', b64'...' or cid'...'.
+func (p *diagParser) appString() error {
+	var kind string
+	switch {
+	case strings.HasSuffix(p.s[:p.pos], "cid'"):
+		kind = "cid"
+	case strings.HasSuffix(p.s[:p.pos], "b64'"):
+		kind = "b64"
+	default:
+		kind = "h"
+	}
+
+	end := strings.IndexByte(p.s[p.pos:], '\'')
+	if end < 0 {
+		return p.errorf("unterminated %s string", kind)
+	}
+	content := p.s[p.pos : p.pos+end]
+	start := p.pos
+	p.pos += end + 1
+
+	var b []byte
+	var err error
+	switch kind {
+	case "h":
+		b, err = hex.DecodeString(strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+				return -1
+			}
+			return r
+		}, content))
+	case "b64":
+		content = strings.TrimRight(content, "=")
+		if strings.ContainsAny(content, "-_") {
+			b, err = base64.RawURLEncoding.DecodeString(content)
+		} else {
+			b, err = base64.RawStdEncoding.DecodeString(content)
+		}
+	case "cid":
+		var c cid.Cid
+		c, err = cid.Decode(content)
+		if err == nil {
+			b = append([]byte{0}, c.Bytes()...)
+		}
+	}
+	if err != nil {
+		p.pos = start
+		return p.errorf("invalid %s string: %s", kind, err)
+	}
+	return p.stringBytes(MajByteString, b, p.indicator())
+}
+
+// number parses an integer or float, or a tag if an integer is followed by (.
+func (p *diagParser) number() error {
+	neg := p.consume("-")
+	if p.consume("Infinity") {
+		return p.writeFloat(math.Inf(-1), p.indicator())
+	}
+	start := p.pos
+
+	isFloat := false
+	base := 10
+	if p.consume("0x") {
+		base = 16
+		start = p.pos
+		for p.pos < len(p.s) && isHexDigit(p.s[p.pos]) {
+			p.pos++
+		}
+	} else {
+		for ; p.pos < len(p.s); p.pos++ {
+			c := p.s[p.pos]
+			if c == '.' || c == 'e' || c == 'E' || ((c == '+' || c == '-') && isFloat && (p.s[p.pos-1] == 'e' || p.s[p.pos-1] == 'E')) {
+				isFloat = true
+			} else if c < '0' || c > '9' {
+				break
+			}
+		}
+	}
+	text := p.s[start:p.pos]
+
+	if isFloat {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.pos = start
+			return p.errorf("invalid number %q", text)
+		}
+		if neg {
+			f = -f
+		}
+		return p.writeFloat(f, p.indicator())
+	}
+
+	n, ok := new(big.Int).SetString(text, base)
+	if !ok {
+		p.pos = start
+		return p.errorf("invalid number %q", text)
+	}
+	if neg {
+		n.Neg(n)
+	}
+	ind := p.indicator()
+
+	if p.pos < len(p.s) && p.s[p.pos] == '(' {
+		if neg || !n.IsUint64() {
+			return p.errorf("invalid tag %s", text)
+		}
+		p.pos++
+		if err := p.header(MajTag, n.Uint64(), ind); err != nil {
+			return err
+		}
+		if err := p.enter(); err != nil {
+			return err
+		}
+		defer p.leave()
+		if err := p.value(); err != nil {
+			return err
+		}
+		return p.expect(')')
+	}
+
+	maj := byte(MajUnsignedInt)
+	if n.Sign() < 0 {
+		// -1 - n
+		maj = MajNegativeInt
+		n.Neg(n).Sub(n, big.NewInt(1))
+	}
+	if n.IsUint64() {
+		return p.header(maj, n.Uint64(), ind)
+	}
+	if ind >= 0 {
+		return p.errorf("%s is too large for an encoding indicator", text)
+	}
+	// Tag 2 or 3 for a bignum.
+	if err := p.header(MajTag, 2+uint64(maj), -1); err != nil {
+		return err
+	}
+	return p.stringBytes(MajByteString, n.Bytes(), -1)
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// writeFloat writes f in the width the encoding indicator ind gives, or in
+// 64 bits if there is none.
+func (p *diagParser) writeFloat(f float64, ind int) error {
+	switch ind {
+	case 1:
+		h, ok := float64ToHalf(f)
+		if !ok {
+			return p.errorf("%v can't be written as a half precision float", f)
+		}
+		return p.header(MajOther, uint64(h), 1)
+	case 2:
+		f32 := float32(f)
+		if float64(f32) != f && !math.IsNaN(f) {
+			return p.errorf("%v can't be written as a single precision float", f)
+		}
+		return p.header(MajOther, uint64(math.Float32bits(f32)), 2)
+	case -1, 3:
+		return p.header(MajOther, math.Float64bits(f), 3)
+	default:
+		return p.errorf("invalid encoding indicator for a float")
+	}
+}
+
+// float64ToHalf converts f to an IEEE 754 half precision float, if it can
+// be without losing precision.
+func float64ToHalf(f float64) (uint16, bool) {
+	var sign uint16
+	if math.Signbit(f) {
+		sign = 0x8000
+		f = -f
+	}
+
+	switch {
+	case math.IsNaN(f):
+		return 0x7e00, true
+	case math.IsInf(f, 0):
+		return sign | 0x7c00, true
+	case f == 0:
+		return sign, true
+	}
+
+	frac, exp := math.Frexp(f) // f = frac * 2^exp, 0.5 <= frac < 1
+	if exp > 16 {
+		return 0, false
+	}
+	if exp < -13 {
+		// Subnormal: f = mant * 2^-24.
+		mant := math.Ldexp(f, 24)
+		if mant != math.Trunc(mant) || mant >= 1024 {
+			return 0, false
+		}
+		return sign | uint16(mant), true
+	}
+	mant := math.Ldexp(frac, 11) // 1024 <= mant < 2048
+	if mant != math.Trunc(mant) {
+		return 0, false
+	}
+	return sign | uint16(exp+14)<<10 | (uint16(mant) - 1024), true
+}
+
+// simple parses the rest of simple(n).
+func (p *diagParser) simple() error {
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.ParseUint(p.s[start:p.pos], 10, 8)
+	if err != nil || (n >= 24 && n < 32) {
+		p.pos = start
+		return p.errorf("invalid simple value")
+	}
+	if err := p.expect(')'); err != nil {
+		return err
+	}
+	if n < 24 {
+		p.out.WriteByte(MajOther<<5 | byte(n))
+	} else {
+		p.out.Write([]byte{MajOther<<5 | 24, byte(n)})
+	}
+	return nil
+}
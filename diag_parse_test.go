@@ -0,0 +1,141 @@
+package typegen
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestParseDiagnosticRoundTrip(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	var cidBuf bytes.Buffer
+	if err := WriteCid(&cidBuf, c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, in := range []string{
+		"00", "17", "1801", "1818", "190100", "1a00010000", "1b0000000100000000",
+		"20", "3bffffffffffffffff", "430102ff", "40", "6461220a62", "60",
+		"79000161", "83010203", "98020102", "a261610161628102", "80", "a0",
+		"c11a514b67b0", hex.EncodeToString(cidBuf.Bytes()), "d82a4101",
+		"c249010000000000000000", "c349010000000000000000", "c24101",
+		"f4", "f5", "f6", "f7", "f0", "f8ff", "f93c00", "f97c00", "f9fc00",
+		"f90001", "fa47c35000", "fb3ff199999999999a", "9f0102ff", "9fff",
+		"bf616101ff", "5f41014102ff", "7f61616162ff", "5fff", "7fff",
+		"d9ffff00", "a16161820102",
+	} {
+		want, err := hex.DecodeString(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range []DiagnoseOptions{{Annotate: true}, {Annotate: true, Pretty: true}} {
+			var diag strings.Builder
+			if err := DiagnoseWithOptions(bytes.NewReader(want), &diag, opts); err != nil {
+				t.Fatalf("%s: %s", in, err)
+			}
+			got, err := ParseDiagnostic(diag.String())
+			if err != nil {
+				t.Errorf("%s: parsing %s: %s", in, diag.String(), err)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: %s parsed as %x", in, diag.String(), got)
+			}
+		}
+	}
+}
+
+func TestParseDiagnostic(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	var cidBuf bytes.Buffer
+	if err := WriteCid(&cidBuf, c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		out string
+	}{
+		{"42(cid'bafkqaaa')", hex.EncodeToString(cidBuf.Bytes())},
+		{"42(h'00" + hex.EncodeToString(c.Bytes()) + "')", hex.EncodeToString(cidBuf.Bytes())},
+		{"0x10", "10"},
+		{"010", "0a"},
+		{"-0", "00"},
+		{"-0.0_1", "f98000"},
+		{"-0x10", "2f"},
+		{"1.5", "fb3ff8000000000000"},
+		{"1.5_1", "f93e00"},
+		{"-Infinity_1", "f9fc00"},
+		{"1e3_2", "fa447a0000"},
+		{"'it\\'s'", "4469742773"},
+		{"h'01 02\n03'", "43010203"},
+		{"b64'AQI'", "420102"},
+		{"b64'-_8='", "42fbff"},
+		{"[1, /two/ 2]", "820102"},
+		{"{1: [_ ], 2: {_ }}", "a2019fff02bfff"},
+		{"(_ 'a', 'b')", "5f41614162ff"},
+		{"18446744073709551616", "c249010000000000000000"},
+		{"-18446744073709551617", "c349010000000000000000"},
+		{"24_1(1)", "d9001801"},
+		{"simple(255)", "f8ff"},
+	} {
+		got, err := ParseDiagnostic(tc.in)
+		if err != nil {
+			t.Errorf("%s: %s", tc.in, err)
+			continue
+		}
+		if hex.EncodeToString(got) != tc.out {
+			t.Errorf("%s: expected %s, got %x", tc.in, tc.out, got)
+		}
+	}
+}
+
+func TestParseDiagnosticErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"[1, 2",
+		"[1 2]",
+		"{1}",
+		"1 2",
+		"256_0",
+		"1.1_1",
+		"0.1_2",
+		"-1(2)",
+		"\"abc",
+		"h'0'",
+		"cid'nope'",
+		"(_ 1)",
+		"(_ 'a', \"b\")",
+		"'a'_",
+		"simple(24)",
+		"18446744073709551616_3",
+		"nul",
+	} {
+		_, err := ParseDiagnostic(in)
+		if _, ok := err.(*DiagSyntaxError); !ok {
+			t.Errorf("%q: expected a syntax error, got %v", in, err)
+		}
+	}
+}
+
+func TestParseDiagnosticNesting(t *testing.T) {
+	nested := func(n int) string {
+		return strings.Repeat("[", n) + strings.Repeat("]", n)
+	}
+	if _, err := ParseDiagnostic(nested(MaxNesting)); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []string{
+		nested(MaxNesting + 1),
+		strings.Repeat("1(", MaxNesting) + "[]" + strings.Repeat(")", MaxNesting),
+		strings.Repeat("[", 1<<20),
+	} {
+		_, err := ParseDiagnostic(in)
+		if serr, ok := err.(*DiagSyntaxError); !ok || !strings.Contains(serr.Msg, "nested") {
+			t.Fatalf("expected a syntax error at the limit, got %v", err)
+		}
+	}
+}
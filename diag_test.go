@@ -0,0 +1,108 @@
+package typegen
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestDiagnose(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	var cidBuf bytes.Buffer
+	if err := WriteCid(&cidBuf, c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		in   string
+		out  string
+		opts DiagnoseOptions
+	}{
+		{in: "00", out: "0"},
+		{in: "1b0000000100000000", out: "4294967296"},
+		{in: "20", out: "-1"},
+		{in: "3bffffffffffffffff", out: "-18446744073709551616"},
+		{in: "430102ff", out: "h'0102ff'"},
+		{in: "6461220a62", out: `"a\"\nb"`},
+		{in: "83010203", out: "[1, 2, 3]"},
+		{in: "a261610161628102", out: `{"a": 1, "b": [2]}`},
+		{in: "80", out: "[]"},
+		{in: "c11a514b67b0", out: "1(1363896240)"},
+		{in: hex.EncodeToString(cidBuf.Bytes()), out: "42(cid'bafkqaaa')"},
+		{in: "d82a4101", out: "42(h'01')"},
+		{in: "c249010000000000000000", out: "18446744073709551616"},
+		{in: "c349010000000000000000", out: "-18446744073709551617"},
+		{in: "c24101", out: "2(h'01')"},
+		{in: "f4", out: "false"},
+		{in: "f5", out: "true"},
+		{in: "f6", out: "null"},
+		{in: "f7", out: "undefined"},
+		{in: "f93c00", out: "1.0"},
+		{in: "fa47c35000", out: "100000.0"},
+		{in: "fb3ff199999999999a", out: "1.1"},
+		{in: "f97c00", out: "Infinity"},
+		{in: "f0", out: "simple(16)"},
+		{in: "9f0102ff", out: "[_ 1, 2]"},
+		{in: "bf616101ff", out: `{_ "a": 1}`},
+		{in: "5f41014102ff", out: "(_ h'01', h'02')"},
+		{in: "5fff", out: "''_"},
+		{in: "7fff", out: `""_`},
+
+		// Encoding indicators.
+		{in: "1801", out: "1_0", opts: DiagnoseOptions{Annotate: true}},
+		{in: "1801", out: "1"},
+		{in: "190100", out: "256", opts: DiagnoseOptions{Annotate: true}},
+		{in: "98020102", out: "[_0 1, 2]", opts: DiagnoseOptions{Annotate: true}},
+		{in: "7900016161", out: `"a"_1`, opts: DiagnoseOptions{Annotate: true}},
+		{in: "fa3f800000", out: "1.0_2", opts: DiagnoseOptions{Annotate: true}},
+
+		{in: "a1616182010280", out: "{\n\t\"a\": [\n\t\t1,\n\t\t2\n\t]\n}", opts: DiagnoseOptions{Pretty: true, Indent: "\t"}},
+		{in: "8180", out: "[\n  []\n]", opts: DiagnoseOptions{Pretty: true}},
+	} {
+		in, err := hex.DecodeString(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		if err := DiagnoseWithOptions(bytes.NewReader(in), &out, tc.opts); err != nil {
+			t.Errorf("%s: %s", tc.in, err)
+			continue
+		}
+		if out.String() != tc.out {
+			t.Errorf("%s: expected %s, got %s", tc.in, tc.out, out.String())
+		}
+	}
+}
+
+func TestDiagnoseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		err error
+	}{
+		{"", io.EOF},
+		{"82", io.ErrUnexpectedEOF},
+		{"8201", io.ErrUnexpectedEOF},
+		{"43", io.ErrUnexpectedEOF},
+		{"5bffffffffffffff00", io.ErrUnexpectedEOF},
+		{"5f4101", io.ErrUnexpectedEOF},
+		{"7f", io.ErrUnexpectedEOF},
+		{"ff", errUnexpectedBreak},
+		{"82ff", errUnexpectedBreak},
+		{"bf01ff", errUnexpectedBreak},
+		{strings.Repeat("81", MaxNesting+1) + "01", ErrDecodeTooDeep},
+		{strings.Repeat("c1", 1000000) + "01", ErrDecodeTooDeep},
+	} {
+		in, err := hex.DecodeString(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Diagnose(bytes.NewReader(in), ioutil.Discard); err != tc.err {
+			t.Errorf("%s: expected %v, got %v", tc.in, tc.err, err)
+		}
+	}
+}
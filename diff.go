@@ -0,0 +1,181 @@
+package typegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContext = 3
+
+	// maxDiffCells bounds the size of the LCS table built for the differing
+	// region of two files. Past that we just report the whole region as changed.
+	maxDiffCells = 1 << 22
+)
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// lineDiff renders a unified diff from old (the file on disk) to new (the
+// freshly generated code).
+func lineDiff(fname string, old, new []byte) string {
+	a := splitLines(old)
+	b := splitLines(new)
+
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s (on disk)\n+++ %s (generated)\n", fname, fname)
+
+	// Walk the edit script, emitting a hunk for every run of changes along
+	// with a few lines of surrounding context.
+	aline, bline := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			aline++
+			bline++
+			i++
+			continue
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			// Merge changes that are separated by less than two
+			// context blocks into the same hunk.
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		hunkA, hunkB := aline-(i-start), bline-(i-start)
+		var countA, countB int
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", hunkA, countA, hunkB, countB)
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.line)
+		}
+
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				aline++
+			}
+			if op.kind != '-' {
+				bline++
+			}
+		}
+		i = end
+	}
+
+	return out.String()
+}
+
+func splitLines(b []byte) []string {
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	if len(b) == 0 {
+		return nil
+	}
+	return strings.Split(string(b), "\n")
+}
+
+// diffLines computes an edit script turning a into b.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+
+	// Strip the common prefix and suffix, generated files usually only
+	// differ in a small region.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		ops = append(ops, diffOp{' ', a[pre]})
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if len(ma)*len(mb) > maxDiffCells {
+		for _, l := range ma {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range mb {
+			ops = append(ops, diffOp{'+', l})
+		}
+	} else {
+		ops = append(ops, lcsDiff(ma, mb)...)
+	}
+
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+func lcsDiff(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	w := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else if lcs[(i+1)*w+j] >= lcs[i*w+j+1] {
+				lcs[i*w+j] = lcs[(i+1)*w+j]
+			} else {
+				lcs[i*w+j] = lcs[i*w+j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
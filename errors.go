@@ -0,0 +1,187 @@
+package typegen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrNonCanonical is returned when a header in the input doesn't use the
+// shortest encoding of its value.
+var ErrNonCanonical = errors.New("cbor input was not canonical")
+
+// ErrWrongMajorType is returned when a value in the input has a different major
+// type than expected.
+type ErrWrongMajorType struct {
+	Want byte
+	Got  byte
+}
+
+func (e *ErrWrongMajorType) Error() string {
+	return fmt.Sprintf("expected cbor type '%s' in input, got '%s'", majorTypeName(e.Want), majorTypeName(e.Got))
+}
+
+// ErrTooLong is returned when a string, byte string, array or map in the input
+// is longer than allowed.
+type ErrTooLong struct {
+	Length uint64
+	Limit  uint64
+}
+
+func (e *ErrTooLong) Error() string {
+	return fmt.Sprintf("length %d beyond maximum allowed (%d)", e.Length, e.Limit)
+}
+
+// ErrUnexpectedTag is returned when a value in the input has a different tag
+// than expected.
+type ErrUnexpectedTag struct {
+	Want uint64
+	Got  uint64
+}
+
+func (e *ErrUnexpectedTag) Error() string {
+	return fmt.Sprintf("expected tag %d, got %d", e.Want, e.Got)
+}
+
+// ErrWrongLength is returned when a byte string or array in the input that
+// must have a fixed length, such as one holding a Go array or a struct in
+// tuple representation, has another.
+type ErrWrongLength struct {
+	Want uint64
+	Got  uint64
+}
+
+func (e *ErrWrongLength) Error() string {
+	return fmt.Sprintf("expected length %d in input, got %d", e.Want, e.Got)
+}
+
+// ErrIntOverflow is returned when an integer in the input is out of the
+// range of the Go type it is decoded into.
+type ErrIntOverflow struct {
+	// Type is the Go type, such as "uint8".
+	Type string
+
+	// Value is the argument of the integer's header, which for a negative
+	// integer is -1 minus its value.
+	Value    uint64
+	Negative bool
+}
+
+func (e *ErrIntOverflow) Error() string {
+	n := strconv.FormatUint(e.Value, 10)
+	if e.Negative {
+		n = "-1-" + n
+	}
+	return fmt.Sprintf("integer %s in input overflows %s", n, e.Type)
+}
+
+func majorTypeName(maj byte) string {
+	switch maj {
+	case MajUnsignedInt:
+		return "unsigned int"
+	case MajNegativeInt:
+		return "negative int"
+	case MajByteString:
+		return "byte string"
+	case MajTextString:
+		return "text string"
+	case MajArray:
+		return "array"
+	case MajMap:
+		return "map"
+	case MajTag:
+		return "tag"
+	case MajOther:
+		return "other"
+	default:
+		return fmt.Sprintf("unknown (%d)", maj)
+	}
+}
+
+// DecodeError gives the location in the input of an error from a generated
+// decoder. Decoders generated with GenOptions.ErrorContext return it.
+type DecodeError struct {
+	// Type is the outermost type being decoded.
+	Type string
+
+	// Path leads from Type to the field that failed, such as
+	// "Stuff.Arrrrrghay[2].Foo". It is empty for errors in the type itself.
+	Path string
+
+	// Offset is the number of bytes read from the input when the error
+	// happened, or -1 if the reader doesn't know.
+	Offset int64
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	where := e.Type
+	if e.Path != "" {
+		where += "." + e.Path
+	}
+	if e.Offset >= 0 {
+		return fmt.Sprintf("decoding %s at byte %d: %s", where, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("decoding %s: %s", where, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// FieldPos is where a generated decoder is in the value it's decoding.
+type FieldPos struct {
+	Field string
+
+	// Index holds the indexes into Field of the Depth nested arrays being
+	// decoded.
+	Index [3]int
+	Depth int
+
+	// Key is the map key being decoded if HasKey is set.
+	Key    string
+	HasKey bool
+}
+
+func (p FieldPos) String() string {
+	s := p.Field
+	for _, i := range p.Index[:p.Depth] {
+		s += "[" + strconv.Itoa(i) + "]"
+	}
+	if p.HasKey {
+		s += "[" + strconv.Quote(p.Key) + "]"
+	}
+	return s
+}
+
+// WrapDecodeError adds the position pos in a value of type typ to err, read
+// from r. If err already has a position in a nested value the two are joined.
+//
+// io.EOF is returned as is, since decoders only return it when the input ends
+// before the value starts.
+func WrapDecodeError(err error, typ string, pos FieldPos, r io.Reader) error {
+	if err == io.EOF {
+		return err
+	}
+	path := pos.String()
+
+	var inner *DecodeError
+	if errors.As(err, &inner) {
+		// The field is the nested value, so the inner type is implied.
+		if inner.Path != "" {
+			if path != "" {
+				path += "."
+			}
+			path += inner.Path
+		}
+		return &DecodeError{Type: typ, Path: path, Offset: inner.Offset, Err: inner.Err}
+	}
+
+	offset := int64(-1)
+	if cr, ok := r.(*CborReader); ok {
+		offset = cr.Offset()
+	}
+	return &DecodeError{Type: typ, Path: path, Offset: offset, Err: err}
+}
@@ -0,0 +1,138 @@
+package typegen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestNonCanonicalError(t *testing.T) {
+	// 1 encoded with a one byte argument.
+	_, _, err := CborReadHeader(bytes.NewReader([]byte{0x18, 0x01}))
+	if !errors.Is(err, ErrNonCanonical) {
+		t.Fatalf("expected ErrNonCanonical, got %v", err)
+	}
+}
+
+func TestWrongMajorTypeError(t *testing.T) {
+	_, err := ReadString(bytes.NewReader([]byte{0x80}))
+	var wrongType *ErrWrongMajorType
+	if !errors.As(err, &wrongType) {
+		t.Fatalf("expected ErrWrongMajorType, got %v", err)
+	}
+	if wrongType.Want != MajTextString || wrongType.Got != MajArray {
+		t.Fatalf("expected text string and array, got %+v", wrongType)
+	}
+	if err.Error() != "expected cbor type 'text string' in input, got 'array'" {
+		t.Fatalf("unexpected message: %s", err)
+	}
+}
+
+func TestTooLongError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMajorTypeHeader(&buf, MajByteString, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ReadByteArray(&buf, 10)
+	var tooLong *ErrTooLong
+	if !errors.As(err, &tooLong) {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+	if tooLong.Length != 100 || tooLong.Limit != 10 {
+		t.Fatalf("expected length 100 and limit 10, got %+v", tooLong)
+	}
+}
+
+func TestUnexpectedTagError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMajorTypeHeader(&buf, MajTag, 43); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, read := range []func() error{
+		func() error {
+			_, err := ReadCid(bytes.NewReader(buf.Bytes()))
+			return err
+		},
+		func() error {
+			_, err := ReadCid(NewCborReaderBytes(buf.Bytes()))
+			return err
+		},
+	} {
+		err := read()
+		var unexpected *ErrUnexpectedTag
+		if !errors.As(err, &unexpected) {
+			t.Fatalf("expected ErrUnexpectedTag, got %v", err)
+		}
+		if unexpected.Want != 42 || unexpected.Got != 43 {
+			t.Fatalf("expected tag 42 and 43, got %+v", unexpected)
+		}
+	}
+}
+
+func TestIntOverflowError(t *testing.T) {
+	for _, tc := range []struct {
+		in       []byte
+		negative bool
+		msg      string
+	}{
+		{CborEncodeMajorType(MajUnsignedInt, 1<<63), false, "integer 9223372036854775808 in input overflows int64"},
+		{CborEncodeMajorType(MajNegativeInt, 1<<63), true, "integer -1-9223372036854775808 in input overflows int64"},
+	} {
+		var ci CborInt
+		err := ci.UnmarshalCBOR(bytes.NewReader(tc.in))
+		var overflow *ErrIntOverflow
+		if !errors.As(err, &overflow) {
+			t.Fatalf("%x: expected ErrIntOverflow, got %v", tc.in, err)
+		}
+		if overflow.Type != "int64" || overflow.Value != 1<<63 || overflow.Negative != tc.negative {
+			t.Fatalf("%x: unexpected %+v", tc.in, overflow)
+		}
+		if err.Error() != tc.msg {
+			t.Fatalf("%x: unexpected message: %s", tc.in, err)
+		}
+	}
+
+	var ci CborInt
+	err := ci.UnmarshalCBOR(bytes.NewReader([]byte{0x60}))
+	var wrongType *ErrWrongMajorType
+	if !errors.As(err, &wrongType) || wrongType.Want != MajUnsignedInt || wrongType.Got != MajTextString {
+		t.Fatalf("expected ErrWrongMajorType, got %v", err)
+	}
+}
+
+func TestWrapDecodeError(t *testing.T) {
+	cr := NewCborReaderBytes([]byte{0x01, 0x02})
+	if _, err := readByte(cr); err != nil {
+		t.Fatal(err)
+	}
+
+	inner := WrapDecodeError(ErrNonCanonical, "Inner", FieldPos{Field: "Vals", Key: "a\"b", HasKey: true}, cr)
+	pos := FieldPos{Field: "Items", Index: [3]int{2, 5}, Depth: 2}
+	err := WrapDecodeError(fmt.Errorf("reading item: %w", inner), "Outer", pos, NewCborReaderBytes(nil))
+
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) {
+		t.Fatalf("expected a DecodeError, got %v", err)
+	}
+	if decErr.Path != `Items[2][5].Vals["a\"b"]` || decErr.Offset != 1 {
+		t.Fatalf("unexpected path or offset: %+v", decErr)
+	}
+	if !errors.Is(err, ErrNonCanonical) {
+		t.Fatalf("expected the error to wrap ErrNonCanonical, got %v", err)
+	}
+	if err.Error() != `decoding Outer.Items[2][5].Vals["a\"b"] at byte 1: cbor input was not canonical` {
+		t.Fatalf("unexpected message: %s", err)
+	}
+
+	if err := WrapDecodeError(io.EOF, "Outer", FieldPos{}, cr); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	err = WrapDecodeError(ErrNonCanonical, "Outer", FieldPos{}, bytes.NewReader(nil))
+	if err.Error() != "decoding Outer: cbor input was not canonical" {
+		t.Fatalf("unexpected message: %s", err)
+	}
+}
@@ -3,8 +3,10 @@ package typegen
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/big"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
@@ -17,6 +19,12 @@ const MaxLength = 8192
 const ByteArrayMaxLen = 2 << 20
 
 const MaxLenTag = "maxlen"
+
+// ForEachTag marks a slice field for which a ForEach<Field> method is
+// generated, which decodes the type while streaming the elements of the field
+// to a callback instead of collecting them. Like json's omitempty, it comes
+// after the field's name, which may be empty: `cborgen:",foreach"`.
+const ForEachTag = "foreach"
 const NoUsrMaxLen = -1
 
 var (
@@ -25,7 +33,113 @@ var (
 	deferredType = reflect.TypeOf(Deferred{})
 )
 
-func doTemplate(w io.Writer, info interface{}, templ string) error {
+// defaultMaxMapLength is the limit on the number of entries in map fields used
+// when GenOptions.MaxMapLength is unset.
+const defaultMaxMapLength = 4096
+
+// GenOptions controls the code generated for a set of types. Its zero value
+// generates the same code as the package level functions, so packages that
+// need different limits or layout can each generate with their own options.
+type GenOptions struct {
+	// MaxLength is the maximum length of slices and strings without a maxlen
+	// tag. If zero, the generated code uses cbg.MaxLength.
+	MaxLength int
+
+	// MaxByteLength is the maximum length of byte arrays without a maxlen
+	// tag. If zero, the generated code uses cbg.ByteArrayMaxLen.
+	MaxByteLength int
+
+	// MaxMapLength is the maximum number of entries in map fields. If zero,
+	// 4096 is used.
+	MaxMapLength int
+
+	// CanonicalOrder writes the fields of map encoded structs and the keys of
+	// map fields in length-first canonical order (RFC 7049, section 3.9) as
+	// DAG-CBOR requires. By default struct fields are written in declaration
+	// order and map keys in lexical order.
+	CanonicalOrder bool
+
+	// Strict makes map decoders reject fields that do not exist on the type
+	// instead of skipping over them.
+	Strict bool
+
+	// HeaderComment is added to the top of the generated file, below the
+	// "Code generated" line. Each line is written as a line comment.
+	HeaderComment string
+
+	// BuildTags are build tags, such as "linux" or "!js", that must all be
+	// satisfied for the generated file to be built.
+	BuildTags []string
+
+	// AppendEncoders generates an AppendCBOR method for each type, which
+	// encodes straight into a byte slice. MarshalCBOR becomes a thin wrapper
+	// around it.
+	AppendEncoders bool
+
+	// SizeMethods generates a CBORSize method for each type, which returns
+	// the exact length of its encoding.
+	SizeMethods bool
+
+	// BytesDecoders generates an UnmarshalCBORBytes method for each type,
+	// which decodes from a byte slice and returns the unread rest of it.
+	BytesDecoders bool
+
+	// ZeroCopyBytes makes decoders read byte slice fields with
+	// CborReader.ReadByteSlice, so that when decoding from a reader created
+	// by NewCborReaderBytes (as UnmarshalCBORBytes does) the fields alias the
+	// input rather than copy it. Other readers still copy.
+	ZeroCopyBytes bool
+
+	// LinkWalkers generates ForEachLink and ForEachLinkPath methods, which
+	// visit the CIDs in a value without marshaling it. See LinkWalker.
+	LinkWalkers bool
+
+	// DecodeLimits makes the generated decoders enforce the MaxDepth and
+	// MaxAlloc limits of the CborReader they decode from. See DecodeOptions.
+	DecodeLimits bool
+
+	// ErrorContext makes the generated decoders return errors as a
+	// *DecodeError, which gives the path to the field that failed, such as
+	// "SimpleTypeTwo.Stuff.Arrrrrghay[2]", and where it is in the input.
+	ErrorContext bool
+
+	// JSONCodecs generates MarshalJSON and UnmarshalJSON methods for each
+	// type, which use the field names of the map representation whatever the
+	// representation of the type. Byte strings, CIDs and Deferred values are
+	// written as DAG-JSON, as CBORToDagJSON writes them. Other values are
+	// encoded by encoding/json.
+	JSONCodecs bool
+
+	// ExtraImports are added to the import block of the generated file, in
+	// addition to the imports needed by the generated code. The caller must
+	// make sure they are used, or import them under the name "_". The name of
+	// an extra import is also used when the generated code refers to its
+	// package.
+	ExtraImports []Import
+}
+
+func (g GenOptions) maxLength() string {
+	if g.MaxLength > 0 {
+		return strconv.Itoa(g.MaxLength)
+	}
+	return "cbg.MaxLength"
+}
+
+func (g GenOptions) maxByteLength() string {
+	if g.MaxByteLength > 0 {
+		return strconv.Itoa(g.MaxByteLength)
+	}
+	return "cbg.ByteArrayMaxLen"
+}
+
+func (g GenOptions) maxMapLength() string {
+	if g.MaxMapLength > 0 {
+		return strconv.Itoa(g.MaxMapLength)
+	}
+	return strconv.Itoa(defaultMaxMapLength)
+}
+
+func (g GenOptions) doTemplate(w io.Writer, info interface{}, templ string) error {
 	t := template.Must(template.New("").
 		Funcs(template.FuncMap{
 			"MajorType": func(wname string, tname string, val string) string {
@@ -36,32 +150,76 @@ func doTemplate(w io.Writer, info interface{}, templ string) error {
 			"ReadHeader": func(rdr string) string {
 				return fmt.Sprintf(`%s.ReadHeader()`, rdr)
 			},
-			"MaxLen": func(val int, def string) string {
+			"MaxLen": func(val int) string {
+				if val <= 0 {
+					return g.maxLength()
+				}
+				return fmt.Sprintf("%d", val)
+			},
+			"MaxByteLen": func(val int) string {
 				if val <= 0 {
-					return def
+					return g.maxByteLength()
 				}
 				return fmt.Sprintf("%d", val)
 			},
+			"MaxMapLen": g.maxMapLength,
+			"join":      strings.Join,
+			"ZeroCopyBytes": func() bool {
+				return g.ZeroCopyBytes
+			},
+			"DecodeLimits": func() bool {
+				return g.DecodeLimits
+			},
+			"ErrorContext": func() bool {
+				return g.ErrorContext
+			},
+			"AllocSize": allocSize,
 		}).Parse(templ))
 
 	return t.Execute(w, info)
 }
 
 func PrintHeaderAndUtilityMethods(w io.Writer, pkg string, typeInfos []*GenTypeInfo) error {
-	var imports []Import
+	return GenOptions{}.PrintHeaderAndUtilityMethods(w, pkg, typeInfos)
+}
+
+func (g GenOptions) PrintHeaderAndUtilityMethods(w io.Writer, pkg string, typeInfos []*GenTypeInfo) error {
+	if errs := checkImports(g.ExtraImports); len(errs) > 0 {
+		return errs
+	}
+
+	// Package names are allocated per file, so that they only depend on
+	// the types generated together.
+	importSet := newImportSet(typeInfos, g.ExtraImports)
+
+	imports := append([]Import{}, g.ExtraImports...)
 	for _, gti := range typeInfos {
+		gti.setImports(importSet)
 		imports = append(imports, gti.Imports()...)
 	}
 
 	imports = append(imports, defaultImports...)
 	imports = dedupImports(imports)
 
+	var comment []string
+	if g.HeaderComment != "" {
+		comment = strings.Split(strings.TrimRight(g.HeaderComment, "\n"), "\n")
+	}
+
 	data := struct {
-		Package string
-		Imports []Import
-	}{pkg, imports}
-	return doTemplate(w, data, `// Code generated by github.com/whyrusleeping/cbor-gen. DO NOT EDIT.
+		Package   string
+		Imports   []Import
+		Comment   []string
+		BuildTags []string
+	}{pkg, imports, comment, g.BuildTags}
+	return g.doTemplate(w, data, `// Code generated by github.com/whyrusleeping/cbor-gen. DO NOT EDIT.
+{{ range .Comment }}// {{ . }}
+{{ end }}
+{{ if .BuildTags }}
+//go:build {{ join .BuildTags " && " }}
+// +build {{ join .BuildTags "," }}
 
+{{ end }}
 package {{ .Package }}
 
 import (
@@ -93,18 +251,23 @@ type Field struct {
 	IterLabel string
 
 	MaxLen int
+
+	// ForEach is set by the foreach tag, see ForEachTag.
+	ForEach bool
+
+	imports *importSet
 }
 
-func typeName(pkg string, t reflect.Type) string {
+func typeName(imports *importSet, pkg string, t reflect.Type) string {
 	switch t.Kind() {
 	case reflect.Array:
-		return fmt.Sprintf("[%d]%s", t.Len(), typeName(pkg, t.Elem()))
+		return fmt.Sprintf("[%d]%s", t.Len(), typeName(imports, pkg, t.Elem()))
 	case reflect.Slice:
-		return "[]" + typeName(pkg, t.Elem())
+		return "[]" + typeName(imports, pkg, t.Elem())
 	case reflect.Ptr:
-		return "*" + typeName(pkg, t.Elem())
+		return "*" + typeName(imports, pkg, t.Elem())
 	case reflect.Map:
-		return "map[" + typeName(pkg, t.Key()) + "]" + typeName(pkg, t.Elem())
+		return "map[" + typeName(imports, pkg, t.Key()) + "]" + typeName(imports, pkg, t.Elem())
 	default:
 		pkgPath := t.PkgPath()
 		if pkgPath == "" {
@@ -113,16 +276,16 @@ func typeName(pkg string, t reflect.Type) string {
 		} else if pkgPath == pkg {
 			return t.Name()
 		}
-		return fmt.Sprintf("%s.%s", resolvePkgName(pkgPath, t.String()), t.Name())
+		return fmt.Sprintf("%s.%s", imports.resolvePkgName(t), t.Name())
 	}
 }
 
 func (f Field) TypeName() string {
-	return typeName(f.Pkg, f.Type)
+	return typeName(f.imports, f.Pkg, f.Type)
 }
 
 func (f Field) ElemName() string {
-	return typeName(f.Pkg, f.Type.Elem())
+	return typeName(f.imports, f.Pkg, f.Type.Elem())
 }
 
 func (f Field) IsArray() bool {
@@ -133,36 +296,96 @@ func (f Field) Len() int {
 	return f.Type.Len()
 }
 
+// needsImport reports whether the generated code refers to the field's type
+// by name, and so needs to import its package.
+func (f Field) needsImport() bool {
+	switch f.Type.Kind() {
+	case reflect.Struct:
+		if !f.Pointer && f.Type != bigIntType {
+			return false
+		}
+		if f.Type == cidType {
+			return false
+		}
+	case reflect.Bool:
+		return false
+	}
+	return true
+}
+
 type GenTypeInfo struct {
 	Name   string
 	Fields []Field
+
+	imports *importSet
 }
 
 func (gti *GenTypeInfo) Imports() []Import {
 	var imports []Import
 	for _, f := range gti.Fields {
-		switch f.Type.Kind() {
-		case reflect.Struct:
-			if !f.Pointer && f.Type != bigIntType {
-				continue
-			}
-			if f.Type == cidType {
-				continue
-			}
-		case reflect.Bool:
+		if !f.needsImport() {
 			continue
 		}
-		imports = append(imports, ImportsForType(f.Pkg, f.Type)...)
+		imports = append(imports, gti.imports.importsForType(f.Pkg, f.Type)...)
 	}
 	return imports
 }
 
+// setImports makes the type's generated code use the package names in s.
+func (gti *GenTypeInfo) setImports(s *importSet) {
+	gti.imports = s
+	for i := range gti.Fields {
+		gti.Fields[i].imports = s
+	}
+}
+
+// ensureImports gives the type its own import set if it wasn't generated as
+// part of a file.
+func (gti *GenTypeInfo) ensureImports() {
+	if gti.imports == nil {
+		gti.setImports(newImportSet([]*GenTypeInfo{gti}, nil))
+	}
+}
+
+// canonicalLess orders map keys the way RFC 7049 canonical CBOR does: shorter
+// keys first, then bytewise.
+func canonicalLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// allocSize is the number of bytes a decoder allocates for each element of a
+// slice or map of type t.
+func allocSize(t reflect.Type) uint64 {
+	switch t.Kind() {
+	case reflect.Map:
+		return uint64(t.Key().Size() + t.Elem().Size())
+	default:
+		return uint64(t.Elem().Size())
+	}
+}
+
 func nameIsExported(name string) bool {
 	return strings.ToUpper(name[0:1]) == name[0:1]
 }
 
 func ParseTypeInfo(i interface{}) (*GenTypeInfo, error) {
+	gti, errs := parseTypeInfo(i)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return gti, nil
+}
+
+// parseTypeInfo parses as much of the type as it can, returning the fields it
+// could parse along with errors for those it couldn't.
+func parseTypeInfo(i interface{}) (*GenTypeInfo, GenErrors) {
 	t := reflect.TypeOf(i)
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, GenErrors{fmt.Errorf("can only generate encoders for structs, got %v", t)}
+	}
 
 	pkg := t.PkgPath()
 
@@ -170,6 +393,7 @@ func ParseTypeInfo(i interface{}) (*GenTypeInfo, error) {
 		Name: t.Name(),
 	}
 
+	var errs GenErrors
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
 		if !nameIsExported(f.Name) {
@@ -188,7 +412,8 @@ func ParseTypeInfo(i interface{}) (*GenTypeInfo, error) {
 		tagval := f.Tag.Get("cborgen")
 		tags, err := tagparse(tagval)
 		if err != nil {
-			return nil, fmt.Errorf("invalid tag format: %w", err)
+			errs = append(errs, &FieldError{Type: out.Name, Field: f.Name, Err: fmt.Errorf("invalid tag format: %w", err)})
+			continue
 		}
 
 		if tags["name"] != "" {
@@ -197,12 +422,19 @@ func ParseTypeInfo(i interface{}) (*GenTypeInfo, error) {
 		if msize := tags["maxlen"]; msize != "" {
 			val, err := strconv.Atoi(msize)
 			if err != nil {
-				return nil, fmt.Errorf("maxsize tag value was not valid: %w", err)
+				errs = append(errs, &FieldError{Type: out.Name, Field: f.Name, Err: fmt.Errorf("maxsize tag value was not valid: %w", err)})
+				continue
 			}
 
 			usrMaxLen = val
 		}
 
+		_, forEach := tags[ForEachTag]
+		if forEach && (ft.Kind() != reflect.Slice || ft.Elem().Kind() == reflect.Uint8 || pointer) {
+			errs = append(errs, &FieldError{Type: out.Name, Field: f.Name, Err: fmt.Errorf("%s is only supported on slices, not %s", ForEachTag, f.Type)})
+			continue
+		}
+
 		out.Fields = append(out.Fields, Field{
 			Name:    f.Name,
 			MapKey:  mapk,
@@ -210,15 +442,16 @@ func ParseTypeInfo(i interface{}) (*GenTypeInfo, error) {
 			Type:    ft,
 			Pkg:     pkg,
 			MaxLen:  usrMaxLen,
+			ForEach: forEach,
 		})
 	}
 
-	return &out, nil
+	return &out, errs
 }
 
 func tagparse(v string) (map[string]string, error) {
 	out := make(map[string]string)
-	for _, elem := range strings.Split(v, ",") {
+	for i, elem := range strings.Split(v, ",") {
 		elem = strings.TrimSpace(elem)
 		if elem == "" {
 			continue
@@ -231,6 +464,8 @@ func tagparse(v string) (map[string]string, error) {
 			}
 
 			out[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		} else if i > 0 && elem == ForEachTag {
+			out[ForEachTag] = ""
 		} else {
 			out["name"] = elem
 		}
@@ -268,13 +503,13 @@ func (gti GenTypeInfo) MapHeaderAsByteString() string {
 	return s
 }
 
-func emitCborMarshalStringField(w io.Writer, f Field) error {
+func (g GenOptions) emitCborMarshalStringField(w io.Writer, f Field) error {
 	if f.Pointer {
 		return fmt.Errorf("pointers to strings not supported")
 	}
 
-	return doTemplate(w, f, `
-	if len({{ .Name }}) > {{ MaxLen .MaxLen "cbg.MaxLength" }} {
+	return g.doTemplate(w, f, `
+	if len({{ .Name }}) > {{ MaxLen .MaxLen }} {
 		return xerrors.Errorf("Value in field {{ .Name | js }} was too long")
 	}
 
@@ -285,10 +520,10 @@ func emitCborMarshalStringField(w io.Writer, f Field) error {
 `)
 }
 
-func emitCborMarshalStructField(w io.Writer, f Field) error {
+func (g GenOptions) emitCborMarshalStructField(w io.Writer, f Field) error {
 	switch f.Type {
 	case bigIntType:
-		return doTemplate(w, f, `
+		return g.doTemplate(w, f, `
 	{
 		if err := cw.CborWriteHeader(cbg.MajTag, 2); err != nil {
 			return err
@@ -308,7 +543,7 @@ func emitCborMarshalStructField(w io.Writer, f Field) error {
 `)
 
 	case cidType:
-		return doTemplate(w, f, `
+		return g.doTemplate(w, f, `
 {{ if .Pointer }}
 	if {{ .Name }} == nil {
 		if _, err := cw.Write(cbg.CborNull); err != nil {
@@ -326,7 +561,7 @@ func emitCborMarshalStructField(w io.Writer, f Field) error {
 {{ end }}
 `)
 	default:
-		return doTemplate(w, f, `
+		return g.doTemplate(w, f, `
 	if err := {{ .Name }}.MarshalCBOR(cw); err != nil {
 		return err
 	}
@@ -334,8 +569,8 @@ func emitCborMarshalStructField(w io.Writer, f Field) error {
 	}
 }
 
-func emitCborMarshalUint64Field(w io.Writer, f Field) error {
-	return doTemplate(w, f, `
+func (g GenOptions) emitCborMarshalUint64Field(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
 {{ if .Pointer }}
 	if {{ .Name }} == nil {
 		if _, err := cw.Write(cbg.CborNull); err != nil {
@@ -350,16 +585,16 @@ func emitCborMarshalUint64Field(w io.Writer, f Field) error {
 `)
 }
 
-func emitCborMarshalUint8Field(w io.Writer, f Field) error {
+func (g GenOptions) emitCborMarshalUint8Field(w io.Writer, f Field) error {
 	if f.Pointer {
 		return fmt.Errorf("pointers to integers not supported")
 	}
-	return doTemplate(w, f, `
+	return g.doTemplate(w, f, `
 {{ MajorType "cw" "cbg.MajUnsignedInt" .Name }}
 `)
 }
 
-func emitCborMarshalInt64Field(w io.Writer, f Field) error {
+func (g GenOptions) emitCborMarshalInt64Field(w io.Writer, f Field) error {
 	if f.Pointer {
 		return fmt.Errorf("pointers to integers not supported")
 	}
@@ -368,7 +603,7 @@ func emitCborMarshalInt64Field(w io.Writer, f Field) error {
 	// val = -1 - cbor
 	// cbor = -val -1
 
-	return doTemplate(w, f, `
+	return g.doTemplate(w, f, `
 	if {{ .Name }} >= 0 {
 	{{ MajorType "cw" "cbg.MajUnsignedInt" .Name }}
 	} else {
@@ -377,18 +612,34 @@ func emitCborMarshalInt64Field(w io.Writer, f Field) error {
 `)
 }
 
-func emitCborMarshalBoolField(w io.Writer, f Field) error {
-	return doTemplate(w, f, `
+func (g GenOptions) emitCborMarshalBoolField(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
 	if err := cbg.WriteBool(w, {{ .Name }}); err != nil {
 		return err
 	}
 `)
 }
 
-func emitCborMarshalMapField(w io.Writer, f Field) error {
-	err := doTemplate(w, f, `
+// emitSortMapKeys emits the code to sort the keys of a map field before
+// writing them out.
+func (g GenOptions) emitSortMapKeys(w io.Writer, f Field) error {
+	if g.CanonicalOrder {
+		return g.doTemplate(w, f, `	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+`)
+	}
+	return g.doTemplate(w, f, `	sort.Strings(keys)
+`)
+}
+
+func (g GenOptions) emitCborMarshalMapField(w io.Writer, f Field) error {
+	err := g.doTemplate(w, f, `
 {
-	if len({{ .Name }}) > 4096 {
+	if len({{ .Name }}) > {{ MaxMapLen }} {
 		return xerrors.Errorf("cannot marshal {{ .Name }} map too large")
 	}
 
@@ -398,8 +649,16 @@ func emitCborMarshalMapField(w io.Writer, f Field) error {
 	for k := range {{ .Name }} {
 		keys = append(keys, k)
 	}
-	sort.Strings(keys)
-	for _, k := range keys {
+`)
+	if err != nil {
+		return err
+	}
+
+	if err := g.emitSortMapKeys(w, f); err != nil {
+		return err
+	}
+
+	err = g.doTemplate(w, f, `	for _, k := range keys {
 		v := {{ .Name }}[k]
 
 `)
@@ -410,7 +669,7 @@ func emitCborMarshalMapField(w io.Writer, f Field) error {
 	// Map key
 	switch f.Type.Key().Kind() {
 	case reflect.String:
-		if err := emitCborMarshalStringField(w, Field{Name: "k"}); err != nil {
+		if err := g.emitCborMarshalStringField(w, Field{Name: "k"}); err != nil {
 			return err
 		}
 	default:
@@ -426,20 +685,20 @@ func emitCborMarshalMapField(w io.Writer, f Field) error {
 
 		fallthrough
 	case reflect.Struct:
-		if err := emitCborMarshalStructField(w, Field{Name: "v", Type: f.Type.Elem(), Pkg: f.Pkg}); err != nil {
+		if err := g.emitCborMarshalStructField(w, Field{Name: "v", Type: f.Type.Elem(), Pkg: f.Pkg, imports: f.imports}); err != nil {
 			return err
 		}
 	default:
 		return fmt.Errorf("currently unsupported map elem type: %s", f.Type.Elem())
 	}
 
-	return doTemplate(w, f, `
+	return g.doTemplate(w, f, `
 	}
 	}
 `)
 }
 
-func emitCborMarshalSliceField(w io.Writer, f Field) error {
+func (g GenOptions) emitCborMarshalSliceField(w io.Writer, f Field) error {
 	if f.Pointer {
 		return fmt.Errorf("pointers to slices not supported")
 	}
@@ -447,8 +706,8 @@ func emitCborMarshalSliceField(w io.Writer, f Field) error {
 
 	// Note: this re-slices the slice to deal with arrays.
 	if e.Kind() == reflect.Uint8 {
-		return doTemplate(w, f, `
-	if len({{ .Name }}) > {{ MaxLen .MaxLen "cbg.ByteArrayMaxLen" }} {
+		return g.doTemplate(w, f, `
+	if len({{ .Name }}) > {{ MaxByteLen .MaxLen }} {
 		return xerrors.Errorf("Byte array in field {{ .Name }} was too long")
 	}
 
@@ -464,8 +723,8 @@ func emitCborMarshalSliceField(w io.Writer, f Field) error {
 		e = e.Elem()
 	}
 
-	err := doTemplate(w, f, `
-	if len({{ .Name }}) > {{ MaxLen .MaxLen "cbg.MaxLength" }} {
+	err := g.doTemplate(w, f, `
+	if len({{ .Name }}) > {{ MaxLen .MaxLen }} {
 		return xerrors.Errorf("Slice value in field {{ .Name }} was too long")
 	}
 
@@ -481,7 +740,7 @@ func emitCborMarshalSliceField(w io.Writer, f Field) error {
 	case reflect.Struct:
 		switch e {
 		case cidType:
-			err := doTemplate(w, f, `
+			err := g.doTemplate(w, f, `
 		if err := cbg.WriteCid(w, v); err != nil {
 			return xerrors.Errorf("failed writing cid field {{ .Name }}: %w", err)
 		}
@@ -491,7 +750,7 @@ func emitCborMarshalSliceField(w io.Writer, f Field) error {
 			}
 
 		default:
-			err := doTemplate(w, f, `
+			err := g.doTemplate(w, f, `
 		if err := v.MarshalCBOR(cw); err != nil {
 			return err
 		}
@@ -501,7 +760,7 @@ func emitCborMarshalSliceField(w io.Writer, f Field) error {
 			}
 		}
 	case reflect.Uint64:
-		err := doTemplate(w, f, `
+		err := g.doTemplate(w, f, `
 		if err := cw.CborWriteHeader(cbg.MajUnsignedInt, uint64(v)); err != nil {
 			return err
 		}
@@ -510,7 +769,7 @@ func emitCborMarshalSliceField(w io.Writer, f Field) error {
 			return err
 		}
 	case reflect.Uint8:
-		err := doTemplate(w, f, `
+		err := g.doTemplate(w, f, `
 		if err := cw.CborWriteHeader(cbg.MajUnsignedInt, uint64(v)); err != nil {
 			return err
 		}
@@ -519,14 +778,14 @@ func emitCborMarshalSliceField(w io.Writer, f Field) error {
 			return err
 		}
 	case reflect.Int64:
-		subf := Field{Name: "v", Type: e, Pkg: f.Pkg}
-		if err := emitCborMarshalInt64Field(w, subf); err != nil {
+		subf := Field{Name: "v", Type: e, Pkg: f.Pkg, imports: f.imports}
+		if err := g.emitCborMarshalInt64Field(w, subf); err != nil {
 			return err
 		}
 
 	case reflect.Slice:
-		subf := Field{Name: "v", Type: e, Pkg: f.Pkg}
-		if err := emitCborMarshalSliceField(w, subf); err != nil {
+		subf := Field{Name: "v", Type: e, Pkg: f.Pkg, imports: f.imports}
+		if err := g.emitCborMarshalSliceField(w, subf); err != nil {
 			return err
 		}
 	}
@@ -536,10 +795,44 @@ func emitCborMarshalSliceField(w io.Writer, f Field) error {
 	return nil
 }
 
-func emitCborMarshalStructTuple(w io.Writer, gti *GenTypeInfo) error {
+// emitCborMarshalField emits the code to marshal a single field of any
+// supported kind.
+func (g GenOptions) emitCborMarshalField(w io.Writer, f Field) error {
+	switch f.Type.Kind() {
+	case reflect.String:
+		return g.emitCborMarshalStringField(w, f)
+	case reflect.Struct:
+		return g.emitCborMarshalStructField(w, f)
+	case reflect.Uint64:
+		return g.emitCborMarshalUint64Field(w, f)
+	case reflect.Uint8:
+		return g.emitCborMarshalUint8Field(w, f)
+	case reflect.Int64:
+		return g.emitCborMarshalInt64Field(w, f)
+	case reflect.Array, reflect.Slice:
+		return g.emitCborMarshalSliceField(w, f)
+	case reflect.Bool:
+		return g.emitCborMarshalBoolField(w, f)
+	case reflect.Map:
+		return g.emitCborMarshalMapField(w, f)
+	default:
+		return fmt.Errorf("unsupported kind %q", f.Type.Kind())
+	}
+}
+
+func (g GenOptions) emitCborMarshalStructTuple(w io.Writer, gti *GenTypeInfo) error {
 	// 9 byte buffer to accomodate for the maximum header length (cbor varints are maximum 9 bytes_
-	err := doTemplate(w, gti, `var lengthBuf{{ .Name }} = {{ .TupleHeaderAsByteString }}
-func (t *{{ .Name }}) MarshalCBOR(w io.Writer) error {
+	err := g.doTemplate(w, gti, `var lengthBuf{{ .Name }} = {{ .TupleHeaderAsByteString }}
+`)
+	if err != nil {
+		return err
+	}
+
+	if g.AppendEncoders {
+		return g.emitCborAppendStruct(w, gti, false)
+	}
+
+	err = g.doTemplate(w, gti, `func (t *{{ .Name }}) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
@@ -557,45 +850,11 @@ func (t *{{ .Name }}) MarshalCBOR(w io.Writer) error {
 
 	for _, f := range gti.Fields {
 		fmt.Fprintf(w, "\n\t// t.%s (%s) (%s)", f.Name, f.Type, f.Type.Kind())
+		fname := f.Name
 		f.Name = "t." + f.Name
 
-		switch f.Type.Kind() {
-		case reflect.String:
-			if err := emitCborMarshalStringField(w, f); err != nil {
-				return err
-			}
-		case reflect.Struct:
-			if err := emitCborMarshalStructField(w, f); err != nil {
-				return err
-			}
-		case reflect.Uint64:
-			if err := emitCborMarshalUint64Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Uint8:
-			if err := emitCborMarshalUint8Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Int64:
-			if err := emitCborMarshalInt64Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Array:
-			fallthrough
-		case reflect.Slice:
-			if err := emitCborMarshalSliceField(w, f); err != nil {
-				return err
-			}
-		case reflect.Bool:
-			if err := emitCborMarshalBoolField(w, f); err != nil {
-				return err
-			}
-		case reflect.Map:
-			if err := emitCborMarshalMapField(w, f); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("field %q of %q has unsupported kind %q", f.Name, gti.Name, f.Type.Kind())
+		if err := g.emitCborMarshalField(w, f); err != nil {
+			return &FieldError{Type: gti.Name, Field: fname, Err: err}
 		}
 	}
 
@@ -603,16 +862,16 @@ func (t *{{ .Name }}) MarshalCBOR(w io.Writer) error {
 	return nil
 }
 
-func emitCborUnmarshalStringField(w io.Writer, f Field) error {
+func (g GenOptions) emitCborUnmarshalStringField(w io.Writer, f Field) error {
 	if f.Pointer {
 		return fmt.Errorf("pointers to strings not supported")
 	}
 	if f.Type == nil {
 		f.Type = reflect.TypeOf("")
 	}
-	return doTemplate(w, f, `
+	return g.doTemplate(w, f, `
 	{
-		sval, err := cbg.ReadString(cr)
+		sval, err := cbg.ReadStringWithMax(cr, {{ MaxLen .MaxLen }})
 		if err != nil {
 			return err
 		}
@@ -622,17 +881,20 @@ func emitCborUnmarshalStringField(w io.Writer, f Field) error {
 `)
 }
 
-func emitCborUnmarshalStructField(w io.Writer, f Field) error {
+func (g GenOptions) emitCborUnmarshalStructField(w io.Writer, f Field) error {
 	switch f.Type {
 	case bigIntType:
-		return doTemplate(w, f, `
+		return g.doTemplate(w, f, `
 	maj, extra, err = {{ ReadHeader "cr" }}
 	if err != nil {
 		return err
 	}
 
-	if maj != cbg.MajTag || extra != 2 {
-		return fmt.Errorf("big ints should be cbor bignums")
+	if maj != cbg.MajTag {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajTag, Got: maj}
+	}
+	if extra != 2 {
+		return &cbg.ErrUnexpectedTag{Want: 2, Got: extra}
 	}
 
 	maj, extra, err = {{ ReadHeader "cr" }}
@@ -641,11 +903,11 @@ func emitCborUnmarshalStructField(w io.Writer, f Field) error {
 	}
 
 	if maj != cbg.MajByteString {
-		return fmt.Errorf("big ints should be tagged cbor byte strings")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
 	}
 
 	if extra > 256 {
-		return fmt.Errorf("{{ .Name }}: cbor bignum was too large")
+		return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: extra, Limit: 256})
 	}
 
 	if extra > 0 {
@@ -659,7 +921,7 @@ func emitCborUnmarshalStructField(w io.Writer, f Field) error {
 	}
 `)
 	case cidType:
-		return doTemplate(w, f, `
+		return g.doTemplate(w, f, `
 	{
 {{ if .Pointer }}
 		b, err := cr.ReadByte()
@@ -684,7 +946,7 @@ func emitCborUnmarshalStructField(w io.Writer, f Field) error {
 	}
 `)
 	case deferredType:
-		return doTemplate(w, f, `
+		return g.doTemplate(w, f, `
 	{
 {{ if .Pointer }}
 		{{ .Name }} = new(cbg.Deferred)
@@ -696,7 +958,7 @@ func emitCborUnmarshalStructField(w io.Writer, f Field) error {
 `)
 
 	default:
-		return doTemplate(w, f, `
+		return g.doTemplate(w, f, `
 	{
 {{ if .Pointer }}
 		b, err := cr.ReadByte()
@@ -722,8 +984,8 @@ func emitCborUnmarshalStructField(w io.Writer, f Field) error {
 	}
 }
 
-func emitCborUnmarshalInt64Field(w io.Writer, f Field) error {
-	return doTemplate(w, f, `{
+func (g GenOptions) emitCborUnmarshalInt64Field(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `{
 	maj, extra, err := {{ ReadHeader "cr" }}
 	var extraI int64
 	if err != nil {
@@ -733,16 +995,16 @@ func emitCborUnmarshalInt64Field(w io.Writer, f Field) error {
 	case cbg.MajUnsignedInt:
 		extraI = int64(extra)
 		if extraI < 0 {
-			return fmt.Errorf("int64 positive overflow")
+			return &cbg.ErrIntOverflow{Type: "int64", Value: extra}
 	   }
 	case cbg.MajNegativeInt:
 		extraI = int64(extra)
 		if extraI < 0 {
-			return fmt.Errorf("int64 negative oveflow")
+			return &cbg.ErrIntOverflow{Type: "int64", Value: extra, Negative: true}
 		}
 		extraI = -1 - extraI
 	default:
-		return fmt.Errorf("wrong type for int64 field: %d", maj)
+		return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 	}
 
 	{{ .Name }} = {{ .TypeName }}(extraI)
@@ -750,8 +1012,8 @@ func emitCborUnmarshalInt64Field(w io.Writer, f Field) error {
 `)
 }
 
-func emitCborUnmarshalUint64Field(w io.Writer, f Field) error {
-	return doTemplate(w, f, `
+func (g GenOptions) emitCborUnmarshalUint64Field(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
 	{
 {{ if .Pointer }}
 	b, err := cr.ReadByte()
@@ -767,7 +1029,7 @@ func emitCborUnmarshalUint64Field(w io.Writer, f Field) error {
 			return err
 		}
 		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
+			return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 		}
 		typed := {{ .TypeName }}(extra)
 		{{ .Name }} = &typed
@@ -778,7 +1040,7 @@ func emitCborUnmarshalUint64Field(w io.Writer, f Field) error {
 		return err
 	}
 	if maj != cbg.MajUnsignedInt {
-		return fmt.Errorf("wrong type for uint64 field")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 	}
 	{{ .Name }} = {{ .TypeName }}(extra)
 {{ end }}
@@ -786,30 +1048,30 @@ func emitCborUnmarshalUint64Field(w io.Writer, f Field) error {
 `)
 }
 
-func emitCborUnmarshalUint8Field(w io.Writer, f Field) error {
-	return doTemplate(w, f, `
+func (g GenOptions) emitCborUnmarshalUint8Field(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
 	maj, extra, err = {{ ReadHeader "cr" }}
 	if err != nil {
 		return err
 	}
 	if maj != cbg.MajUnsignedInt {
-		return fmt.Errorf("wrong type for uint8 field")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 	}
 	if extra > math.MaxUint8 {
-		return fmt.Errorf("integer in input was too large for uint8 field")
+		return &cbg.ErrIntOverflow{Type: "uint8", Value: extra}
 	}
 	{{ .Name }} = {{ .TypeName }}(extra)
 `)
 }
 
-func emitCborUnmarshalBoolField(w io.Writer, f Field) error {
-	return doTemplate(w, f, `
+func (g GenOptions) emitCborUnmarshalBoolField(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
 	maj, extra, err = {{ ReadHeader "cr" }}
 	if err != nil {
 		return err
 	}
 	if maj != cbg.MajOther {
-		return fmt.Errorf("booleans must be major type 7")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajOther, Got: maj}
 	}
 	switch extra {
 	case 20:
@@ -822,19 +1084,24 @@ func emitCborUnmarshalBoolField(w io.Writer, f Field) error {
 `)
 }
 
-func emitCborUnmarshalMapField(w io.Writer, f Field) error {
-	err := doTemplate(w, f, `
+func (g GenOptions) emitCborUnmarshalMapField(w io.Writer, f Field) error {
+	err := g.doTemplate(w, f, `
 	maj, extra, err = {{ ReadHeader "cr" }}
 	if err != nil {
 		return err
 	}
 	if maj != cbg.MajMap {
-		return fmt.Errorf("expected a map (major type 5)")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 	}
-	if extra > 4096 {
-		return fmt.Errorf("{{ .Name }}: map too large")
+	if extra > {{ MaxMapLen }} {
+		return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: extra, Limit: {{ MaxMapLen }}})
 	}
 
+	{{ if DecodeLimits }}
+	if err := cr.Allocate(extra * {{ AllocSize .Type }}); err != nil {
+		return err
+	}
+	{{ end }}
 	{{ .Name }} = make({{ .TypeName }}, extra)
 
 
@@ -843,17 +1110,23 @@ func emitCborUnmarshalMapField(w io.Writer, f Field) error {
 	if err != nil {
 		return err
 	}
+	if g.ErrorContext {
+		fmt.Fprintf(w, "\t\tpos.HasKey = false\n")
+	}
 
 	switch f.Type.Key().Kind() {
 	case reflect.String:
-		if err := doTemplate(w, f, `
+		if err := g.doTemplate(w, f, `
 	var k string
 `); err != nil {
 			return err
 		}
-		if err := emitCborUnmarshalStringField(w, Field{Name: "k"}); err != nil {
+		if err := g.emitCborUnmarshalStringField(w, Field{Name: "k"}); err != nil {
 			return err
 		}
+		if g.ErrorContext {
+			fmt.Fprintf(w, "\t\tpos.Key = k\n\t\tpos.HasKey = true\n")
+		}
 	default:
 		return fmt.Errorf("maps with non-string keys are not yet supported")
 	}
@@ -869,8 +1142,8 @@ func emitCborUnmarshalMapField(w io.Writer, f Field) error {
 		pointer = true
 		fallthrough
 	case reflect.Struct:
-		subf := Field{Name: "v", Pointer: pointer, Type: t, Pkg: f.Pkg}
-		if err := doTemplate(w, subf, `
+		subf := Field{Name: "v", Pointer: pointer, Type: t, Pkg: f.Pkg, imports: f.imports}
+		if err := g.doTemplate(w, subf, `
 	var v {{ .TypeName }}
 `); err != nil {
 			return err
@@ -879,10 +1152,10 @@ func emitCborUnmarshalMapField(w io.Writer, f Field) error {
 		if pointer {
 			subf.Type = subf.Type.Elem()
 		}
-		if err := emitCborUnmarshalStructField(w, subf); err != nil {
+		if err := g.emitCborUnmarshalStructField(w, subf); err != nil {
 			return err
 		}
-		if err := doTemplate(w, f, `
+		if err := g.doTemplate(w, f, `
 	{{ .Name }}[k] = v
 `); err != nil {
 			return err
@@ -891,12 +1164,12 @@ func emitCborUnmarshalMapField(w io.Writer, f Field) error {
 		return fmt.Errorf("currently only support maps of structs")
 	}
 
-	return doTemplate(w, f, `
+	return g.doTemplate(w, f, `
 	}
 `)
 }
 
-func emitCborUnmarshalSliceField(w io.Writer, f Field) error {
+func (g GenOptions) emitCborUnmarshalSliceField(w io.Writer, f Field) error {
 	if f.IterLabel == "" {
 		f.IterLabel = "i"
 	}
@@ -908,7 +1181,7 @@ func emitCborUnmarshalSliceField(w io.Writer, f Field) error {
 		e = e.Elem()
 	}
 
-	err := doTemplate(w, f, `
+	err := g.doTemplate(w, f, `
 	maj, extra, err = {{ ReadHeader "cr" }}
 	if err != nil {
 		return err
@@ -919,50 +1192,69 @@ func emitCborUnmarshalSliceField(w io.Writer, f Field) error {
 	}
 
 	if e.Kind() == reflect.Uint8 {
-		return doTemplate(w, f, `
-	if extra > {{ MaxLen .MaxLen "cbg.ByteArrayMaxLen" }} {
-		return fmt.Errorf("{{ .Name }}: byte array too large (%d)", extra)
+		return g.doTemplate(w, f, `
+	if extra > {{ MaxByteLen .MaxLen }} {
+		return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: extra, Limit: {{ MaxByteLen .MaxLen }}})
 	}
 	if maj != cbg.MajByteString {
-		return fmt.Errorf("expected byte array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
 	}
 	{{if .IsArray}}
 	if extra != {{ .Len }} {
-		return fmt.Errorf("expected array to have {{ .Len }} elements")
+		return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrWrongLength{Want: {{ .Len }}, Got: extra})
 	}
 
 	{{ .Name }} = {{ .TypeName }}{}
+	{{else if ZeroCopyBytes}}
+	if extra > 0 {
+		{{ .Name }}, err = cr.ReadByteSlice(extra)
+		if err != nil {
+			return err
+		}
+	}
 	{{else}}
 	if extra > 0 {
+		{{- if DecodeLimits }}
+		if err := cr.Allocate(extra * {{ AllocSize .Type }}); err != nil {
+			return err
+		}
+		{{- end }}
 		{{ .Name }} = make({{ .TypeName }}, extra)
 	}
 	{{end}}
+	{{- if or .IsArray (not ZeroCopyBytes)}}
 	if _, err := io.ReadFull(cr, {{ .Name }}[:]); err != nil {
 		return err
 	}
+	{{- end}}
 `)
 	}
 
-	if err := doTemplate(w, f, `
-	if extra > {{ MaxLen .MaxLen "cbg.MaxLength" }} {
-		return fmt.Errorf("{{ .Name }}: array too large (%d)", extra)
+	if err := g.doTemplate(w, f, `
+	if extra > {{ MaxLen .MaxLen }} {
+		return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: extra, Limit: {{ MaxLen .MaxLen }}})
 	}
 `); err != nil {
 		return err
 	}
 
-	err = doTemplate(w, f, `
+	err = g.doTemplate(w, f, `
 	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 	{{if .IsArray}}
 	if extra != {{ .Len }} {
-		return fmt.Errorf("expected array to have {{ .Len }} elements")
+		return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrWrongLength{Want: {{ .Len }}, Got: extra})
 	}
 
 	{{ .Name }} = {{ .TypeName }}{}
 	{{else}}
 	if extra > 0 {
+		{{- if DecodeLimits }}
+		if err := cr.Allocate(extra * {{ AllocSize .Type }}); err != nil {
+			return err
+		}
+		{{- end }}
 		{{ .Name }} = make({{ .TypeName }}, extra)
 	}
 	{{end}}
@@ -971,13 +1263,14 @@ func emitCborUnmarshalSliceField(w io.Writer, f Field) error {
 	if err != nil {
 		return err
 	}
+	g.emitErrorIndex(w, int(f.IterLabel[0]-'i'), f.IterLabel)
 
 	switch e.Kind() {
 	case reflect.Struct:
 		fname := e.PkgPath() + "." + e.Name()
 		switch fname {
 		case "github.com/ipfs/go-cid.Cid":
-			err := doTemplate(w, f, `
+			err := g.doTemplate(w, f, `
 		c, err := cbg.ReadCid(cr)
 		if err != nil {
 			return xerrors.Errorf("reading cid field {{ .Name }} failed: %w", err)
@@ -993,9 +1286,10 @@ func emitCborUnmarshalSliceField(w io.Writer, f Field) error {
 				Pkg:     f.Pkg,
 				Pointer: pointer,
 				Name:    f.Name + "[" + f.IterLabel + "]",
+				imports: f.imports,
 			}
 
-			err := doTemplate(w, subf, `
+			err := g.doTemplate(w, subf, `
 		var v {{ .TypeName }}
 		if err := v.UnmarshalCBOR(cr); err != nil {
 			return err
@@ -1008,14 +1302,14 @@ func emitCborUnmarshalSliceField(w io.Writer, f Field) error {
 			}
 		}
 	case reflect.Uint64:
-		err := doTemplate(w, f, `
+		err := g.doTemplate(w, f, `
 		maj, val, err := {{ ReadHeader "cr" }}
 		if err != nil {
 			return xerrors.Errorf("failed to read uint64 for {{ .Name }} slice: %w", err)
 		}
 
 		if maj != cbg.MajUnsignedInt {
-			return xerrors.Errorf("value read for array {{ .Name }} was not a uint, instead got %d", maj)
+			return xerrors.Errorf("value read for array {{ .Name }}: %w", &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj})
 		}
 		
 		{{ .Name }}[{{ .IterLabel}}] = {{ .ElemName }}(val)
@@ -1025,11 +1319,12 @@ func emitCborUnmarshalSliceField(w io.Writer, f Field) error {
 		}
 	case reflect.Int64:
 		subf := Field{
-			Type: e,
-			Pkg:  f.Pkg,
-			Name: f.Name + "[" + f.IterLabel + "]",
+			Type:    e,
+			Pkg:     f.Pkg,
+			Name:    f.Name + "[" + f.IterLabel + "]",
+			imports: f.imports,
 		}
-		err := emitCborUnmarshalInt64Field(w, subf)
+		err := g.emitCborUnmarshalInt64Field(w, subf)
 		if err != nil {
 			return err
 		}
@@ -1042,27 +1337,86 @@ func emitCborUnmarshalSliceField(w io.Writer, f Field) error {
 			Type:      e,
 			IterLabel: nextIter,
 			Pkg:       f.Pkg,
+			imports:   f.imports,
 		}
 		fmt.Fprintf(w, "\t\t{\n\t\t\tvar maj byte\n\t\tvar extra uint64\n\t\tvar err error\n")
-		if err := emitCborUnmarshalSliceField(w, subf); err != nil {
+		if err := g.emitCborUnmarshalSliceField(w, subf); err != nil {
 			return err
 		}
 		fmt.Fprintf(w, "\t\t}\n")
 
 	default:
-		return fmt.Errorf("do not yet support slices of %s yet", e.Elem())
+		return fmt.Errorf("do not yet support slices of %s yet", e.Kind())
 	}
 	fmt.Fprintf(w, "\t}\n\n")
 
 	return nil
 }
 
-func emitCborUnmarshalStructTuple(w io.Writer, gti *GenTypeInfo) error {
-	err := doTemplate(w, gti, `
-func (t *{{ .Name}}) UnmarshalCBOR(r io.Reader) (err error) {
+// emitErrorIndex emits code that records the index of the array element being
+// decoded at nesting level n for error messages. Levels beyond those FieldPos
+// holds are not recorded.
+func (g GenOptions) emitErrorIndex(w io.Writer, n int, index string) {
+	if !g.ErrorContext || n >= len(FieldPos{}.Index) {
+		return
+	}
+	fmt.Fprintf(w, "\t\tpos.Index[%d] = %s\n\t\tpos.Depth = %d\n", n, index, n+1)
+}
+
+// emitCborUnmarshalField emits the code to unmarshal a single field of any
+// supported kind.
+func (g GenOptions) emitCborUnmarshalField(w io.Writer, f Field) error {
+	switch f.Type.Kind() {
+	case reflect.String:
+		return g.emitCborUnmarshalStringField(w, f)
+	case reflect.Struct:
+		return g.emitCborUnmarshalStructField(w, f)
+	case reflect.Uint64:
+		return g.emitCborUnmarshalUint64Field(w, f)
+	case reflect.Uint8:
+		return g.emitCborUnmarshalUint8Field(w, f)
+	case reflect.Int64:
+		return g.emitCborUnmarshalInt64Field(w, f)
+	case reflect.Array, reflect.Slice:
+		return g.emitCborUnmarshalSliceField(w, f)
+	case reflect.Bool:
+		return g.emitCborUnmarshalBoolField(w, f)
+	case reflect.Map:
+		return g.emitCborUnmarshalMapField(w, f)
+	default:
+		return fmt.Errorf("unsupported kind %q", f.Type.Kind())
+	}
+}
+
+func (g GenOptions) emitCborUnmarshalStructTuple(w io.Writer, gti *GenTypeInfo) error {
+	return g.emitCborUnmarshalStructTupleFunc(w, gti, nil)
+}
+
+// emitCborUnmarshalStructTupleFunc emits UnmarshalCBOR, or the ForEach method
+// of forEach if it is set.
+func (g GenOptions) emitCborUnmarshalStructTupleFunc(w io.Writer, gti *GenTypeInfo, forEach *Field) error {
+	if err := g.emitCborUnmarshalFuncHeader(w, gti, forEach); err != nil {
+		return err
+	}
+
+	err := g.doTemplate(w, gti, `
 	*t = {{.Name}}{}
 
 	cr := cbg.NewCborReader(r)
+	{{- if ErrorContext }}
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "{{ .Name }}", pos, cr)
+		}
+	}()
+	{{- end }}
+	{{- if DecodeLimits }}
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+	{{- end }}
 
 	maj, extra, err := {{ ReadHeader "cr" }}
 	if err != nil {
@@ -1075,11 +1429,11 @@ func (t *{{ .Name}}) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra != {{ len .Fields }} {
-		return fmt.Errorf("cbor input had wrong number of fields")
+		return &cbg.ErrWrongLength{Want: {{ len .Fields }}, Got: extra}
 	}
 
 `)
@@ -1089,68 +1443,210 @@ func (t *{{ .Name}}) UnmarshalCBOR(r io.Reader) (err error) {
 
 	for _, f := range gti.Fields {
 		fmt.Fprintf(w, "\t// t.%s (%s) (%s)\n", f.Name, f.Type, f.Type.Kind())
-		f.Name = "t." + f.Name
+		if err := g.emitCborUnmarshalTypeField(w, gti, f, forEach); err != nil {
+			return err
+		}
+	}
 
-		switch f.Type.Kind() {
-		case reflect.String:
-			if err := emitCborUnmarshalStringField(w, f); err != nil {
-				return err
-			}
-		case reflect.Struct:
-			if err := emitCborUnmarshalStructField(w, f); err != nil {
-				return err
-			}
-		case reflect.Uint64:
-			if err := emitCborUnmarshalUint64Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Uint8:
-			if err := emitCborUnmarshalUint8Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Int64:
-			if err := emitCborUnmarshalInt64Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Array:
-			fallthrough
-		case reflect.Slice:
-			if err := emitCborUnmarshalSliceField(w, f); err != nil {
-				return err
-			}
-		case reflect.Bool:
-			if err := emitCborUnmarshalBoolField(w, f); err != nil {
-				return err
-			}
-		case reflect.Map:
-			if err := emitCborUnmarshalMapField(w, f); err != nil {
+	fmt.Fprintf(w, "\treturn nil\n}\n\n")
+
+	return nil
+}
+
+func (g GenOptions) emitCborUnmarshalBytes(w io.Writer, gti *GenTypeInfo) error {
+	return g.doTemplate(w, gti, `
+func (t *{{ .Name }}) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+`)
+}
+
+// emitCborUnmarshalFuncHeader emits the signature of UnmarshalCBOR, or of the
+// ForEach method of forEach if it is set.
+func (g GenOptions) emitCborUnmarshalFuncHeader(w io.Writer, gti *GenTypeInfo, forEach *Field) error {
+	if forEach == nil {
+		return g.doTemplate(w, gti, `
+func (t *{{ .Name}}) UnmarshalCBOR(r io.Reader) (err error) {`)
+	}
+
+	return g.doTemplate(w, struct {
+		Type  string
+		Field Field
+	}{gti.Name, *forEach}, `
+// ForEach{{ .Field.Name }} decodes t from r like UnmarshalCBOR, but instead of
+// collecting the elements of {{ .Field.Name }} it calls fn with each of them in
+// turn, leaving the field empty. The number of elements is not limited.
+func (t *{{ .Type }}) ForEach{{ .Field.Name }}(r io.Reader, fn func({{ .Field.ElemName }}) error) (err error) {`)
+}
+
+// emitCborUnmarshalTypeField emits the decoding of a field of gti, streaming it
+// if it is the forEach field.
+func (g GenOptions) emitCborUnmarshalTypeField(w io.Writer, gti *GenTypeInfo, f Field, forEach *Field) error {
+	fname := f.Name
+	f.Name = "t." + f.Name
+
+	emit := g.emitCborUnmarshalField
+	if forEach != nil && fname == forEach.Name {
+		emit = g.emitCborForEachField
+	}
+
+	if g.ErrorContext {
+		fmt.Fprintf(w, "\tpos = cbg.FieldPos{Field: %q}\n", fname)
+	}
+
+	if err := emit(w, f); err != nil {
+		return &FieldError{Type: gti.Name, Field: fname, Err: err}
+	}
+	return nil
+}
+
+// emitCborForEachField emits code that decodes the elements of a slice field
+// one at a time, passing each to fn.
+func (g GenOptions) emitCborForEachField(w io.Writer, f Field) error {
+	e := f.Type.Elem()
+	var pointer bool
+	if e.Kind() == reflect.Ptr {
+		pointer = true
+		e = e.Elem()
+	}
+
+	err := g.doTemplate(w, f, `
+	{
+		length, err := cr.ReadArrayHeader()
+		if err != nil {
+			return err
+		}
+
+		for i := uint64(0); i < length; i++ {
+			var v {{ .ElemName }}
+`)
+	if err != nil {
+		return err
+	}
+	g.emitErrorIndex(w, 0, "int(i)")
+
+	// Slices nested in the elements are indexed from the second level on.
+	subf := Field{
+		Name:      "v",
+		Type:      e,
+		Pkg:       f.Pkg,
+		Pointer:   pointer,
+		IterLabel: "j",
+		imports:   f.imports,
+	}
+	if err := g.emitCborUnmarshalField(w, subf); err != nil {
+		return err
+	}
+
+	return g.doTemplate(w, f, `
+			if err := fn(v); err != nil {
 				return err
 			}
-		default:
-			return fmt.Errorf("field %q of %q has unsupported kind %q", f.Name, gti.Name, f.Type.Kind())
 		}
 	}
 
-	fmt.Fprintf(w, "\treturn nil\n}\n\n")
+`)
+}
 
+// emitCborForEachMethods emits a ForEach method for each field of gti that
+// has the foreach tag.
+func (g GenOptions) emitCborForEachMethods(w io.Writer, gti *GenTypeInfo, mapRepr bool) error {
+	for i := range gti.Fields {
+		if !gti.Fields[i].ForEach {
+			continue
+		}
+
+		var err error
+		if mapRepr {
+			err = g.emitCborUnmarshalStructMapFunc(w, gti, &gti.Fields[i])
+		} else {
+			err = g.emitCborUnmarshalStructTupleFunc(w, gti, &gti.Fields[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // Generates 'tuple representation' cbor encoders for the given type
 func GenTupleEncodersForType(gti *GenTypeInfo, w io.Writer) error {
-	if err := emitCborMarshalStructTuple(w, gti); err != nil {
+	return GenOptions{}.GenTupleEncodersForType(gti, w)
+}
+
+// Generates 'tuple representation' cbor encoders for the given type
+func (g GenOptions) GenTupleEncodersForType(gti *GenTypeInfo, w io.Writer) error {
+	if errs := g.checkTypeInfos([]*GenTypeInfo{gti}); len(errs) > 0 {
+		return errs
+	}
+
+	gti.ensureImports()
+
+	if err := g.emitCborMarshalStructTuple(w, gti); err != nil {
+		return err
+	}
+
+	if err := g.emitCborUnmarshalStructTuple(w, gti); err != nil {
 		return err
 	}
 
-	if err := emitCborUnmarshalStructTuple(w, gti); err != nil {
+	if err := g.emitCborForEachMethods(w, gti, false); err != nil {
 		return err
 	}
 
+	if g.SizeMethods {
+		if err := g.emitCborSizeStruct(w, gti, false); err != nil {
+			return err
+		}
+	}
+
+	if g.BytesDecoders {
+		if err := g.emitCborUnmarshalBytes(w, gti); err != nil {
+			return err
+		}
+	}
+
+	if g.LinkWalkers {
+		if err := g.emitCborLinksStruct(w, gti); err != nil {
+			return err
+		}
+	}
+
+	if g.JSONCodecs {
+		if err := g.emitJSONStruct(w, gti); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func emitCborMarshalStructMap(w io.Writer, gti *GenTypeInfo) error {
-	err := doTemplate(w, gti, `func (t *{{ .Name }}) MarshalCBOR(w io.Writer) error {
+// mapFieldOrder returns the fields of a map encoded struct in the order they
+// are written in.
+func (g GenOptions) mapFieldOrder(gti *GenTypeInfo) []Field {
+	if !g.CanonicalOrder {
+		return gti.Fields
+	}
+
+	fields := make([]Field, len(gti.Fields))
+	copy(fields, gti.Fields)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return canonicalLess(fields[i].MapKey, fields[j].MapKey)
+	})
+	return fields
+}
+
+func (g GenOptions) emitCborMarshalStructMap(w io.Writer, gti *GenTypeInfo) error {
+	if g.AppendEncoders {
+		return g.emitCborAppendStruct(w, gti, true)
+	}
+
+	err := g.doTemplate(w, gti, `func (t *{{ .Name }}) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
@@ -1166,54 +1662,20 @@ func emitCborMarshalStructMap(w io.Writer, gti *GenTypeInfo) error {
 		return err
 	}
 
-	for _, f := range gti.Fields {
+	for _, f := range g.mapFieldOrder(gti) {
 		fmt.Fprintf(w, "\n\t// t.%s (%s) (%s)", f.Name, f.Type, f.Type.Kind())
 
-		if err := emitCborMarshalStringField(w, Field{
+		if err := g.emitCborMarshalStringField(w, Field{
 			Name: `"` + f.MapKey + `"`,
 		}); err != nil {
 			return err
 		}
 
+		fname := f.Name
 		f.Name = "t." + f.Name
 
-		switch f.Type.Kind() {
-		case reflect.String:
-			if err := emitCborMarshalStringField(w, f); err != nil {
-				return err
-			}
-		case reflect.Struct:
-			if err := emitCborMarshalStructField(w, f); err != nil {
-				return err
-			}
-		case reflect.Uint64:
-			if err := emitCborMarshalUint64Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Int64:
-			if err := emitCborMarshalInt64Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Uint8:
-			if err := emitCborMarshalUint8Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Array:
-			fallthrough
-		case reflect.Slice:
-			if err := emitCborMarshalSliceField(w, f); err != nil {
-				return err
-			}
-		case reflect.Bool:
-			if err := emitCborMarshalBoolField(w, f); err != nil {
-				return err
-			}
-		case reflect.Map:
-			if err := emitCborMarshalMapField(w, f); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("field %q of %q has unsupported kind %q", f.Name, gti.Name, f.Type.Kind())
+		if err := g.emitCborMarshalField(w, f); err != nil {
+			return &FieldError{Type: gti.Name, Field: fname, Err: err}
 		}
 	}
 
@@ -1221,12 +1683,35 @@ func emitCborMarshalStructMap(w io.Writer, gti *GenTypeInfo) error {
 	return nil
 }
 
-func emitCborUnmarshalStructMap(w io.Writer, gti *GenTypeInfo) error {
-	err := doTemplate(w, gti, `
-func (t *{{ .Name}}) UnmarshalCBOR(r io.Reader) (err error) {
+func (g GenOptions) emitCborUnmarshalStructMap(w io.Writer, gti *GenTypeInfo) error {
+	return g.emitCborUnmarshalStructMapFunc(w, gti, nil)
+}
+
+// emitCborUnmarshalStructMapFunc emits UnmarshalCBOR, or the ForEach method of
+// forEach if it is set.
+func (g GenOptions) emitCborUnmarshalStructMapFunc(w io.Writer, gti *GenTypeInfo, forEach *Field) error {
+	if err := g.emitCborUnmarshalFuncHeader(w, gti, forEach); err != nil {
+		return err
+	}
+
+	err := g.doTemplate(w, gti, `
 	*t = {{.Name}}{}
 
 	cr := cbg.NewCborReader(r)
+	{{- if ErrorContext }}
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "{{ .Name }}", pos, cr)
+		}
+	}()
+	{{- end }}
+	{{- if DecodeLimits }}
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+	{{- end }}
 
 	maj, extra, err := {{ ReadHeader "cr" }}
 	if err != nil {
@@ -1239,27 +1724,30 @@ func (t *{{ .Name}}) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajMap {
-		return fmt.Errorf("cbor input should be of type map")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 	}
 
-	if extra > cbg.MaxLength {
-		return fmt.Errorf("{{ .Name }}: map struct too large (%d)", extra)
+	if extra > {{ MaxLen 0 }} {
+		return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: extra, Limit: {{ MaxLen 0 }}})
 	}
 
 	var name string
 	n := extra
 
 	for i := uint64(0); i < n; i++ {
+		{{- if ErrorContext }}
+		pos = cbg.FieldPos{}
+		{{- end }}
 `)
 	if err != nil {
 		return err
 	}
 
-	if err := emitCborUnmarshalStringField(w, Field{Name: "name"}); err != nil {
+	if err := g.emitCborUnmarshalStringField(w, Field{Name: "name"}); err != nil {
 		return err
 	}
 
-	err = doTemplate(w, gti, `
+	err = g.doTemplate(w, gti, `
 		switch name {
 `)
 	if err != nil {
@@ -1269,59 +1757,36 @@ func (t *{{ .Name}}) UnmarshalCBOR(r io.Reader) (err error) {
 	for _, f := range gti.Fields {
 		fmt.Fprintf(w, "// t.%s (%s) (%s)", f.Name, f.Type, f.Type.Kind())
 
-		err := doTemplate(w, f, `
+		err := g.doTemplate(w, f, `
 		case "{{ .MapKey }}":
 `)
 		if err != nil {
 			return err
 		}
 
-		f.Name = "t." + f.Name
+		if err := g.emitCborUnmarshalTypeField(w, gti, f, forEach); err != nil {
+			return err
+		}
+	}
 
-		switch f.Type.Kind() {
-		case reflect.String:
-			if err := emitCborUnmarshalStringField(w, f); err != nil {
-				return err
-			}
-		case reflect.Struct:
-			if err := emitCborUnmarshalStructField(w, f); err != nil {
-				return err
-			}
-		case reflect.Uint64:
-			if err := emitCborUnmarshalUint64Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Int64:
-			if err := emitCborUnmarshalInt64Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Uint8:
-			if err := emitCborUnmarshalUint8Field(w, f); err != nil {
-				return err
-			}
-		case reflect.Array:
-			fallthrough
-		case reflect.Slice:
-			if err := emitCborUnmarshalSliceField(w, f); err != nil {
-				return err
-			}
-		case reflect.Bool:
-			if err := emitCborUnmarshalBoolField(w, f); err != nil {
-				return err
-			}
-		case reflect.Map:
-			if err := emitCborUnmarshalMapField(w, f); err != nil {
-				return err
-			}
+	if g.Strict {
+		return g.doTemplate(w, gti, `
 		default:
-			return fmt.Errorf("field %q of %q has unsupported kind %q", f.Name, gti.Name, f.Type.Kind())
+			return fmt.Errorf("{{ .Name }}: unknown field %q", name)
 		}
 	}
 
-	return doTemplate(w, gti, `
+	return nil
+}
+`)
+	}
+
+	return g.doTemplate(w, gti, `
 		default:
 			// Field doesn't exist on this type, so ignore it
-			cbg.ScanForLinks(r, func(cid.Cid){})
+			if err := cbg.ScanForLinks(cr, func(cid.Cid){}); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -1330,15 +1795,130 @@ func (t *{{ .Name}}) UnmarshalCBOR(r io.Reader) (err error) {
 `)
 }
 
-// Generates 'tuple representation' cbor encoders for the given type
+// Generates 'map representation' cbor encoders for the given type
 func GenMapEncodersForType(gti *GenTypeInfo, w io.Writer) error {
-	if err := emitCborMarshalStructMap(w, gti); err != nil {
+	return GenOptions{}.GenMapEncodersForType(gti, w)
+}
+
+// Generates 'map representation' cbor encoders for the given type
+func (g GenOptions) GenMapEncodersForType(gti *GenTypeInfo, w io.Writer) error {
+	if errs := g.checkTypeInfos([]*GenTypeInfo{gti}); len(errs) > 0 {
+		return errs
+	}
+
+	gti.ensureImports()
+
+	if err := g.emitCborMarshalStructMap(w, gti); err != nil {
 		return err
 	}
 
-	if err := emitCborUnmarshalStructMap(w, gti); err != nil {
+	if err := g.emitCborUnmarshalStructMap(w, gti); err != nil {
 		return err
 	}
 
+	if err := g.emitCborForEachMethods(w, gti, true); err != nil {
+		return err
+	}
+
+	if g.SizeMethods {
+		if err := g.emitCborSizeStruct(w, gti, true); err != nil {
+			return err
+		}
+	}
+
+	if g.BytesDecoders {
+		if err := g.emitCborUnmarshalBytes(w, gti); err != nil {
+			return err
+		}
+	}
+
+	if g.LinkWalkers {
+		if err := g.emitCborLinksStruct(w, gti); err != nil {
+			return err
+		}
+	}
+
+	if g.JSONCodecs {
+		if err := g.emitJSONStruct(w, gti); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// FieldError describes a field of a type that no code can be generated for.
+type FieldError struct {
+	Type  string
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s.%s: %s", e.Type, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// GenErrors collects all the problems found while generating code for a set
+// of types, so they can be fixed in one go.
+type GenErrors []error
+
+func (errs GenErrors) Error() string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d problems found:\n\t%s", len(errs), strings.Join(msgs, "\n\t"))
+}
+
+// fieldEmitters returns the code generators that run for every field, in
+// either representation.
+func (g GenOptions) fieldEmitters() []func(io.Writer, Field) error {
+	emitters := []func(io.Writer, Field) error{
+		g.emitCborMarshalField,
+		g.emitCborUnmarshalField,
+	}
+	if g.AppendEncoders {
+		emitters = append(emitters, g.emitCborAppendField)
+	}
+	if g.SizeMethods {
+		emitters = append(emitters, g.emitCborSizeField)
+	}
+	if g.LinkWalkers {
+		emitters = append(emitters, g.emitCborLinksField)
+	}
+	if g.JSONCodecs {
+		emitters = append(emitters, g.emitJSONMarshalField, g.emitJSONUnmarshalField)
+	}
+	return emitters
+}
+
+// checkTypeInfos does a dry run of the code generation for every field of the
+// given types and returns all the fields that can't be generated as GenErrors.
+func (g GenOptions) checkTypeInfos(typeInfos []*GenTypeInfo) GenErrors {
+	var errs GenErrors
+	for _, gti := range typeInfos {
+		for _, f := range gti.Fields {
+			fname := f.Name
+			f.Name = "t." + f.Name
+			emitters := g.fieldEmitters()
+			if f.ForEach {
+				emitters = append(emitters, g.emitCborForEachField)
+			}
+			for _, emit := range emitters {
+				if err := emit(ioutil.Discard, f); err != nil {
+					errs = append(errs, &FieldError{Type: gti.Name, Field: fname, Err: err})
+					break
+				}
+			}
+		}
+	}
+	return errs
+}
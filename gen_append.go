@@ -0,0 +1,330 @@
+package typegen
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// The emitters in this file generate AppendCBOR methods, which encode straight
+// into a byte slice instead of going through an io.Writer. They mirror the
+// MarshalCBOR emitters in gen.go and must produce the same bytes.
+
+func (g GenOptions) emitCborAppendStringField(w io.Writer, f Field) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to strings not supported")
+	}
+
+	return g.doTemplate(w, f, `
+	if len({{ .Name }}) > {{ MaxLen .MaxLen }} {
+		return nil, xerrors.Errorf("Value in field {{ .Name | js }} was too long")
+	}
+
+	b = cbg.AppendString(b, string({{ .Name }}))
+`)
+}
+
+func (g GenOptions) emitCborAppendStructField(w io.Writer, f Field) error {
+	switch f.Type {
+	case bigIntType:
+		return g.doTemplate(w, f, `
+	{
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajTag, 2)
+		var bi []byte
+		if {{ .Name }} != nil {
+			bi = {{ .Name }}.Bytes()
+		}
+		b = cbg.AppendByteString(b, bi)
+	}
+`)
+
+	case cidType:
+		return g.doTemplate(w, f, `
+{{ if .Pointer }}
+	if {{ .Name }} == nil {
+		b = append(b, cbg.CborNull...)
+	} else {
+		if b, err = cbg.AppendCid(b, *{{ .Name }}); err != nil {
+			return nil, xerrors.Errorf("failed to write cid field {{ .Name }}: %w", err)
+		}
+	}
+{{ else }}
+	if b, err = cbg.AppendCid(b, {{ .Name }}); err != nil {
+		return nil, xerrors.Errorf("failed to write cid field {{ .Name }}: %w", err)
+	}
+{{ end }}
+`)
+	default:
+		return g.doTemplate(w, f, `
+	if b, err = cbg.AppendMarshaler(b, {{ if not .Pointer }}&{{ end }}{{ .Name }}); err != nil {
+		return nil, err
+	}
+`)
+	}
+}
+
+func (g GenOptions) emitCborAppendUint64Field(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
+{{ if .Pointer }}
+	if {{ .Name }} == nil {
+		b = append(b, cbg.CborNull...)
+	} else {
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(*{{ .Name }}))
+	}
+{{ else }}
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64({{ .Name }}))
+{{ end }}
+`)
+}
+
+func (g GenOptions) emitCborAppendUint8Field(w io.Writer, f Field) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to integers not supported")
+	}
+	return g.doTemplate(w, f, `
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64({{ .Name }}))
+`)
+}
+
+func (g GenOptions) emitCborAppendInt64Field(w io.Writer, f Field) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to integers not supported")
+	}
+	return g.doTemplate(w, f, `
+	b = cbg.AppendInt64(b, int64({{ .Name }}))
+`)
+}
+
+func (g GenOptions) emitCborAppendBoolField(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
+	b = cbg.AppendBool(b, {{ .Name }})
+`)
+}
+
+func (g GenOptions) emitCborAppendMapField(w io.Writer, f Field) error {
+	err := g.doTemplate(w, f, `
+{
+	if len({{ .Name }}) > {{ MaxMapLen }} {
+		return nil, xerrors.Errorf("cannot marshal {{ .Name }} map too large")
+	}
+
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajMap, uint64(len({{ .Name }})))
+
+	keys := make([]string, 0, len({{ .Name }}))
+	for k := range {{ .Name }} {
+		keys = append(keys, k)
+	}
+`)
+	if err != nil {
+		return err
+	}
+
+	if err := g.emitSortMapKeys(w, f); err != nil {
+		return err
+	}
+
+	err = g.doTemplate(w, f, `	for _, k := range keys {
+		v := {{ .Name }}[k]
+
+`)
+	if err != nil {
+		return err
+	}
+
+	// Map key
+	switch f.Type.Key().Kind() {
+	case reflect.String:
+		if err := g.emitCborAppendStringField(w, Field{Name: "k"}); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("non-string map keys are not yet supported")
+	}
+
+	// Map value
+	switch f.Type.Elem().Kind() {
+	case reflect.Ptr:
+		if f.Type.Elem().Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("unsupported map elem ptr type: %s", f.Type.Elem())
+		}
+
+		if err := g.emitCborAppendStructField(w, Field{Name: "v", Pointer: true, Type: f.Type.Elem().Elem(), Pkg: f.Pkg, imports: f.imports}); err != nil {
+			return err
+		}
+	case reflect.Struct:
+		// v is a copy, so it is addressable.
+		if err := g.emitCborAppendStructField(w, Field{Name: "v", Type: f.Type.Elem(), Pkg: f.Pkg, imports: f.imports}); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("currently unsupported map elem type: %s", f.Type.Elem())
+	}
+
+	return g.doTemplate(w, f, `
+	}
+	}
+`)
+}
+
+func (g GenOptions) emitCborAppendSliceField(w io.Writer, f Field) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to slices not supported")
+	}
+	e := f.Type.Elem()
+
+	// Note: this re-slices the slice to deal with arrays.
+	if e.Kind() == reflect.Uint8 {
+		return g.doTemplate(w, f, `
+	if len({{ .Name }}) > {{ MaxByteLen .MaxLen }} {
+		return nil, xerrors.Errorf("Byte array in field {{ .Name }} was too long")
+	}
+
+	b = cbg.AppendByteString(b, {{ .Name }}[:])
+`)
+	}
+
+	var pointer bool
+	if e.Kind() == reflect.Ptr {
+		pointer = true
+		e = e.Elem()
+	}
+
+	err := g.doTemplate(w, f, `
+	if len({{ .Name }}) > {{ MaxLen .MaxLen }} {
+		return nil, xerrors.Errorf("Slice value in field {{ .Name }} was too long")
+	}
+
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len({{ .Name }})))
+	for _, v := range {{ .Name }} {`)
+	if err != nil {
+		return err
+	}
+
+	subf := Field{Name: "v", Type: e, Pkg: f.Pkg, Pointer: pointer, imports: f.imports}
+	switch e.Kind() {
+	default:
+		return fmt.Errorf("do not yet support slices of %s yet", e.Kind())
+	case reflect.Struct:
+		switch e {
+		case cidType:
+			err := g.doTemplate(w, f, `
+		if b, err = cbg.AppendCid(b, v); err != nil {
+			return nil, xerrors.Errorf("failed writing cid field {{ .Name }}: %w", err)
+		}
+`)
+			if err != nil {
+				return err
+			}
+
+		default:
+			if err := g.emitCborAppendStructField(w, subf); err != nil {
+				return err
+			}
+		}
+	case reflect.Uint64, reflect.Uint8:
+		err := g.doTemplate(w, f, `
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(v))
+`)
+		if err != nil {
+			return err
+		}
+	case reflect.Int64:
+		if err := g.emitCborAppendInt64Field(w, subf); err != nil {
+			return err
+		}
+
+	case reflect.Slice:
+		if err := g.emitCborAppendSliceField(w, subf); err != nil {
+			return err
+		}
+	}
+
+	// array end
+	fmt.Fprintf(w, "\t}\n")
+	return nil
+}
+
+// emitCborAppendField emits the code to append a single field of any
+// supported kind.
+func (g GenOptions) emitCborAppendField(w io.Writer, f Field) error {
+	switch f.Type.Kind() {
+	case reflect.String:
+		return g.emitCborAppendStringField(w, f)
+	case reflect.Struct:
+		return g.emitCborAppendStructField(w, f)
+	case reflect.Uint64:
+		return g.emitCborAppendUint64Field(w, f)
+	case reflect.Uint8:
+		return g.emitCborAppendUint8Field(w, f)
+	case reflect.Int64:
+		return g.emitCborAppendInt64Field(w, f)
+	case reflect.Array, reflect.Slice:
+		return g.emitCborAppendSliceField(w, f)
+	case reflect.Bool:
+		return g.emitCborAppendBoolField(w, f)
+	case reflect.Map:
+		return g.emitCborAppendMapField(w, f)
+	default:
+		return fmt.Errorf("unsupported kind %q", f.Type.Kind())
+	}
+}
+
+// emitCborAppendStruct emits a MarshalCBOR method that wraps AppendCBOR, and
+// the AppendCBOR method itself, for either struct representation.
+func (g GenOptions) emitCborAppendStruct(w io.Writer, gti *GenTypeInfo, mapRepr bool) error {
+	header := "lengthBuf" + gti.Name
+	fields := gti.Fields
+	if mapRepr {
+		header = gti.MapHeaderAsByteString()
+		fields = g.mapFieldOrder(gti)
+	}
+
+	data := struct {
+		*GenTypeInfo
+		Header string
+		Sized  bool
+	}{gti, header, g.SizeMethods}
+	err := g.doTemplate(w, data, `func (t *{{ .Name }}) MarshalCBOR(w io.Writer) error {
+{{- if .Sized }}
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+{{- else }}
+	b, err := t.AppendCBOR(nil)
+{{- end }}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func (t *{{ .Name }}) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
+	}
+
+	b = append(b, {{ .Header }}...)
+`)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		fmt.Fprintf(w, "\n\t// t.%s (%s) (%s)", f.Name, f.Type, f.Type.Kind())
+		fname := f.Name
+
+		if mapRepr {
+			if err := g.emitCborAppendStringField(w, Field{Name: `"` + f.MapKey + `"`}); err != nil {
+				return err
+			}
+		}
+
+		f.Name = "t." + f.Name
+		if err := g.emitCborAppendField(w, f); err != nil {
+			return &FieldError{Type: gti.Name, Field: fname, Err: err}
+		}
+	}
+
+	fmt.Fprintf(w, "\treturn b, nil\n}\n\n")
+	return nil
+}
@@ -0,0 +1,359 @@
+package typegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// The emitters in this file generate MarshalJSON and UnmarshalJSON methods.
+// Every type is written as a JSON object keyed by the names of its map
+// representation, with the keys sorted as DAG-JSON requires. Byte strings and
+// Deferred values are written as CBORToDagJSON would write them, and CIDs as
+// cid.Cid marshals itself, which is the same. Other values are left to
+// encoding/json, and so to their own MarshalJSON methods. Decoding enforces
+// the same length limits as the CBOR decoder.
+
+// isJSONBytes reports whether values of type t are written as DAG-JSON bytes.
+func isJSONBytes(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// jsonNeedsLoop reports whether values of type t must be converted element by
+// element, rather than by encoding/json as a whole. Elements of maps are not
+// addressable, so encoding/json wouldn't use the pointer methods generated
+// for them.
+func jsonNeedsLoop(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case isJSONBytes(t), t == deferredType:
+		return true
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Array:
+		return jsonNeedsLoop(t.Elem())
+	case t.Kind() == reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonElemField returns the field for the elements of the slice, array or
+// map field f, named name.
+func jsonElemField(f Field, name string) Field {
+	e := f.Type.Elem()
+	var pointer bool
+	if e.Kind() == reflect.Ptr {
+		pointer = true
+		e = e.Elem()
+	}
+	return Field{
+		Name:      name,
+		Type:      e,
+		Pkg:       f.Pkg,
+		Pointer:   pointer,
+		IterLabel: string([]byte{f.IterLabel[0] + 1}),
+		imports:   f.imports,
+	}
+}
+
+func (g GenOptions) emitJSONMarshalField(w io.Writer, f Field) error {
+	if f.IterLabel == "" {
+		f.IterLabel = "i"
+	}
+
+	switch {
+	case isJSONBytes(f.Type):
+		if f.Pointer {
+			return fmt.Errorf("pointers to byte arrays not supported")
+		}
+		return g.doTemplate(w, f, `
+	b = cbg.AppendJSONBytes(b, {{ .Name }}[:])
+`)
+
+	case f.Type == deferredType:
+		return g.doTemplate(w, f, `
+	if b, err = cbg.AppendJSONDeferred(b, {{ if not .Pointer }}&{{ end }}{{ .Name }}); err != nil {
+		return nil, err
+	}
+`)
+
+	case f.Type.Kind() == reflect.Map && !f.Pointer:
+		if f.Type.Key().Kind() != reflect.String {
+			return fmt.Errorf("non-string map keys are not yet supported")
+		}
+		err := g.doTemplate(w, f, `
+	if {{ .Name }} == nil {
+		b = append(b, "null"...)
+	} else {
+		keys := make([]string, 0, len({{ .Name }}))
+		for k := range {{ .Name }} {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = append(b, '{')
+		for {{ .IterLabel }}, k := range keys {
+			if {{ .IterLabel }} > 0 {
+				b = append(b, ',')
+			}
+			if b, err = cbg.AppendJSON(b, k); err != nil {
+				return nil, err
+			}
+			b = append(b, ':')
+			v := {{ .Name }}[k]
+`)
+		if err != nil {
+			return err
+		}
+		if err := g.emitJSONMarshalField(w, jsonElemField(f, "v")); err != nil {
+			return err
+		}
+		return g.doTemplate(w, f, `
+		}
+		b = append(b, '}')
+	}
+`)
+
+	case (f.Type.Kind() == reflect.Slice || f.Type.Kind() == reflect.Array) && jsonNeedsLoop(f.Type) && !f.Pointer:
+		err := g.doTemplate(w, f, `
+{{- if not .IsArray }}
+	if {{ .Name }} == nil {
+		b = append(b, "null"...)
+	} else {
+{{- end }}
+	b = append(b, '[')
+	for {{ .IterLabel }} := range {{ .Name }} {
+		if {{ .IterLabel }} > 0 {
+			b = append(b, ',')
+		}
+`)
+		if err != nil {
+			return err
+		}
+		if err := g.emitJSONMarshalField(w, jsonElemField(f, fmt.Sprintf("%s[%s]", f.Name, f.IterLabel))); err != nil {
+			return err
+		}
+		return g.doTemplate(w, f, `
+	}
+	b = append(b, ']')
+{{- if not .IsArray }}
+	}
+{{- end }}
+`)
+
+	default:
+		return g.doTemplate(w, f, `
+	if b, err = cbg.AppendJSON(b, &{{ .Name }}); err != nil {
+		return nil, err
+	}
+`)
+	}
+}
+
+func (g GenOptions) emitJSONUnmarshalField(w io.Writer, f Field) error {
+	if f.IterLabel == "" {
+		f.IterLabel = "i"
+	}
+
+	switch {
+	case isJSONBytes(f.Type):
+		if f.Pointer {
+			return fmt.Errorf("pointers to byte arrays not supported")
+		}
+		return g.doTemplate(w, f, `
+	{
+		bs, err := cbg.ReadJSONBytes(v)
+		if err != nil {
+			return err
+		}
+		if len(bs) > {{ MaxByteLen .MaxLen }} {
+			return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: {{ MaxByteLen .MaxLen }}})
+		}
+{{- if .IsArray }}
+		if len(bs) != {{ .Len }} {
+			return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrWrongLength{Want: {{ .Len }}, Got: uint64(len(bs))})
+		}
+		copy({{ .Name }}[:], bs)
+{{- else }}
+		{{ .Name }} = bs
+{{- end }}
+	}
+`)
+
+	case f.Type == deferredType:
+		return g.doTemplate(w, f, `
+	{
+		d, err := cbg.ReadJSONDeferred(v)
+		if err != nil {
+			return err
+		}
+		{{ .Name }} = {{ if not .Pointer }}*{{ end }}d
+	}
+`)
+
+	case f.Type.Kind() == reflect.Map && !f.Pointer:
+		if f.Type.Key().Kind() != reflect.String {
+			return fmt.Errorf("non-string map keys are not yet supported")
+		}
+		err := g.doTemplate(w, f, `
+	if !cbg.IsJSONNull(v) {
+		{{ .Name }} = make({{ .TypeName }})
+		if err := cbg.ReadJSONObject(v, func(k string, v []byte) error {
+			if len({{ .Name }}) >= {{ MaxMapLen }} {
+				return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: uint64(len({{ .Name }})) + 1, Limit: {{ MaxMapLen }}})
+			}
+			var val {{ .ElemName }}
+`)
+		if err != nil {
+			return err
+		}
+		if err := g.emitJSONUnmarshalField(w, jsonElemField(f, "val")); err != nil {
+			return err
+		}
+		return g.doTemplate(w, f, `
+			{{ .Name }}[k] = val
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+`)
+
+	case (f.Type.Kind() == reflect.Slice || f.Type.Kind() == reflect.Array) && jsonNeedsLoop(f.Type) && !f.Pointer:
+		err := g.doTemplate(w, f, `
+	{
+		elems, err := cbg.ReadJSONArray(v)
+		if err != nil {
+			return err
+		}
+		if len(elems) > {{ MaxLen .MaxLen }} {
+			return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: uint64(len(elems)), Limit: {{ MaxLen .MaxLen }}})
+		}
+{{- if .IsArray }}
+		if len(elems) != {{ .Len }} {
+			return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrWrongLength{Want: {{ .Len }}, Got: uint64(len(elems))})
+		}
+{{- else }}
+		if elems != nil {
+			{{ .Name }} = make({{ .TypeName }}, len(elems))
+		}
+{{- end }}
+		for {{ .IterLabel }}, v := range elems {
+`)
+		if err != nil {
+			return err
+		}
+		if err := g.emitJSONUnmarshalField(w, jsonElemField(f, fmt.Sprintf("%s[%s]", f.Name, f.IterLabel))); err != nil {
+			return err
+		}
+		return g.doTemplate(w, f, `
+		}
+	}
+`)
+
+	case (f.Type.Kind() == reflect.String || f.Type.Kind() == reflect.Slice) && !f.Pointer:
+		return g.doTemplate(w, f, `
+	if err := cbg.ReadJSON(v, &{{ .Name }}); err != nil {
+		return err
+	}
+	if len({{ .Name }}) > {{ MaxLen .MaxLen }} {
+		return fmt.Errorf("{{ .Name }}: %w", &cbg.ErrTooLong{Length: uint64(len({{ .Name }})), Limit: {{ MaxLen .MaxLen }}})
+	}
+`)
+
+	default:
+		return g.doTemplate(w, f, `
+	if err := cbg.ReadJSON(v, &{{ .Name }}); err != nil {
+		return err
+	}
+`)
+	}
+}
+
+// jsonFieldOrder returns the fields of gti sorted by key, the order DAG-JSON
+// requires.
+func jsonFieldOrder(gti *GenTypeInfo) []Field {
+	fields := make([]Field, len(gti.Fields))
+	copy(fields, gti.Fields)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].MapKey < fields[j].MapKey
+	})
+	return fields
+}
+
+// emitJSONStruct emits the MarshalJSON and UnmarshalJSON methods.
+func (g GenOptions) emitJSONStruct(w io.Writer, gti *GenTypeInfo) error {
+	err := g.doTemplate(w, gti, `func (t *{{ .Name }}) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+`)
+	if err != nil {
+		return err
+	}
+
+	for i, f := range jsonFieldOrder(gti) {
+		fmt.Fprintf(w, "\n\t// t.%s (%s) (%s)", f.Name, f.Type, f.Type.Kind())
+
+		key, err := json.Marshal(f.MapKey)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			key = append([]byte{','}, key...)
+		}
+		fmt.Fprintf(w, "\n\tb = append(b, %q...)\n", string(key)+":")
+
+		fname := f.Name
+		f.Name = "t." + f.Name
+		if err := g.emitJSONMarshalField(w, f); err != nil {
+			return &FieldError{Type: gti.Name, Field: fname, Err: err}
+		}
+	}
+
+	err = g.doTemplate(w, gti, `
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *{{ .Name }}) UnmarshalJSON(b []byte) error {
+	*t = {{ .Name }}{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+`)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range gti.Fields {
+		fmt.Fprintf(w, "\t\t// t.%s (%s) (%s)", f.Name, f.Type, f.Type.Kind())
+		fmt.Fprintf(w, "\n\t\tcase %q:\n", f.MapKey)
+
+		fname := f.Name
+		f.Name = "t." + f.Name
+		if err := g.emitJSONUnmarshalField(w, f); err != nil {
+			return &FieldError{Type: gti.Name, Field: fname, Err: err}
+		}
+	}
+
+	if g.Strict {
+		err := g.doTemplate(w, gti, `
+		default:
+			return fmt.Errorf("{{ .Name }}: unknown field %q", key)`)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "\n\t\t}\n\t\treturn nil\n\t})\n}\n\n")
+	return nil
+}
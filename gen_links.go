@@ -0,0 +1,239 @@
+package typegen
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// The emitters in this file generate ForEachLink and ForEachLinkPath methods,
+// which visit the CIDs of a value in memory in the order MarshalCBOR would
+// write them. Each field emitter is given the Go expression for the path of
+// its field, which is empty when generating ForEachLink.
+
+type linkField struct {
+	Field
+	Path string
+}
+
+// mayHaveLinks reports whether values of type t can contain CIDs.
+func mayHaveLinks(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != bigIntType
+	case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+		return mayHaveLinks(t.Elem())
+	default:
+		return false
+	}
+}
+
+func (g GenOptions) emitCborLinksStructField(w io.Writer, f linkField) error {
+	switch f.Type {
+	case bigIntType:
+		return nil
+
+	case cidType:
+		return g.doTemplate(w, f, `
+{{ if .Pointer }}
+	if {{ .Name }} != nil && {{ .Name }}.Defined() {
+		if err := fn({{ if .Path }}{{ .Path }}, {{ end }}*{{ .Name }}); err != nil {
+			return err
+		}
+	}
+{{ else }}
+	if {{ .Name }}.Defined() {
+		if err := fn({{ if .Path }}{{ .Path }}, {{ end }}{{ .Name }}); err != nil {
+			return err
+		}
+	}
+{{ end }}
+`)
+	default:
+		return g.doTemplate(w, f, `
+{{ if .Pointer }}
+	if {{ .Name }} != nil {
+{{ end }}
+	{{ if .Path -}}
+	if err := cbg.ForEachLinkPath({{ if not .Pointer }}&{{ end }}{{ .Name }}, {{ .Path }}, fn); err != nil {
+		return err
+	}
+	{{- else -}}
+	if err := cbg.ForEachLink({{ if not .Pointer }}&{{ end }}{{ .Name }}, fn); err != nil {
+		return err
+	}
+	{{- end }}
+{{ if .Pointer }}
+	}
+{{ end }}
+`)
+	}
+}
+
+func (g GenOptions) emitCborLinksMapField(w io.Writer, f linkField) error {
+	if f.Type.Key().Kind() != reflect.String {
+		return fmt.Errorf("non-string map keys are not yet supported")
+	}
+
+	e := f.Type.Elem()
+	var pointer bool
+	if e.Kind() == reflect.Ptr {
+		pointer = true
+		e = e.Elem()
+	}
+	if e.Kind() != reflect.Struct {
+		return fmt.Errorf("currently unsupported map elem type: %s", f.Type.Elem())
+	}
+	if !mayHaveLinks(e) {
+		return nil
+	}
+
+	err := g.doTemplate(w, f, `
+	{
+		keys := make([]string, 0, len({{ .Name }}))
+		for k := range {{ .Name }} {
+			keys = append(keys, k)
+		}
+`)
+	if err != nil {
+		return err
+	}
+
+	if err := g.emitSortMapKeys(w, f.Field); err != nil {
+		return err
+	}
+
+	err = g.doTemplate(w, f, `	for _, k := range keys {
+		v := {{ .Name }}[k]
+`)
+	if err != nil {
+		return err
+	}
+
+	subf := linkField{Field: Field{Name: "v", Type: e, Pkg: f.Pkg, Pointer: pointer, imports: f.imports}}
+	if f.Path != "" {
+		subf.Path = fmt.Sprintf("cbg.JoinPath(%s, k)", f.Path)
+	}
+	if err := g.emitCborLinksStructField(w, subf); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\t\t}\n\t}\n")
+	return nil
+}
+
+func (g GenOptions) emitCborLinksSliceField(w io.Writer, f linkField) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to slices not supported")
+	}
+	if f.IterLabel == "" {
+		f.IterLabel = "i"
+	}
+
+	e := f.Type.Elem()
+	var pointer bool
+	if e.Kind() == reflect.Ptr {
+		pointer = true
+		e = e.Elem()
+	}
+	if !mayHaveLinks(e) {
+		return nil
+	}
+
+	err := g.doTemplate(w, f, `
+	for {{ .IterLabel }} := range {{ .Name }} {`)
+	if err != nil {
+		return err
+	}
+
+	subf := linkField{Field: Field{
+		Name:      fmt.Sprintf("%s[%s]", f.Name, f.IterLabel),
+		Type:      e,
+		Pkg:       f.Pkg,
+		Pointer:   pointer,
+		IterLabel: string([]byte{f.IterLabel[0] + 1}),
+		imports:   f.imports,
+	}}
+	if f.Path != "" {
+		subf.Path = fmt.Sprintf("cbg.JoinPathIndex(%s, %s)", f.Path, f.IterLabel)
+	}
+
+	switch e.Kind() {
+	case reflect.Struct:
+		if err := g.emitCborLinksStructField(w, subf); err != nil {
+			return err
+		}
+	case reflect.Array, reflect.Slice:
+		if err := g.emitCborLinksSliceField(w, subf); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("do not yet support slices of %s yet", e.Kind())
+	}
+
+	fmt.Fprintf(w, "\t}\n")
+	return nil
+}
+
+func (g GenOptions) emitCborLinks(w io.Writer, f linkField) error {
+	switch f.Type.Kind() {
+	case reflect.String, reflect.Uint64, reflect.Uint8, reflect.Int64, reflect.Bool:
+		return nil
+	case reflect.Struct:
+		return g.emitCborLinksStructField(w, f)
+	case reflect.Array, reflect.Slice:
+		return g.emitCborLinksSliceField(w, f)
+	case reflect.Map:
+		return g.emitCborLinksMapField(w, f)
+	default:
+		return fmt.Errorf("unsupported kind %q", f.Type.Kind())
+	}
+}
+
+// emitCborLinksField emits the code to visit the CIDs of a single field of any
+// supported kind.
+func (g GenOptions) emitCborLinksField(w io.Writer, f Field) error {
+	return g.emitCborLinks(w, linkField{Field: f})
+}
+
+// emitCborLinksStruct emits the ForEachLink and ForEachLinkPath methods.
+func (g GenOptions) emitCborLinksStruct(w io.Writer, gti *GenTypeInfo) error {
+	for _, withPath := range []bool{false, true} {
+		header := `func (t *{{ .Name }}) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+`
+		if withPath {
+			header = `func (t *{{ .Name }}) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+`
+		}
+		if err := g.doTemplate(w, gti, header); err != nil {
+			return err
+		}
+
+		for _, f := range gti.Fields {
+			if !mayHaveLinks(f.Type) {
+				continue
+			}
+
+			fmt.Fprintf(w, "\n\t// t.%s (%s) (%s)", f.Name, f.Type, f.Type.Kind())
+			fname := f.Name
+
+			lf := linkField{Field: f}
+			lf.Name = "t." + f.Name
+			if withPath {
+				lf.Path = fmt.Sprintf("cbg.JoinPath(prefix, %q)", f.MapKey)
+			}
+			if err := g.emitCborLinks(w, lf); err != nil {
+				return &FieldError{Type: gti.Name, Field: fname, Err: err}
+			}
+		}
+
+		fmt.Fprintf(w, "\treturn nil\n}\n\n")
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package typegen
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// The emitters in this file generate CBORSize methods, which compute the
+// length of the encoding produced by MarshalCBOR without marshaling. Each
+// field emitter adds the encoded length of its field to n.
+
+func (g GenOptions) emitCborSizeStringField(w io.Writer, f Field) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to strings not supported")
+	}
+
+	return g.doTemplate(w, f, `
+	n += cbg.CborHeaderSize(uint64(len({{ .Name }}))) + len({{ .Name }})
+`)
+}
+
+func (g GenOptions) emitCborSizeStructField(w io.Writer, f Field) error {
+	switch f.Type {
+	case bigIntType:
+		return g.doTemplate(w, f, `
+	{
+		var l int
+		if {{ .Name }} != nil {
+			l = ({{ .Name }}.BitLen() + 7) / 8
+		}
+		n += cbg.CborHeaderSize(2) + cbg.CborHeaderSize(uint64(l)) + l
+	}
+`)
+
+	case cidType:
+		return g.doTemplate(w, f, `
+{{ if .Pointer }}
+	if {{ .Name }} == nil {
+		n += len(cbg.CborNull)
+	} else {
+		n += cbg.CidSize(*{{ .Name }})
+	}
+{{ else }}
+	n += cbg.CidSize({{ .Name }})
+{{ end }}
+`)
+	default:
+		return g.doTemplate(w, f, `
+	n += cbg.SizeOf({{ if not .Pointer }}&{{ end }}{{ .Name }})
+`)
+	}
+}
+
+func (g GenOptions) emitCborSizeUint64Field(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
+{{ if .Pointer }}
+	if {{ .Name }} == nil {
+		n += len(cbg.CborNull)
+	} else {
+		n += cbg.CborHeaderSize(uint64(*{{ .Name }}))
+	}
+{{ else }}
+	n += cbg.CborHeaderSize(uint64({{ .Name }}))
+{{ end }}
+`)
+}
+
+func (g GenOptions) emitCborSizeUint8Field(w io.Writer, f Field) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to integers not supported")
+	}
+	return g.doTemplate(w, f, `
+	n += cbg.CborHeaderSize(uint64({{ .Name }}))
+`)
+}
+
+func (g GenOptions) emitCborSizeInt64Field(w io.Writer, f Field) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to integers not supported")
+	}
+	return g.doTemplate(w, f, `
+	n += cbg.CborIntSize(int64({{ .Name }}))
+`)
+}
+
+func (g GenOptions) emitCborSizeBoolField(w io.Writer, f Field) error {
+	return g.doTemplate(w, f, `
+	n += 1
+`)
+}
+
+func (g GenOptions) emitCborSizeMapField(w io.Writer, f Field) error {
+	err := g.doTemplate(w, f, `
+	n += cbg.CborHeaderSize(uint64(len({{ .Name }})))
+	for k, v := range {{ .Name }} {
+`)
+	if err != nil {
+		return err
+	}
+
+	// Map key
+	switch f.Type.Key().Kind() {
+	case reflect.String:
+		if err := g.emitCborSizeStringField(w, Field{Name: "k"}); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("non-string map keys are not yet supported")
+	}
+
+	// Map value
+	switch f.Type.Elem().Kind() {
+	case reflect.Ptr:
+		if f.Type.Elem().Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("unsupported map elem ptr type: %s", f.Type.Elem())
+		}
+
+		if err := g.emitCborSizeStructField(w, Field{Name: "v", Pointer: true, Type: f.Type.Elem().Elem(), Pkg: f.Pkg, imports: f.imports}); err != nil {
+			return err
+		}
+	case reflect.Struct:
+		if err := g.emitCborSizeStructField(w, Field{Name: "v", Type: f.Type.Elem(), Pkg: f.Pkg, imports: f.imports}); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("currently unsupported map elem type: %s", f.Type.Elem())
+	}
+
+	return g.doTemplate(w, f, `
+	}
+`)
+}
+
+func (g GenOptions) emitCborSizeSliceField(w io.Writer, f Field) error {
+	if f.Pointer {
+		return fmt.Errorf("pointers to slices not supported")
+	}
+	e := f.Type.Elem()
+
+	if e.Kind() == reflect.Uint8 {
+		return g.doTemplate(w, f, `
+	n += cbg.CborHeaderSize(uint64(len({{ .Name }}))) + len({{ .Name }})
+`)
+	}
+
+	var pointer bool
+	if e.Kind() == reflect.Ptr {
+		pointer = true
+		e = e.Elem()
+	}
+
+	err := g.doTemplate(w, f, `
+	n += cbg.CborHeaderSize(uint64(len({{ .Name }})))
+	for _, v := range {{ .Name }} {`)
+	if err != nil {
+		return err
+	}
+
+	subf := Field{Name: "v", Type: e, Pkg: f.Pkg, Pointer: pointer, imports: f.imports}
+	switch e.Kind() {
+	default:
+		return fmt.Errorf("do not yet support slices of %s yet", e.Kind())
+	case reflect.Struct:
+		if err := g.emitCborSizeStructField(w, subf); err != nil {
+			return err
+		}
+	case reflect.Uint64, reflect.Uint8:
+		if err := g.emitCborSizeUint64Field(w, subf); err != nil {
+			return err
+		}
+	case reflect.Int64:
+		if err := g.emitCborSizeInt64Field(w, subf); err != nil {
+			return err
+		}
+	case reflect.Slice:
+		if err := g.emitCborSizeSliceField(w, subf); err != nil {
+			return err
+		}
+	}
+
+	// array end
+	fmt.Fprintf(w, "\t}\n")
+	return nil
+}
+
+// emitCborSizeField emits the code to compute the encoded length of a single
+// field of any supported kind.
+func (g GenOptions) emitCborSizeField(w io.Writer, f Field) error {
+	switch f.Type.Kind() {
+	case reflect.String:
+		return g.emitCborSizeStringField(w, f)
+	case reflect.Struct:
+		return g.emitCborSizeStructField(w, f)
+	case reflect.Uint64:
+		return g.emitCborSizeUint64Field(w, f)
+	case reflect.Uint8:
+		return g.emitCborSizeUint8Field(w, f)
+	case reflect.Int64:
+		return g.emitCborSizeInt64Field(w, f)
+	case reflect.Array, reflect.Slice:
+		return g.emitCborSizeSliceField(w, f)
+	case reflect.Bool:
+		return g.emitCborSizeBoolField(w, f)
+	case reflect.Map:
+		return g.emitCborSizeMapField(w, f)
+	default:
+		return fmt.Errorf("unsupported kind %q", f.Type.Kind())
+	}
+}
+
+// emitCborSizeStruct emits the CBORSize method for either struct
+// representation.
+func (g GenOptions) emitCborSizeStruct(w io.Writer, gti *GenTypeInfo, mapRepr bool) error {
+	header := len(gti.TupleHeader())
+	if mapRepr {
+		header = len(gti.MapHeader())
+	}
+
+	data := struct {
+		*GenTypeInfo
+		Header int
+	}{gti, header}
+	err := g.doTemplate(w, data, `func (t *{{ .Name }}) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := {{ .Header }}
+`)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range gti.Fields {
+		fmt.Fprintf(w, "\n\t// t.%s (%s) (%s)", f.Name, f.Type, f.Type.Kind())
+		fname := f.Name
+
+		if mapRepr {
+			if err := g.emitCborSizeStringField(w, Field{Name: `"` + f.MapKey + `"`}); err != nil {
+				return err
+			}
+		}
+
+		f.Name = "t." + f.Name
+		if err := g.emitCborSizeField(w, f); err != nil {
+			return &FieldError{Type: gti.Name, Field: fname, Err: err}
+		}
+	}
+
+	fmt.Fprintf(w, "\treturn n\n}\n\n")
+	return nil
+}
@@ -0,0 +1,207 @@
+package typegen
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type optionsType struct {
+	Long  string
+	Short map[string]optionsType
+	Data  []byte
+	List  []uint64
+}
+
+func TestGenOptions(t *testing.T) {
+	opts := GenOptions{
+		MaxLength:      100,
+		MaxByteLength:  200,
+		MaxMapLength:   300,
+		CanonicalOrder: true,
+		Strict:         true,
+		HeaderComment:  "first line\nsecond line",
+		BuildTags:      []string{"linux", "!js"},
+		ExtraImports:   []Import{{Name: "_", PkgPath: "embed"}},
+	}
+
+	out, err := opts.GenerateMapEncoders("typegen", optionsType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(out)
+
+	for _, expected := range []string{
+		"DO NOT EDIT.\n// first line\n// second line\n",
+		"//go:build linux && !js\n// +build linux,!js\n\npackage typegen",
+		`_ "embed"`,
+		"if len(t.Long) > 100 {",
+		"cbg.ReadStringWithMax(cr, 100)",
+		"if len(t.Data) > 200 {",
+		"if len(t.List) > 100 {",
+		"if len(t.Short) > 300 {",
+		"return len(keys[i]) < len(keys[j])",
+		`return fmt.Errorf("optionsType: unknown field %q", name)`,
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("expected generated code to contain %q", expected)
+		}
+	}
+
+	for _, unexpected := range []string{"cbg.MaxLength", "cbg.ByteArrayMaxLen", "4096", "sort.Strings(keys)"} {
+		if strings.Contains(code, unexpected) {
+			t.Errorf("generated code should not contain %q", unexpected)
+		}
+	}
+
+	// Fields are written shortest name first, then in lexical order.
+	data := strings.Index(code, "// t.Data")
+	long := strings.Index(code, "// t.Long")
+	short := strings.Index(code, "// t.Short")
+	if data < 0 || data > long || long > short {
+		t.Error("expected map fields in canonical order")
+	}
+}
+
+func TestGenOptionsZeroValue(t *testing.T) {
+	def, err := GenerateMapEncoders("typegen", optionsType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero, err := GenOptions{}.GenerateMapEncoders("typegen", optionsType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(def) != string(zero) {
+		t.Fatal("zero GenOptions should generate the same code as the package level functions")
+	}
+}
+
+func TestGenOptionsMaxLength(t *testing.T) {
+	// Strings longer than the default MaxLength round trip when allowed.
+	opts := GenOptions{MaxLength: 10000}
+	in := optionsType{Long: strings.Repeat("a", 9000)}
+	enc, err := opts.Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out optionsType
+	if err := opts.Unmarshal(enc, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Long != in.Long {
+		t.Fatal("the string did not round trip")
+	}
+
+	var tooLong *ErrTooLong
+	if err := (GenOptions{}).Unmarshal(enc, &out); !errors.As(err, &tooLong) || tooLong.Limit != MaxLength {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestGenJSONCodecs(t *testing.T) {
+	out, err := GenOptions{JSONCodecs: true, Strict: true}.GenerateTupleEncoders("typegen", optionsType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(out)
+
+	for _, expected := range []string{
+		"func (t *optionsType) MarshalJSON() ([]byte, error) {",
+		"func (t *optionsType) UnmarshalJSON(b []byte) error {",
+		`b = cbg.AppendJSONBytes(b, t.Data[:])`,
+		`sort.Strings(keys)`,
+		`return fmt.Errorf("optionsType: unknown field %q", key)`,
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("expected generated code to contain %q", expected)
+		}
+	}
+
+	// Fields are written in DAG-JSON key order whatever the representation.
+	data := strings.Index(code, `"\"Data\":"`)
+	list := strings.Index(code, `",\"List\":"`)
+	long := strings.Index(code, `",\"Long\":"`)
+	if data < 0 || data > list || list > long {
+		t.Error("expected JSON fields in key order")
+	}
+}
+
+type badFieldsType struct {
+	Strings []string
+	Chan    chan int
+	Good    uint64
+	Keys    map[int]optionsType
+	Tag     string `cborgen:"maxlen=lots"`
+	Stream  []byte `cborgen:",foreach"`
+}
+
+type otherBadType struct {
+	Float float64
+}
+
+func TestGenCollectsAllFieldErrors(t *testing.T) {
+	_, err := GenerateTupleEncoders("typegen", badFieldsType{}, optionsType{}, otherBadType{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	errs, ok := err.(GenErrors)
+	if !ok {
+		t.Fatalf("expected GenErrors, got %T: %s", err, err)
+	}
+
+	expected := []string{
+		"badFieldsType.Tag",
+		"badFieldsType.Stream",
+		"badFieldsType.Strings",
+		"badFieldsType.Chan",
+		"badFieldsType.Keys",
+		"otherBadType.Float",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %s", len(expected), len(errs), err)
+	}
+	for i, field := range expected {
+		var ferr *FieldError
+		if !errors.As(errs[i], &ferr) {
+			t.Fatalf("expected a FieldError, got %T", errs[i])
+		}
+		if ferr.Type+"."+ferr.Field != field {
+			t.Errorf("error %d: expected field %s, got %s", i, field, errs[i])
+		}
+	}
+}
+
+func TestTagparseForEach(t *testing.T) {
+	tags, err := tagparse(",foreach, maxlen=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tags[ForEachTag]; !ok {
+		t.Error("expected the foreach flag to be set")
+	}
+	if _, ok := tags["name"]; ok {
+		t.Error("foreach should not rename the field")
+	}
+
+	tags, err = tagparse("renamed,foreach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags["name"] != "renamed" {
+		t.Errorf("expected the field to be renamed, got %q", tags["name"])
+	}
+
+	// In the name's position, foreach is a name like any other.
+	tags, err = tagparse("foreach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tags[ForEachTag]; ok {
+		t.Error("expected the foreach flag not to be set")
+	}
+	if tags["name"] != "foreach" {
+		t.Errorf("expected the field to be renamed to foreach, got %q", tags["name"])
+	}
+}
@@ -1,7 +1,10 @@
 package typegen
 
 import (
+	"bufio"
+	"bytes"
 	"io"
+	"io/ioutil"
 )
 
 var (
@@ -11,7 +14,18 @@ var (
 
 type CborReader struct {
 	r    BytePeeker
-	hbuf []byte
+	hbuf [maxHeaderSize]byte
+
+	// slice is set when reading from a byte slice, see NewCborReaderBytes.
+	slice *sliceReader
+	sr    sliceReader
+
+	// off counts the bytes read from r, unless reading from a slice.
+	off int64
+
+	opts  DecodeOptions
+	depth int
+	alloc int64
 }
 
 func NewCborReader(r io.Reader) *CborReader {
@@ -20,25 +34,134 @@ func NewCborReader(r io.Reader) *CborReader {
 	}
 
 	return &CborReader{
-		r:    GetPeeker(r),
-		hbuf: make([]byte, maxHeaderSize),
+		r: GetPeeker(r),
 	}
 }
 
+// NewCborReaderBytes returns a CborReader that reads from b. Byte strings read
+// with ReadByteSlice alias b instead of being copied, so b must not be
+// modified while they are in use.
+func NewCborReaderBytes(b []byte) *CborReader {
+	cr := &CborReader{}
+	cr.sr.buf = b
+	cr.sr.end = len(b)
+	cr.slice = &cr.sr
+	cr.r = cr.slice
+	return cr
+}
+
 func (cr *CborReader) Read(p []byte) (n int, err error) {
-	return cr.r.Read(p)
+	n, err = cr.r.Read(p)
+	cr.off += int64(n)
+	return n, err
 }
 
 func (cr *CborReader) ReadByte() (byte, error) {
-	return cr.r.ReadByte()
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.off++
+	}
+	return b, err
 }
 
 func (cr *CborReader) UnreadByte() error {
-	return cr.r.UnreadByte()
+	err := cr.r.UnreadByte()
+	if err == nil {
+		cr.off--
+	}
+	return err
 }
 
+// ReadHeader reads a header and returns its major type and argument. Unless
+// the reader's options allow it, the argument must be in its shortest
+// encoding.
 func (cr *CborReader) ReadHeader() (byte, uint64, error) {
-	return CborReadHeaderBuf(cr.r, cr.hbuf)
+	if !cr.opts.AllowNonMinimal {
+		return CborReadHeaderBuf(cr, cr.hbuf[:])
+	}
+
+	maj, _, extra, err := readLenientHeader(cr, cr.hbuf[:], DecodeOptions{AllowNonMinimal: true})
+	return maj, extra, err
+}
+
+// ReadArrayHeader reads the header of an array and returns the number of
+// elements that follow it. Unlike the generated decoders it places no limit on
+// the length, so callers can decode arrays of any size one element at a time.
+func (cr *CborReader) ReadArrayHeader() (uint64, error) {
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return 0, err
+	}
+	if maj != MajArray {
+		return 0, &ErrWrongMajorType{Want: MajArray, Got: maj}
+	}
+	return extra, nil
+}
+
+// ReadByteSlice reads the next n bytes. If the reader was created with
+// NewCborReaderBytes the result aliases its input, otherwise it is a newly
+// allocated slice.
+func (cr *CborReader) ReadByteSlice(n uint64) ([]byte, error) {
+	if cr.slice != nil {
+		return cr.slice.next(n)
+	}
+
+	if err := cr.Allocate(n); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(cr, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Offset returns the number of bytes read from the input since the reader was
+// created. Bytes given back with UnreadByte are not counted.
+func (cr *CborReader) Offset() int64 {
+	if cr.slice != nil {
+		return int64(cr.slice.off)
+	}
+	return cr.off
+}
+
+// discard skips the next n bytes, counting those that are skipped even if
+// the input ends first.
+func (cr *CborReader) discard(n int) error {
+	if cr.slice != nil {
+		return cr.slice.discard(n)
+	}
+
+	switch r := cr.r.(type) {
+	case *bytes.Buffer, *bytes.Reader:
+		l := r.(interface{ Len() int })
+		before := l.Len()
+		err := discard(r, n)
+		cr.off += int64(before - l.Len())
+		return err
+	case *bufio.Reader:
+		discarded, err := r.Discard(n)
+		cr.off += int64(discarded)
+		if discarded != 0 && discarded < n && err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	default:
+		discarded, err := io.CopyN(ioutil.Discard, cr, int64(n))
+		if discarded != 0 && discarded < int64(n) && err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+}
+
+// Remaining returns the unread part of the input of a reader created with
+// NewCborReaderBytes. It returns nil for other readers.
+func (cr *CborReader) Remaining() []byte {
+	if cr.slice == nil {
+		return nil
+	}
+	return cr.slice.buf[cr.slice.off:]
 }
 
 var (
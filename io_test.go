@@ -0,0 +1,67 @@
+package typegen
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCborReaderOffset(t *testing.T) {
+	// "abc", 300, then four more bytes.
+	input := []byte{0x63, 'a', 'b', 'c', 0x19, 0x01, 0x2c, 0x01, 0x02, 0x03, 0x04, 0x05}
+
+	readers := map[string]*CborReader{
+		"bytes.Buffer":  NewCborReader(bytes.NewBuffer(input)),
+		"bytes.Reader":  NewCborReader(bytes.NewReader(input)),
+		"bufio.Reader":  NewCborReader(bufio.NewReader(bytes.NewReader(input))),
+		"io.Reader":     NewCborReader(iotest.OneByteReader(bytes.NewReader(input))),
+		"slice":         NewCborReaderBytes(input),
+		"limited":       NewCborReaderWithOptions(bytes.NewReader(input), DecodeOptions{MaxBytes: 100}),
+		"limited slice": NewCborReaderBytesWithOptions(input, DecodeOptions{MaxBytes: 100}),
+	}
+
+	for name, cr := range readers {
+		expect := func(step string, offset int64) {
+			t.Helper()
+			if got := cr.Offset(); got != offset {
+				t.Fatalf("%s: expected offset %d after %s, got %d", name, offset, step, got)
+			}
+		}
+
+		if _, _, err := cr.ReadHeader(); err != nil {
+			t.Fatal(err)
+		}
+		expect("ReadHeader", 1)
+		if _, err := cr.ReadByteSlice(3); err != nil {
+			t.Fatal(err)
+		}
+		expect("ReadByteSlice", 4)
+		if _, _, err := CborReadHeader(cr); err != nil {
+			t.Fatal(err)
+		}
+		expect("CborReadHeader", 7)
+		if _, err := readByte(cr); err != nil {
+			t.Fatal(err)
+		}
+		expect("readByte", 8)
+		if err := cr.UnreadByte(); err != nil {
+			t.Fatal(err)
+		}
+		expect("UnreadByte", 7)
+		var scratch [1]byte
+		if _, err := readByteBuf(cr, scratch[:]); err != nil {
+			t.Fatal(err)
+		}
+		expect("readByteBuf", 8)
+		if err := discard(cr, 2); err != nil {
+			t.Fatal(err)
+		}
+		expect("discard", 10)
+		if err := discard(cr, 5); err != io.ErrUnexpectedEOF {
+			t.Fatalf("%s: expected io.ErrUnexpectedEOF, got %v", name, err)
+		}
+		expect("discarding past the end", 12)
+	}
+}
@@ -0,0 +1,130 @@
+package typegen
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The functions in this file are used by the MarshalJSON and UnmarshalJSON
+// methods generated with GenOptions.JSONCodecs. They write byte strings and
+// Deferred values the way CBORToDagJSON does, and leave everything else to
+// encoding/json.
+
+// AppendJSON appends the JSON encoding of v to dst, as json.Marshal does.
+func AppendJSON(dst []byte, v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, b...), nil
+}
+
+// AppendJSONBytes appends b to dst in the DAG-JSON form for bytes,
+// {"/":{"bytes":"<unpadded base64>"}}.
+func AppendJSONBytes(dst []byte, b []byte) []byte {
+	dst = append(dst, `{"/":{"bytes":"`...)
+	n := len(dst)
+	dst = append(dst, make([]byte, base64.RawStdEncoding.EncodedLen(len(b)))...)
+	base64.RawStdEncoding.Encode(dst[n:], b)
+	return append(dst, `"}}`...)
+}
+
+// AppendJSONDeferred appends the CBOR in d to dst as DAG-JSON, or null if d
+// is nil.
+func AppendJSONDeferred(dst []byte, d *Deferred) ([]byte, error) {
+	if d == nil {
+		return append(dst, "null"...), nil
+	}
+	if d.Raw == nil {
+		return nil, errors.New("cannot marshal Deferred with nil value for Raw (will not unmarshal)")
+	}
+	buf := bytes.NewBuffer(dst)
+	if err := CBORToDagJSON(bytes.NewReader(d.Raw), buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IsJSONNull reports whether data is the JSON null.
+func IsJSONNull(data []byte) bool {
+	return string(bytes.TrimSpace(data)) == "null"
+}
+
+// ReadJSON decodes the JSON in data into v, as json.Unmarshal does.
+func ReadJSON(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ReadJSONBytes decodes bytes in the DAG-JSON form written by
+// AppendJSONBytes. It returns nil for null.
+func ReadJSONBytes(data []byte) ([]byte, error) {
+	if IsJSONNull(data) {
+		return nil, nil
+	}
+
+	var outer map[string]json.RawMessage
+	if err := json.Unmarshal(data, &outer); err != nil {
+		return nil, fmt.Errorf("expected DAG-JSON bytes: %w", err)
+	}
+	var inner map[string]string
+	if slash, ok := outer["/"]; ok && len(outer) == 1 {
+		if err := json.Unmarshal(slash, &inner); err != nil {
+			return nil, fmt.Errorf("expected DAG-JSON bytes: %w", err)
+		}
+	}
+	s, ok := inner["bytes"]
+	if !ok || len(inner) != 1 {
+		return nil, fmt.Errorf(`expected DAG-JSON bytes, of the form {"/":{"bytes":"..."}}`)
+	}
+
+	b, err := base64.RawStdEncoding.DecodeString(trimBase64Padding(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DAG-JSON bytes: %w", err)
+	}
+	return b, nil
+}
+
+// ReadJSONDeferred converts DAG-JSON in data to a Deferred holding its CBOR
+// encoding, as DagJSONToCBOR does.
+func ReadJSONDeferred(data []byte) (*Deferred, error) {
+	var buf bytes.Buffer
+	if err := DagJSONToCBOR(bytes.NewReader(data), &buf); err != nil {
+		return nil, err
+	}
+	return &Deferred{Raw: buf.Bytes()}, nil
+}
+
+// ReadJSONArray splits a JSON array into its elements. It returns nil for
+// null.
+func ReadJSONArray(data []byte) ([]json.RawMessage, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+// ReadJSONObject calls fn with each key and value of a JSON object, in key
+// order. null is read as an empty object.
+func ReadJSONObject(data []byte, fn func(key string, value []byte) error) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, obj[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
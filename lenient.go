@@ -0,0 +1,288 @@
+package typegen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// normFrame is an item normalizeValue is in the middle of.
+type normFrame struct {
+	maj        byte
+	indefinite bool
+
+	// nested is set for arrays, maps and tags, which count towards the
+	// reader's MaxDepth.
+	nested bool
+
+	// remaining is the number of items left in a definite length item, and
+	// count the number read so far of an indefinite length one: elements of
+	// arrays, keys and values of maps, and bytes of strings.
+	remaining uint64
+	count     uint64
+
+	// start is where the space reserved in the output for the header of an
+	// indefinite length item begins. The header is written there once its
+	// length is known.
+	start int
+}
+
+// normGap is unused space left in the output of normalizeValue after a
+// header written in the space reserved for it.
+type normGap struct {
+	off, n int
+}
+
+// lenientOptions returns the options of br if it is a CborReader whose
+// options relax what headers it accepts.
+func lenientOptions(br io.Reader) (DecodeOptions, bool) {
+	cr, ok := br.(*CborReader)
+	if !ok || !(cr.opts.AllowIndefinite || cr.opts.AllowNonMinimal) {
+		return DecodeOptions{}, false
+	}
+	return cr.opts, true
+}
+
+// readLenientHeader reads a header like CborReadHeaderBuf, but accepts what
+// opts allow: the indefinite length indicator and the break code, for which
+// it returns a low of 31, and arguments not in their shortest encoding.
+func readLenientHeader(br io.Reader, scratch []byte, opts DecodeOptions) (maj byte, low byte, extra uint64, err error) {
+	maj, low, extra, err = readHeaderAnyLength(br, scratch)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	switch {
+	case low == 31:
+		if !opts.AllowIndefinite {
+			return 0, 0, 0, fmt.Errorf("invalid header: (%x)", maj<<5|low)
+		}
+	case maj == MajOther && low == 24 && extra < 32:
+		return 0, 0, 0, fmt.Errorf("invalid simple value %d in two bytes", extra)
+	case !opts.AllowNonMinimal && !headerIsShortest(low, extra):
+		return 0, 0, 0, fmt.Errorf("%w (argument %d not in its shortest encoding)", ErrNonCanonical, extra)
+	}
+	return maj, low, extra, nil
+}
+
+// writeHeaderLow writes a header with the same additional information low it
+// was read with, so that floats keep their width.
+func writeHeaderLow(buf *bytes.Buffer, maj byte, low byte, extra uint64) {
+	var b [maxHeaderSize]byte
+	b[0] = maj<<5 | low
+	switch low {
+	case 24:
+		b[1] = byte(extra)
+		buf.Write(b[:2])
+	case 25:
+		binary.BigEndian.PutUint16(b[1:], uint16(extra))
+		buf.Write(b[:3])
+	case 26:
+		binary.BigEndian.PutUint32(b[1:], uint32(extra))
+		buf.Write(b[:5])
+	case 27:
+		binary.BigEndian.PutUint64(b[1:], extra)
+		buf.Write(b[:9])
+	default:
+		buf.Write(b[:1])
+	}
+}
+
+// normalizeValue copies a single CBOR value from br to buf, accepting the
+// headers opts allow. Indefinite length strings, arrays and maps are
+// rewritten to definite length ones, and the arguments of other headers to
+// their shortest encoding, except those of floats which keep their width.
+// Strings may be no longer than ByteArrayMaxLen, and arrays and maps have no
+// more than MaxLength items, as in Deferred. If br is a CborReader, its
+// MaxDepth and MaxAlloc apply too, with the bytes written to buf charged to
+// its allocation budget.
+func normalizeValue(br io.Reader, buf *bytes.Buffer, opts DecodeOptions) error {
+	cr, _ := br.(*CborReader)
+	var depth int
+	defer func() {
+		for ; depth > 0; depth-- {
+			cr.LeaveNested()
+		}
+	}()
+
+	scratch := make([]byte, maxHeaderSize)
+	limitedReader := io.LimitedReader{R: br}
+
+	var gaps []normGap
+	stack := []normFrame{{remaining: 1}}
+	push := func(f normFrame) error {
+		if f.nested && cr != nil {
+			if err := cr.EnterNested(); err != nil {
+				return err
+			}
+			depth++
+		}
+		stack = append(stack, f)
+		return nil
+	}
+	pop := func() {
+		if stack[len(stack)-1].nested && cr != nil {
+			cr.LeaveNested()
+			depth--
+		}
+		stack = stack[:len(stack)-1]
+	}
+	writeHeader := func(maj byte, low byte, extra uint64) error {
+		n := buf.Len()
+		if maj == MajOther {
+			writeHeaderLow(buf, maj, low, extra)
+		} else if err := WriteMajorTypeHeaderBuf(scratch, buf, maj, extra); err != nil {
+			return err
+		}
+		return allocate(br, uint64(buf.Len()-n))
+	}
+
+	first := true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if !top.indefinite && top.remaining == 0 {
+			pop()
+			continue
+		}
+
+		maj, low, extra, err := readLenientHeader(br, scratch, opts)
+		if err == io.EOF && !first {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		first = false
+
+		if maj == MajOther && low == 31 {
+			if !top.indefinite {
+				return fmt.Errorf("unexpected break")
+			}
+			n := top.count
+			if top.maj == MajMap {
+				if n%2 != 0 {
+					return fmt.Errorf("indefinite length map has a key without a value")
+				}
+				n /= 2
+			}
+
+			// Write the header in the space reserved for it, and leave
+			// the rest of that space to be removed at the end.
+			hdr := AppendMajorTypeHeader(scratch[:0], top.maj, n)
+			copy(buf.Bytes()[top.start:], hdr)
+			gaps = append(gaps, normGap{off: top.start + len(hdr), n: maxHeaderSize - len(hdr)})
+			pop()
+			continue
+		}
+
+		isString := maj == MajByteString || maj == MajTextString
+		if top.indefinite && (top.maj == MajByteString || top.maj == MajTextString) {
+			// Only the contents of the chunks are kept.
+			if maj != top.maj || low == 31 {
+				return fmt.Errorf("invalid chunk of major type %d in indefinite length string of major type %d", maj, top.maj)
+			}
+			if extra > ByteArrayMaxLen-top.count {
+				return &ErrTooLong{Length: top.count + extra, Limit: ByteArrayMaxLen}
+			}
+			top.count += extra
+		} else {
+			if top.indefinite {
+				top.count++
+				limit := uint64(MaxLength)
+				if top.maj == MajMap {
+					limit *= 2
+				}
+				if top.count > limit {
+					return &ErrTooLong{Length: top.count, Limit: limit}
+				}
+			} else {
+				top.remaining--
+			}
+			if low == 31 {
+				if err := allocate(br, maxHeaderSize); err != nil {
+					return err
+				}
+				f := normFrame{maj: maj, indefinite: true, nested: !isString, start: buf.Len()}
+				if err := push(f); err != nil {
+					return err
+				}
+				var reserved [maxHeaderSize]byte
+				buf.Write(reserved[:])
+				continue
+			}
+			if err := writeHeader(maj, low, extra); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case isString:
+			if extra > ByteArrayMaxLen {
+				return &ErrTooLong{Length: extra, Limit: ByteArrayMaxLen}
+			}
+			if err := allocate(br, extra); err != nil {
+				return err
+			}
+			limitedReader.N = int64(extra)
+			buf.Grow(int(extra))
+			if n, err := buf.ReadFrom(&limitedReader); err != nil {
+				return err
+			} else if n < int64(extra) {
+				return io.ErrUnexpectedEOF
+			}
+		case maj == MajTag:
+			if err := push(normFrame{nested: true, remaining: 1}); err != nil {
+				return err
+			}
+		case maj == MajArray:
+			if extra > MaxLength {
+				return &ErrTooLong{Length: extra, Limit: MaxLength}
+			}
+			if err := push(normFrame{nested: true, remaining: extra}); err != nil {
+				return err
+			}
+		case maj == MajMap:
+			if extra > MaxLength {
+				return &ErrTooLong{Length: extra, Limit: MaxLength}
+			}
+			if err := push(normFrame{nested: true, remaining: extra * 2}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Remove the gaps, which were found innermost first, in one pass.
+	if len(gaps) > 0 {
+		sort.Slice(gaps, func(i, j int) bool {
+			return gaps[i].off < gaps[j].off
+		})
+		b := buf.Bytes()
+		w := gaps[0].off
+		for i, g := range gaps {
+			end := len(b)
+			if i+1 < len(gaps) {
+				end = gaps[i+1].off
+			}
+			w += copy(b[w:], b[g.off+g.n:end])
+		}
+		buf.Truncate(w)
+	}
+	return nil
+}
+
+// NormalizeIndefinite rewrites the indefinite length strings, arrays and maps
+// in the single CBOR value b to definite length ones, concatenating the
+// chunks of strings. Everything else is copied as is. b must otherwise be
+// valid as ValidateCBOR checks it.
+func NormalizeIndefinite(b []byte) ([]byte, error) {
+	br := bytes.NewReader(b)
+	buf := bytes.NewBuffer(make([]byte, 0, len(b)))
+	if err := normalizeValue(br, buf, DecodeOptions{AllowIndefinite: true}); err != nil {
+		return nil, err
+	}
+	if br.Len() > 0 {
+		return nil, fmt.Errorf("unexpected %d unread bytes", br.Len())
+	}
+	return buf.Bytes(), nil
+}
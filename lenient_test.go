@@ -0,0 +1,194 @@
+package typegen
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestNormalizeIndefinite(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+	}{
+		{`[_ 1, [2], {_ "a": (_ h'01', h'', h'0203')}]`, `[1, [2], {"a": h'010203'}]`},
+		{`(_ "ab", "c")`, `"abc"`},
+		{`''_`, `''`},
+		{`{_ }`, `{}`},
+		{`1([_ 1.5_1, 2.5_3])`, `1([1.5_1, 2.5_3])`},
+		{`[1, 2]`, `[1, 2]`},
+	} {
+		got, err := NormalizeIndefinite(MustParseDiagnostic(tc.in))
+		if err != nil {
+			t.Errorf("%s: %s", tc.in, err)
+			continue
+		}
+		if want := MustParseDiagnostic(tc.out); !bytes.Equal(got, want) {
+			t.Errorf("%s: expected %x, got %x", tc.in, want, got)
+		}
+	}
+
+	for _, in := range [][]byte{
+		{0xff},
+		{0x9f, 0x01},
+		{0xbf, 0x01, 0xff},
+		{0x5f, 0x61, 0x61, 0xff},
+		{0x5f, 0x5f, 0xff, 0xff},
+		{0x81, 0x01, 0xff},
+		{0x9f, 0xff, 0x01},
+		{0x18, 0x01},
+	} {
+		if _, err := NormalizeIndefinite(in); err == nil {
+			t.Errorf("%x: expected an error", in)
+		}
+	}
+}
+
+func TestAllowIndefinite(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	var link bytes.Buffer
+	if err := WriteCid(&link, c); err != nil {
+		t.Fatal(err)
+	}
+	in := append([]byte{0xbf, 0x61, 0x61, 0x9f}, link.Bytes()...)
+	in = append(in, 0xff, 0xff)
+	want, err := NormalizeIndefinite(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateCBOR(in); err == nil {
+		t.Fatal("expected indefinite length items to be rejected by default")
+	}
+	var d Deferred
+	if err := d.UnmarshalCBOR(NewCborReaderBytes(in)); err == nil {
+		t.Fatal("expected indefinite length items to be rejected by default")
+	}
+
+	opts := DecodeOptions{AllowIndefinite: true}
+	if err := ValidateCBORWithOptions(in, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UnmarshalCBOR(NewCborReaderBytesWithOptions(in, opts)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d.Raw, want) {
+		t.Fatalf("expected %x, got %x", want, d.Raw)
+	}
+
+	var links []cid.Cid
+	if err := ScanForLinks(NewCborReaderWithOptions(bytes.NewReader(in), opts), func(c cid.Cid) {
+		links = append(links, c)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || !links[0].Equals(c) {
+		t.Fatalf("expected to find %s, got %v", c, links)
+	}
+
+	var paths []string
+	if err := ScanForLinksPath(NewCborReaderBytesWithOptions(in, opts), ScanOptions{}, func(path string, _ cid.Cid) error {
+		paths = append(paths, path)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "a/0" {
+		t.Fatalf("expected the link at a/0, got %v", paths)
+	}
+}
+
+func TestAllowNonMinimal(t *testing.T) {
+	in := MustParseDiagnostic(`[1_1, "a"_0, 0.0_3, {_ }]`)
+	opts := DecodeOptions{AllowNonMinimal: true}
+
+	if _, _, err := NewCborReaderBytes(in[1:]).ReadHeader(); !errors.Is(err, ErrNonCanonical) {
+		t.Fatalf("expected ErrNonCanonical by default, got %v", err)
+	}
+	maj, extra, err := NewCborReaderBytesWithOptions(in[1:], opts).ReadHeader()
+	if err != nil || maj != MajUnsignedInt || extra != 1 {
+		t.Fatalf("expected 1, got %d, %d, %v", maj, extra, err)
+	}
+	tok, err := NewCborReaderBytesWithOptions(in[1:], opts).Next()
+	if err != nil || tok.Type != TokenUint || tok.Value != 1 {
+		t.Fatalf("expected 1, got %+v, %v", tok, err)
+	}
+
+	// Indefinite length items are allowed separately.
+	var d Deferred
+	if err := d.UnmarshalCBOR(NewCborReaderBytesWithOptions(in, opts)); err == nil {
+		t.Fatal("expected the indefinite length map to be rejected")
+	}
+	if err := ValidateCBORWithOptions(in, opts); err == nil {
+		t.Fatal("expected the indefinite length map to be rejected")
+	}
+
+	// Headers are rewritten to the shortest encoding, except for floats.
+	opts.AllowIndefinite = true
+	if err := d.UnmarshalCBOR(NewCborReaderBytesWithOptions(in, opts)); err != nil {
+		t.Fatal(err)
+	}
+	if want := MustParseDiagnostic(`[1, "a", 0.0_3, {}]`); !bytes.Equal(d.Raw, want) {
+		t.Fatalf("expected %x, got %x", want, d.Raw)
+	}
+	if err := ValidateCBORWithOptions(in, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, in := range [][]byte{
+		{0xf8, 0x14},
+		{0x1f},
+	} {
+		if _, _, err := NewCborReaderBytesWithOptions(in, opts).ReadHeader(); err == nil {
+			t.Errorf("%x: expected an error", in)
+		}
+	}
+}
+
+func TestAllowIndefiniteLimits(t *testing.T) {
+	// Headers of different sizes, nested.
+	in := []byte{0x9f, 0x9f}
+	want := []byte{0x82, 0x98, 30}
+	for i := 0; i < 30; i++ {
+		in = append(in, 0x01)
+		want = append(want, 0x01)
+	}
+	in = append(in, 0xff, 0x7f, 0x61, 'x', 0xff, 0xff)
+	want = append(want, 0x61, 'x')
+	got, err := NormalizeIndefinite(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %x, got %x", want, got)
+	}
+
+	const depth = 100000
+	deep := append(bytes.Repeat([]byte{0x9f}, depth), bytes.Repeat([]byte{0xff}, depth)...)
+	got, err = NormalizeIndefinite(deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(bytes.Repeat([]byte{0x81}, depth-1), 0x80); !bytes.Equal(got, want) {
+		t.Fatal("deeply nested arrays normalized wrong")
+	}
+
+	var d Deferred
+	opts := DecodeOptions{AllowIndefinite: true, MaxDepth: 10}
+	if err := d.UnmarshalCBOR(NewCborReaderBytesWithOptions(deep, opts)); err != ErrDecodeTooDeep {
+		t.Fatalf("expected ErrDecodeTooDeep, got %v", err)
+	}
+	cr := NewCborReaderBytesWithOptions(in, opts)
+	if err := d.UnmarshalCBOR(cr); err != nil {
+		t.Fatal(err)
+	}
+	if cr.depth != 0 {
+		t.Fatalf("expected the depth to be restored, got %d", cr.depth)
+	}
+
+	opts = DecodeOptions{AllowIndefinite: true, MaxAlloc: 16}
+	if err := d.UnmarshalCBOR(NewCborReaderBytesWithOptions(in, opts)); err != ErrDecodeAllocLimit {
+		t.Fatalf("expected ErrDecodeAllocLimit, got %v", err)
+	}
+}
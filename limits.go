@@ -0,0 +1,154 @@
+package typegen
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	ErrDecodeTooDeep    = errors.New("decode: input nested too deeply")
+	ErrDecodeReadLimit  = errors.New("decode: input exceeds read limit")
+	ErrDecodeAllocLimit = errors.New("decode: input exceeds allocation budget")
+)
+
+// MaxNesting is how deeply arrays, maps and tags may be nested in the input
+// of functions such as Canonicalize that walk it recursively. They return
+// ErrDecodeTooDeep for input nested any deeper.
+const MaxNesting = 1024
+
+// DecodeOptions limits the resources used decoding from a CborReader, on top
+// of the per item limits of the generated decoders, and relaxes what input it
+// accepts. Zero values mean no limit, and the strictest input.
+//
+// MaxBytes is enforced by the reader itself. MaxDepth and MaxAlloc are
+// enforced by decoders generated with GenOptions.DecodeLimits, and by the
+// runtime helpers that allocate, such as ReadString and Deferred.
+type DecodeOptions struct {
+	// MaxDepth is how deeply generated types may be nested in the input.
+	MaxDepth int
+
+	// MaxBytes is the most bytes that may be read from the input.
+	MaxBytes int64
+
+	// MaxAlloc is the most bytes that may be allocated for decoded strings,
+	// byte strings, slices and maps, in total.
+	MaxAlloc int64
+
+	// AllowIndefinite lets Deferred, ScanForLinks and ScanForLinksPath, and
+	// so the fields generated decoders skip, accept indefinite length
+	// strings, arrays and maps. Deferred stores them rewritten to definite
+	// length, as NormalizeIndefinite does. Generated decoders still reject
+	// them elsewhere.
+	AllowIndefinite bool
+
+	// AllowNonMinimal makes the reader accept headers whose argument is not
+	// in its shortest encoding, as some encoders other than DAG-CBOR ones
+	// write. Generated decoders then accept them too, and Deferred stores
+	// them rewritten to the shortest encoding.
+	AllowNonMinimal bool
+}
+
+// limitPeeker counts the bytes read through it and fails reads past limit.
+type limitPeeker struct {
+	r     BytePeeker
+	n     int64
+	limit int64
+}
+
+var _ BytePeeker = (*limitPeeker)(nil)
+
+func (lp *limitPeeker) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	remaining := lp.limit - lp.n
+	if remaining <= 0 {
+		return 0, ErrDecodeReadLimit
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := lp.r.Read(p)
+	lp.n += int64(n)
+	return n, err
+}
+
+func (lp *limitPeeker) ReadByte() (byte, error) {
+	if lp.n >= lp.limit {
+		return 0, ErrDecodeReadLimit
+	}
+	b, err := lp.r.ReadByte()
+	if err == nil {
+		lp.n++
+	}
+	return b, err
+}
+
+func (lp *limitPeeker) UnreadByte() error {
+	err := lp.r.UnreadByte()
+	if err == nil {
+		lp.n--
+	}
+	return err
+}
+
+// NewCborReaderWithOptions returns a CborReader for r that enforces opts.
+func NewCborReaderWithOptions(r io.Reader, opts DecodeOptions) *CborReader {
+	cr := &CborReader{r: GetPeeker(r), opts: opts}
+	if opts.MaxBytes > 0 {
+		cr.r = &limitPeeker{r: cr.r, limit: opts.MaxBytes}
+	}
+	return cr
+}
+
+// NewCborReaderBytesWithOptions is NewCborReaderBytes for a CborReader that
+// enforces opts.
+func NewCborReaderBytesWithOptions(b []byte, opts DecodeOptions) *CborReader {
+	cr := NewCborReaderBytes(b)
+	cr.opts = opts
+	if opts.MaxBytes > 0 && int64(len(b)) > opts.MaxBytes {
+		cr.sr.end = int(opts.MaxBytes)
+		cr.sr.endErr = ErrDecodeReadLimit
+	}
+	return cr
+}
+
+// EnterNested is called by generated decoders as they start decoding a value,
+// and fails if that nests values deeper than the reader's MaxDepth. Each call
+// that succeeds must be matched by a call to LeaveNested.
+func (cr *CborReader) EnterNested() error {
+	if cr.opts.MaxDepth > 0 && cr.depth >= cr.opts.MaxDepth {
+		return ErrDecodeTooDeep
+	}
+	cr.depth++
+	return nil
+}
+
+// LeaveNested is called by generated decoders as they finish decoding a value.
+func (cr *CborReader) LeaveNested() {
+	cr.depth--
+}
+
+// Allocate charges n bytes to the reader's allocation budget, and fails once
+// MaxAlloc is exceeded. Decoders call it before allocating memory whose size
+// comes from the input.
+func (cr *CborReader) Allocate(n uint64) error {
+	if cr.opts.MaxAlloc <= 0 {
+		return nil
+	}
+	if n > uint64(cr.opts.MaxAlloc-cr.alloc) {
+		cr.alloc = cr.opts.MaxAlloc
+		return ErrDecodeAllocLimit
+	}
+	cr.alloc += int64(n)
+	return nil
+}
+
+// allocate charges n bytes to the allocation budget of br if it is a
+// CborReader, for helpers that take any reader.
+func allocate(br io.Reader, n uint64) error {
+	if cr, ok := br.(*CborReader); ok {
+		return cr.Allocate(n)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package typegen
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeReadLimit(t *testing.T) {
+	input := []byte{0x01, 0x02, 0x03, 0x04}
+	opts := DecodeOptions{MaxBytes: 3}
+
+	for _, cr := range []*CborReader{
+		NewCborReaderWithOptions(bytes.NewReader(input), opts),
+		NewCborReaderBytesWithOptions(input, opts),
+	} {
+		if _, err := readByte(cr); err != nil {
+			t.Fatal(err)
+		}
+		if err := discard(cr, 1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cr.ReadByteSlice(2); err != ErrDecodeReadLimit {
+			t.Fatalf("expected ErrDecodeReadLimit, got %v", err)
+		}
+	}
+}
+
+func TestDecodeAllocBudget(t *testing.T) {
+	cr := NewCborReaderWithOptions(bytes.NewReader(nil), DecodeOptions{MaxAlloc: 10})
+	if err := cr.Allocate(6); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.Allocate(4); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.Allocate(1); err != ErrDecodeAllocLimit {
+		t.Fatalf("expected ErrDecodeAllocLimit, got %v", err)
+	}
+
+	// Helpers that take any reader charge a CborReader's budget.
+	in := AppendByteString(nil, make([]byte, 10))
+	if _, err := ReadByteArray(NewCborReaderBytesWithOptions(in, DecodeOptions{MaxAlloc: 9}), 10); err != ErrDecodeAllocLimit {
+		t.Fatalf("expected ErrDecodeAllocLimit, got %v", err)
+	}
+
+	// Without a budget nothing is counted.
+	if err := NewCborReader(bytes.NewReader(nil)).Allocate(1 << 62); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeMaxDepth(t *testing.T) {
+	cr := NewCborReaderWithOptions(bytes.NewReader(nil), DecodeOptions{MaxDepth: 2})
+	for i := 0; i < 2; i++ {
+		if err := cr.EnterNested(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cr.EnterNested(); err != ErrDecodeTooDeep {
+		t.Fatalf("expected ErrDecodeTooDeep, got %v", err)
+	}
+	cr.LeaveNested()
+	if err := cr.EnterNested(); err != nil {
+		t.Fatal(err)
+	}
+}
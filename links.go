@@ -0,0 +1,82 @@
+package typegen
+
+import (
+	"bytes"
+	"strconv"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// LinkWalker is implemented by types that can visit the CIDs they contain
+// without being marshaled first. Generated types implement it when
+// GenOptions.LinkWalkers is set.
+type LinkWalker interface {
+	ForEachLink(fn func(cid.Cid) error) error
+}
+
+// LinkPathWalker is LinkWalker that also reports where each CID was found.
+// Paths are the map keys of the fields and the indexes of slice elements
+// leading to the CID, appended to prefix and separated by "/".
+type LinkPathWalker interface {
+	ForEachLinkPath(prefix string, fn func(path string, c cid.Cid) error) error
+}
+
+// ForEachLink calls fn with every CID in v, stopping at the first error. Values
+// that don't implement LinkWalker are marshaled and scanned for links.
+func ForEachLink(v CBORMarshaler, fn func(cid.Cid) error) error {
+	if lw, ok := v.(LinkWalker); ok {
+		return lw.ForEachLink(fn)
+	}
+
+	return scanMarshaled(v, fn)
+}
+
+// ForEachLinkPath calls fn with every CID in v and its path, stopping at the
+// first error. Values that don't implement LinkPathWalker are marshaled and
+// scanned for links, which are all reported at prefix.
+func ForEachLinkPath(v CBORMarshaler, prefix string, fn func(path string, c cid.Cid) error) error {
+	if lw, ok := v.(LinkPathWalker); ok {
+		return lw.ForEachLinkPath(prefix, fn)
+	}
+
+	return scanMarshaled(v, func(c cid.Cid) error {
+		return fn(prefix, c)
+	})
+}
+
+func scanMarshaled(v CBORMarshaler, fn func(cid.Cid) error) error {
+	buf := new(bytes.Buffer)
+	if err := v.MarshalCBOR(buf); err != nil {
+		return err
+	}
+
+	return scanBytes(buf.Bytes(), fn)
+}
+
+// scanBytes calls fn with each link in the encoded value b until fn returns an
+// error.
+func scanBytes(b []byte, fn func(cid.Cid) error) error {
+	var fnErr error
+	err := ScanForLinks(bytes.NewReader(b), func(c cid.Cid) {
+		if fnErr == nil {
+			fnErr = fn(c)
+		}
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// JoinPath appends elem to the link path prefix.
+func JoinPath(prefix, elem string) string {
+	if prefix == "" {
+		return elem
+	}
+	return prefix + "/" + elem
+}
+
+// JoinPathIndex appends a slice index to the link path prefix.
+func JoinPathIndex(prefix string, i int) string {
+	return JoinPath(prefix, strconv.Itoa(i))
+}
@@ -0,0 +1,93 @@
+package typegen
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// linkList is a CBORMarshaler that doesn't implement LinkWalker.
+type linkList []cid.Cid
+
+func (l linkList) MarshalCBOR(w io.Writer) error {
+	if err := WriteMajorTypeHeader(w, MajArray, uint64(len(l))); err != nil {
+		return err
+	}
+	for _, c := range l {
+		if err := WriteCid(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestForEachLinkFallback(t *testing.T) {
+	c1, _ := cid.Parse("bafkqaaa")
+	c2, _ := cid.Parse("bafkqaab")
+	l := linkList{c1, c2}
+
+	var got []cid.Cid
+	if err := ForEachLink(l, func(c cid.Cid) error {
+		got = append(got, c)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []cid.Cid(l)) {
+		t.Fatalf("expected %v, got %v", l, got)
+	}
+
+	var paths []string
+	if err := ForEachLinkPath(l, "list", func(path string, c cid.Cid) error {
+		paths = append(paths, path)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(paths, []string{"list", "list"}) {
+		t.Fatalf("expected the links to be reported at the prefix, got %v", paths)
+	}
+
+	stop := errors.New("stop")
+	var calls int
+	if err := ForEachLink(l, func(cid.Cid) error {
+		calls++
+		return stop
+	}); err != stop || calls != 1 {
+		t.Fatalf("expected to stop after the first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDeferredForEachLink(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	var buf bytes.Buffer
+	if err := (linkList{c}).MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []*Deferred{{Raw: buf.Bytes()}, {}, nil} {
+		var got []cid.Cid
+		if err := d.ForEachLink(func(c cid.Cid) error {
+			got = append(got, c)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if d != nil && len(d.Raw) > 0 && !reflect.DeepEqual(got, []cid.Cid{c}) {
+			t.Fatalf("expected %v, got %v", c, got)
+		}
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	if p := JoinPath("", "a"); p != "a" {
+		t.Errorf("expected a, got %s", p)
+	}
+	if p := JoinPathIndex(JoinPath("a", "b"), 3); p != "a/b/3" {
+		t.Errorf("expected a/b/3, got %s", p)
+	}
+}
@@ -5,46 +5,131 @@ import (
 	"reflect"
 	"sort"
 	"strings"
-	"sync"
 )
 
 var (
-	knownPackageNamesMu sync.Mutex
-	pkgNameToPkgPath    = make(map[string]string)
-	pkgPathToPkgName    = make(map[string]string)
-
 	defaultImports = []Import{
 		{Name: "cbg", PkgPath: "github.com/whyrusleeping/cbor-gen"},
 		{Name: "xerrors", PkgPath: "golang.org/x/xerrors"},
 		{Name: "cid", PkgPath: "github.com/ipfs/go-cid"},
 	}
+
+	// stdImports are imported by every generated file without an alias, so
+	// their names can't be given to other packages.
+	stdImports = []Import{
+		{Name: "fmt", PkgPath: "fmt"},
+		{Name: "io", PkgPath: "io"},
+		{Name: "math", PkgPath: "math"},
+		{Name: "sort", PkgPath: "sort"},
+	}
 )
 
-func init() {
-	for _, imp := range defaultImports {
-		if was, conflict := pkgNameToPkgPath[imp.Name]; conflict {
-			panic(fmt.Sprintf("reused pkg name %s for %s and %s", imp.Name, imp.PkgPath, was))
+// importSet assigns package names to the packages referenced by a single
+// generated file. Names only depend on the set of packages in the file, not
+// on the order they are encountered in or on anything generated before.
+type importSet struct {
+	pkgNameToPkgPath map[string]string
+	pkgPathToPkgName map[string]string
+}
+
+// knownImports returns the imports whose names are fixed: those of every
+// generated file followed by the given extra imports.
+func knownImports(extra []Import) []Import {
+	var known []Import
+	known = append(known, stdImports...)
+	known = append(known, defaultImports...)
+	known = append(known, extra...)
+	return known
+}
+
+// checkImports returns an error for every extra import given a name already
+// used by another package.
+func checkImports(extra []Import) GenErrors {
+	var errs GenErrors
+	paths := make(map[string]string)
+	for _, imp := range knownImports(extra) {
+		if imp.Name == "_" || imp.Name == "." {
+			continue
+		}
+		if was, conflict := paths[imp.Name]; conflict && was != imp.PkgPath {
+			errs = append(errs, fmt.Errorf("import name %s is used for both %s and %s", imp.Name, was, imp.PkgPath))
+			continue
+		}
+		paths[imp.Name] = imp.PkgPath
+	}
+	return errs
+}
+
+// newImportSet allocates names for all packages referenced by the fields of
+// typeInfos. The default imports and the given extra imports keep their names,
+// other packages get their own name if it is free, or a numbered variant of it
+// otherwise. Clashing packages are numbered in import path order. The extra
+// imports must have passed checkImports.
+func newImportSet(typeInfos []*GenTypeInfo, extra []Import) *importSet {
+	s := &importSet{
+		pkgNameToPkgPath: make(map[string]string),
+		pkgPathToPkgName: make(map[string]string),
+	}
+
+	for _, imp := range knownImports(extra) {
+		if imp.Name == "_" || imp.Name == "." {
+			continue
 		}
-		if _, conflict := pkgPathToPkgName[imp.Name]; conflict {
-			panic(fmt.Sprintf("duplicate default import %s", imp.PkgPath))
+		s.pkgNameToPkgPath[imp.Name] = imp.PkgPath
+		s.pkgPathToPkgName[imp.PkgPath] = imp.Name
+	}
+
+	names := make(map[string]string)
+	for _, gti := range typeInfos {
+		for _, f := range gti.Fields {
+			if !f.needsImport() {
+				continue
+			}
+			collectPkgNames(f.Pkg, f.Type, names)
+		}
+	}
+
+	paths := make([]string, 0, len(names))
+	for path := range names {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		s.name(path, names[path])
+	}
+
+	return s
+}
+
+func collectPkgNames(currPkg string, t reflect.Type, names map[string]string) {
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Ptr:
+		collectPkgNames(currPkg, t.Elem(), names)
+	case reflect.Map:
+		collectPkgNames(currPkg, t.Key(), names)
+		collectPkgNames(currPkg, t.Elem(), names)
+	default:
+		path := t.PkgPath()
+		if path == "" || path == currPkg {
+			return
 		}
-		pkgNameToPkgPath[imp.Name] = imp.PkgPath
-		pkgPathToPkgName[imp.PkgPath] = imp.Name
+		names[path] = defaultPkgName(t.String())
 	}
 }
 
-func resolvePkgName(path, typeName string) string {
+func defaultPkgName(typeName string) string {
 	parts := strings.Split(typeName, ".")
 	if len(parts) != 2 {
 		panic(fmt.Sprintf("expected type to have a package name: %s", typeName))
 	}
-	defaultName := parts[0]
-
-	knownPackageNamesMu.Lock()
-	defer knownPackageNamesMu.Unlock()
+	return parts[0]
+}
 
+// name returns the name for the package at path, allocating one based on
+// defaultName if it doesn't have one yet.
+func (s *importSet) name(path, defaultName string) string {
 	// Check for a known name and use it.
-	if name, ok := pkgPathToPkgName[path]; ok {
+	if name, ok := s.pkgPathToPkgName[path]; ok {
 		return name
 	}
 
@@ -54,25 +139,39 @@ func resolvePkgName(path, typeName string) string {
 		if i > 0 {
 			tryName = fmt.Sprintf("%s%d", defaultName, i)
 		}
-		if _, taken := pkgNameToPkgPath[tryName]; !taken {
-			pkgNameToPkgPath[tryName] = path
-			pkgPathToPkgName[path] = tryName
+		if _, taken := s.pkgNameToPkgPath[tryName]; !taken {
+			s.pkgNameToPkgPath[tryName] = path
+			s.pkgPathToPkgName[path] = tryName
 			return tryName
 		}
 	}
+}
 
+// resolvePkgName returns the name used for the package of the named type t.
+// Without an import set, packages are referred to by their own name.
+func (s *importSet) resolvePkgName(t reflect.Type) string {
+	if s == nil {
+		return defaultPkgName(t.String())
+	}
+	return s.name(t.PkgPath(), defaultPkgName(t.String()))
 }
 
 type Import struct {
 	Name, PkgPath string
 }
 
+// ImportsForType returns the imports needed to refer to t from currPkg, using
+// each package's own name.
 func ImportsForType(currPkg string, t reflect.Type) []Import {
+	return (*importSet)(nil).importsForType(currPkg, t)
+}
+
+func (s *importSet) importsForType(currPkg string, t reflect.Type) []Import {
 	switch t.Kind() {
 	case reflect.Array, reflect.Slice, reflect.Ptr:
-		return ImportsForType(currPkg, t.Elem())
+		return s.importsForType(currPkg, t.Elem())
 	case reflect.Map:
-		return dedupImports(append(ImportsForType(currPkg, t.Key()), ImportsForType(currPkg, t.Elem())...))
+		return dedupImports(append(s.importsForType(currPkg, t.Key()), s.importsForType(currPkg, t.Elem())...))
 	default:
 		path := t.PkgPath()
 		if path == "" || path == currPkg {
@@ -80,7 +179,7 @@ func ImportsForType(currPkg string, t reflect.Type) []Import {
 			return nil
 		}
 
-		return []Import{{PkgPath: path, Name: resolvePkgName(path, t.String())}}
+		return []Import{{PkgPath: path, Name: s.resolvePkgName(t)}}
 	}
 }
 
@@ -0,0 +1,65 @@
+package typegen
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	texttemplate "text/template"
+)
+
+type textTemplateUser struct {
+	Text *texttemplate.Template
+}
+
+type bothTemplatesUser struct {
+	Text *texttemplate.Template
+	HTML *htmltemplate.Template
+}
+
+func TestImportAliasesArePerFile(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		both, err := GenerateTupleEncoders("typegen", bothTemplatesUser{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{
+			`template "html/template"`,
+			`template1 "text/template"`,
+			"new(template1.Template)",
+			"new(template.Template)",
+		} {
+			if !strings.Contains(string(both), expected) {
+				t.Errorf("expected generated code to contain %q", expected)
+			}
+		}
+
+		// A file that only uses text/template shouldn't be affected by
+		// the aliases allocated for the file above.
+		single, err := GenerateTupleEncoders("typegen", textTemplateUser{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(single), `template "text/template"`) {
+			t.Errorf("expected text/template to be imported as template:\n%s", single)
+		}
+	}
+}
+
+func TestExtraImportsPinAliases(t *testing.T) {
+	opts := GenOptions{
+		ExtraImports: []Import{{Name: "ttemplate", PkgPath: "text/template"}},
+	}
+	out, err := opts.GenerateTupleEncoders("typegen", bothTemplatesUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`template "html/template"`,
+		`ttemplate "text/template"`,
+		"new(ttemplate.Template)",
+	} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("expected generated code to contain %q", expected)
+		}
+	}
+}
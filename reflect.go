@@ -0,0 +1,811 @@
+package typegen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// Marshal encodes v, a struct or a pointer to one, in tuple representation
+// without generated code. The output is the same as that of the code
+// GenTupleEncodersForType generates, following the same rules and cborgen
+// struct tags. Values of types that implement CBORMarshaler, v itself or any
+// nested in it, are encoded with their MarshalCBOR method. Nested structs
+// that don't are encoded in tuple representation too.
+func Marshal(v interface{}) ([]byte, error) {
+	return GenOptions{}.Marshal(v)
+}
+
+// MarshalMap is Marshal for the map representation, as generated by
+// GenMapEncodersForType.
+func MarshalMap(v interface{}) ([]byte, error) {
+	return GenOptions{}.MarshalMap(v)
+}
+
+// Unmarshal decodes data, which must hold exactly one value, into v, a
+// pointer to a struct, like the UnmarshalCBOR method GenTupleEncodersForType
+// generates. Values of types that implement CBORUnmarshaler, v itself or any
+// nested in it, are decoded with their UnmarshalCBOR method.
+func Unmarshal(data []byte, v interface{}) error {
+	return GenOptions{}.Unmarshal(data, v)
+}
+
+// UnmarshalMap is Unmarshal for the map representation, as generated by
+// GenMapEncodersForType.
+func UnmarshalMap(data []byte, v interface{}) error {
+	return GenOptions{}.UnmarshalMap(data, v)
+}
+
+// Marshal is the package level Marshal with the limits, ordering and
+// decoding options of g.
+func (g GenOptions) Marshal(v interface{}) ([]byte, error) {
+	return reflectCodec{g: g}.marshal(v)
+}
+
+// MarshalMap is the package level MarshalMap with the options of g.
+func (g GenOptions) MarshalMap(v interface{}) ([]byte, error) {
+	return reflectCodec{g: g, mapRepr: true}.marshal(v)
+}
+
+// Unmarshal is the package level Unmarshal with the options of g.
+func (g GenOptions) Unmarshal(data []byte, v interface{}) error {
+	return reflectCodec{g: g}.unmarshal(data, v)
+}
+
+// UnmarshalMap is the package level UnmarshalMap with the options of g.
+func (g GenOptions) UnmarshalMap(data []byte, v interface{}) error {
+	return reflectCodec{g: g, mapRepr: true}.unmarshal(data, v)
+}
+
+// reflectType is the parsed form of a struct type without CBOR methods.
+type reflectType struct {
+	gti *GenTypeInfo
+	// index maps field names to their index in the struct.
+	index map[string]int
+}
+
+var reflectTypes sync.Map // reflect.Type -> *reflectType or error
+
+// getReflectType parses t, checking that code could be generated for it.
+func getReflectType(t reflect.Type) (*reflectType, error) {
+	if v, ok := reflectTypes.Load(t); ok {
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		return v.(*reflectType), nil
+	}
+
+	rt, err := parseReflectType(t)
+	if err != nil {
+		reflectTypes.Store(t, err)
+		return nil, err
+	}
+	reflectTypes.Store(t, rt)
+	return rt, nil
+}
+
+func parseReflectType(t reflect.Type) (*reflectType, error) {
+	gti, errs := parseTypeInfo(reflect.Zero(t).Interface())
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	gti.ensureImports()
+	if errs := (GenOptions{}).checkTypeInfos([]*GenTypeInfo{gti}); len(errs) > 0 {
+		return nil, errs
+	}
+
+	rt := &reflectType{gti: gti, index: make(map[string]int, len(gti.Fields))}
+	for _, f := range gti.Fields {
+		sf, _ := t.FieldByName(f.Name)
+		rt.index[f.Name] = sf.Index[0]
+	}
+	return rt, nil
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*CBORMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*CBORUnmarshaler)(nil)).Elem()
+)
+
+// reflectCodec encodes and decodes values with reflection the way generated
+// code does.
+type reflectCodec struct {
+	g       GenOptions
+	mapRepr bool
+}
+
+func (c reflectCodec) maxLen(val int) int {
+	if val > 0 {
+		return val
+	}
+	if c.g.MaxLength > 0 {
+		return c.g.MaxLength
+	}
+	return MaxLength
+}
+
+func (c reflectCodec) maxByteLen(val int) int {
+	if val > 0 {
+		return val
+	}
+	if c.g.MaxByteLength > 0 {
+		return c.g.MaxByteLength
+	}
+	return ByteArrayMaxLen
+}
+
+func (c reflectCodec) maxMapLen() int {
+	if c.g.MaxMapLength > 0 {
+		return c.g.MaxMapLength
+	}
+	return defaultMaxMapLength
+}
+
+func (c reflectCodec) marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || (rv.Kind() != reflect.Struct && (rv.Kind() != reflect.Ptr || rv.Type().Elem().Kind() != reflect.Struct)) {
+		return nil, fmt.Errorf("can only marshal structs, got %T", v)
+	}
+
+	var buf bytes.Buffer
+	cw := NewCborWriter(&buf)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		if m, ok := v.(CBORMarshaler); ok {
+			if err := m.MarshalCBOR(cw); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}
+		return append([]byte(nil), CborNull...), nil
+	}
+	if err := c.encodeStruct(cw, rv); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeStruct encodes v, a struct or a non-nil pointer to one.
+func (c reflectCodec) encodeStruct(cw *CborWriter, v reflect.Value) error {
+	if v.Kind() != reflect.Ptr {
+		if v.CanAddr() {
+			v = v.Addr()
+		} else {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p
+		}
+	}
+	if v.Type().Implements(marshalerType) {
+		return v.Interface().(CBORMarshaler).MarshalCBOR(cw)
+	}
+
+	v = v.Elem()
+	rt, err := getReflectType(v.Type())
+	if err != nil {
+		return err
+	}
+
+	if c.mapRepr {
+		if _, err := cw.Write(rt.gti.MapHeader()); err != nil {
+			return err
+		}
+		for _, f := range c.g.mapFieldOrder(rt.gti) {
+			if err := c.encodeString(cw, "k", f.MapKey, 0); err != nil {
+				return err
+			}
+			if err := c.encodeField(cw, f, "t."+f.Name, v.Field(rt.index[f.Name])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := cw.Write(rt.gti.TupleHeader()); err != nil {
+		return err
+	}
+	for _, f := range rt.gti.Fields {
+		if err := c.encodeField(cw, f, "t."+f.Name, v.Field(rt.index[f.Name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c reflectCodec) encodeString(cw *CborWriter, name string, s string, maxLen int) error {
+	if len(s) > c.maxLen(maxLen) {
+		return fmt.Errorf("Value in field %s was too long", name)
+	}
+	if err := cw.WriteMajorTypeHeader(MajTextString, uint64(len(s))); err != nil {
+		return err
+	}
+	_, err := cw.WriteString(s)
+	return err
+}
+
+func encodeInt(cw *CborWriter, i int64) error {
+	if i >= 0 {
+		return cw.WriteMajorTypeHeader(MajUnsignedInt, uint64(i))
+	}
+	return cw.WriteMajorTypeHeader(MajNegativeInt, uint64(-i-1))
+}
+
+// encodeField encodes v, the value of f, which is a pointer if f.Pointer is
+// set. name is used in errors.
+func (c reflectCodec) encodeField(cw *CborWriter, f Field, name string, v reflect.Value) error {
+	switch f.Type.Kind() {
+	case reflect.String:
+		return c.encodeString(cw, name, v.String(), f.MaxLen)
+	case reflect.Struct:
+		return c.encodeStructField(cw, f.Type, v)
+	case reflect.Uint64:
+		if f.Pointer {
+			if v.IsNil() {
+				_, err := cw.Write(CborNull)
+				return err
+			}
+			v = v.Elem()
+		}
+		return cw.WriteMajorTypeHeader(MajUnsignedInt, v.Uint())
+	case reflect.Uint8:
+		return cw.WriteMajorTypeHeader(MajUnsignedInt, v.Uint())
+	case reflect.Int64:
+		return encodeInt(cw, v.Int())
+	case reflect.Bool:
+		return WriteBool(cw, v.Bool())
+	case reflect.Array, reflect.Slice:
+		return c.encodeSlice(cw, f, name, v)
+	case reflect.Map:
+		return c.encodeMap(cw, name, v)
+	default:
+		return fmt.Errorf("unsupported kind %q", f.Type.Kind())
+	}
+}
+
+// encodeStructField encodes v, a value of struct type t or a pointer to one.
+func (c reflectCodec) encodeStructField(cw *CborWriter, t reflect.Type, v reflect.Value) error {
+	pointer := v.Kind() == reflect.Ptr
+
+	switch t {
+	case bigIntType:
+		if err := cw.CborWriteHeader(MajTag, 2); err != nil {
+			return err
+		}
+		var b []byte
+		if pointer && !v.IsNil() {
+			b = v.Interface().(*big.Int).Bytes()
+		} else if !pointer {
+			i := v.Interface().(big.Int)
+			b = i.Bytes()
+		}
+		if err := cw.CborWriteHeader(MajByteString, uint64(len(b))); err != nil {
+			return err
+		}
+		_, err := cw.Write(b)
+		return err
+	case cidType:
+		if pointer {
+			if v.IsNil() {
+				_, err := cw.Write(CborNull)
+				return err
+			}
+			v = v.Elem()
+		}
+		if err := WriteCid(cw, v.Interface().(cid.Cid)); err != nil {
+			return fmt.Errorf("failed to write cid field: %w", err)
+		}
+		return nil
+	}
+
+	if pointer && v.IsNil() {
+		// Generated MarshalCBOR methods write null for nil receivers.
+		if v.Type().Implements(marshalerType) {
+			return v.Interface().(CBORMarshaler).MarshalCBOR(cw)
+		}
+		_, err := cw.Write(CborNull)
+		return err
+	}
+	return c.encodeStruct(cw, v)
+}
+
+// bytesOf returns the bytes in v, a slice or array of a uint8 kind.
+func bytesOf(v reflect.Value) []byte {
+	if v.Kind() == reflect.Slice {
+		return v.Bytes()
+	}
+	b := make([]byte, v.Len())
+	for i := range b {
+		b[i] = byte(v.Index(i).Uint())
+	}
+	return b
+}
+
+func (c reflectCodec) encodeSlice(cw *CborWriter, f Field, name string, v reflect.Value) error {
+	e := f.Type.Elem()
+	if e.Kind() == reflect.Uint8 {
+		if v.Len() > c.maxByteLen(f.MaxLen) {
+			return fmt.Errorf("Byte array in field %s was too long", name)
+		}
+		if err := cw.WriteMajorTypeHeader(MajByteString, uint64(v.Len())); err != nil {
+			return err
+		}
+		_, err := cw.Write(bytesOf(v))
+		return err
+	}
+
+	if v.Len() > c.maxLen(f.MaxLen) {
+		return fmt.Errorf("Slice value in field %s was too long", name)
+	}
+	if err := cw.WriteMajorTypeHeader(MajArray, uint64(v.Len())); err != nil {
+		return err
+	}
+
+	pointer := e.Kind() == reflect.Ptr
+	if pointer {
+		e = e.Elem()
+	}
+	subf := Field{Type: e, Pointer: pointer}
+	for i := 0; i < v.Len(); i++ {
+		if err := c.encodeField(cw, subf, "v", v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c reflectCodec) encodeMap(cw *CborWriter, name string, v reflect.Value) error {
+	if v.Len() > c.maxMapLen() {
+		return fmt.Errorf("cannot marshal %s map too large", name)
+	}
+	if err := cw.WriteMajorTypeHeader(MajMap, uint64(v.Len())); err != nil {
+		return err
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		if c.g.CanonicalOrder {
+			return canonicalLess(keys[i].String(), keys[j].String())
+		}
+		return keys[i].String() < keys[j].String()
+	})
+
+	e := v.Type().Elem()
+	if e.Kind() == reflect.Ptr {
+		e = e.Elem()
+	}
+	for _, k := range keys {
+		if err := c.encodeString(cw, "k", k.String(), 0); err != nil {
+			return err
+		}
+		if err := c.encodeStructField(cw, e, v.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c reflectCodec) unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("can only unmarshal into non-nil pointers to structs, got %T", v)
+	}
+
+	cr := NewCborReaderBytes(data)
+	if err := c.decodeStruct(cr, rv); err != nil {
+		return err
+	}
+	if rest := cr.Remaining(); len(rest) > 0 {
+		return fmt.Errorf("unexpected %d unread bytes", len(rest))
+	}
+	return nil
+}
+
+// decodeStruct decodes into p, a non-nil pointer to a struct.
+func (c reflectCodec) decodeStruct(cr *CborReader, p reflect.Value) (err error) {
+	if p.Type().Implements(unmarshalerType) {
+		return p.Interface().(CBORUnmarshaler).UnmarshalCBOR(cr)
+	}
+
+	v := p.Elem()
+	rt, err := getReflectType(v.Type())
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.Zero(v.Type()))
+
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if !c.mapRepr {
+		if maj != MajArray {
+			return &ErrWrongMajorType{Want: MajArray, Got: maj}
+		}
+		if extra != uint64(len(rt.gti.Fields)) {
+			return &ErrWrongLength{Want: uint64(len(rt.gti.Fields)), Got: extra}
+		}
+		for _, f := range rt.gti.Fields {
+			if err := c.decodeField(cr, f, "t."+f.Name, v.Field(rt.index[f.Name])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if maj != MajMap {
+		return &ErrWrongMajorType{Want: MajMap, Got: maj}
+	}
+	if limit := uint64(c.maxLen(0)); extra > limit {
+		return fmt.Errorf("%s: %w", rt.gti.Name, &ErrTooLong{Length: extra, Limit: limit})
+	}
+
+	for i := uint64(0); i < extra; i++ {
+		name, err := ReadStringWithMax(cr, uint64(c.maxLen(0)))
+		if err != nil {
+			return err
+		}
+
+		f, ok := rt.fieldByKey(name)
+		if !ok {
+			if c.g.Strict {
+				return fmt.Errorf("%s: unknown field %q", rt.gti.Name, name)
+			}
+			// Field doesn't exist on this type, so ignore it
+			if err := ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.decodeField(cr, f, "t."+f.Name, v.Field(rt.index[f.Name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rt *reflectType) fieldByKey(key string) (Field, bool) {
+	for _, f := range rt.gti.Fields {
+		if f.MapKey == key {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// readNull reports whether the next value is null, consuming it if so.
+func readNull(cr *CborReader) (bool, error) {
+	b, err := cr.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	if b == CborNull[0] {
+		return true, nil
+	}
+	return false, cr.UnreadByte()
+}
+
+// decodeField decodes the value of f into v, which is a pointer if f.Pointer
+// is set. name is used in errors.
+func (c reflectCodec) decodeField(cr *CborReader, f Field, name string, v reflect.Value) error {
+	switch f.Type.Kind() {
+	case reflect.String:
+		s, err := ReadStringWithMax(cr, uint64(c.maxLen(f.MaxLen)))
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Struct:
+		return c.decodeStructField(cr, f.Type, name, v)
+	case reflect.Uint64:
+		if f.Pointer {
+			null, err := readNull(cr)
+			if err != nil || null {
+				return err
+			}
+			v.Set(reflect.New(f.Type))
+			v = v.Elem()
+		}
+		maj, extra, err := cr.ReadHeader()
+		if err != nil {
+			return err
+		}
+		if maj != MajUnsignedInt {
+			return &ErrWrongMajorType{Want: MajUnsignedInt, Got: maj}
+		}
+		v.SetUint(extra)
+		return nil
+	case reflect.Uint8:
+		maj, extra, err := cr.ReadHeader()
+		if err != nil {
+			return err
+		}
+		if maj != MajUnsignedInt {
+			return &ErrWrongMajorType{Want: MajUnsignedInt, Got: maj}
+		}
+		if extra > math.MaxUint8 {
+			return &ErrIntOverflow{Type: "uint8", Value: extra}
+		}
+		v.SetUint(extra)
+		return nil
+	case reflect.Int64:
+		i, err := decodeInt(cr)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Bool:
+		maj, extra, err := cr.ReadHeader()
+		if err != nil {
+			return err
+		}
+		if maj != MajOther {
+			return &ErrWrongMajorType{Want: MajOther, Got: maj}
+		}
+		switch extra {
+		case 20:
+			v.SetBool(false)
+		case 21:
+			v.SetBool(true)
+		default:
+			return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+		}
+		return nil
+	case reflect.Array, reflect.Slice:
+		return c.decodeSlice(cr, f, name, v)
+	case reflect.Map:
+		return c.decodeMap(cr, name, v)
+	default:
+		return fmt.Errorf("unsupported kind %q", f.Type.Kind())
+	}
+}
+
+func decodeInt(cr *CborReader) (int64, error) {
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return 0, err
+	}
+	extraI := int64(extra)
+	switch maj {
+	case MajUnsignedInt:
+		if extraI < 0 {
+			return 0, &ErrIntOverflow{Type: "int64", Value: extra}
+		}
+	case MajNegativeInt:
+		if extraI < 0 {
+			return 0, &ErrIntOverflow{Type: "int64", Value: extra, Negative: true}
+		}
+		extraI = -1 - extraI
+	default:
+		return 0, &ErrWrongMajorType{Want: MajUnsignedInt, Got: maj}
+	}
+	return extraI, nil
+}
+
+// decodeStructField decodes a value of struct type t into v, which is either
+// of type t or a pointer to it.
+func (c reflectCodec) decodeStructField(cr *CborReader, t reflect.Type, name string, v reflect.Value) error {
+	pointer := v.Kind() == reflect.Ptr
+
+	switch t {
+	case bigIntType:
+		maj, extra, err := cr.ReadHeader()
+		if err != nil {
+			return err
+		}
+		if maj != MajTag {
+			return &ErrWrongMajorType{Want: MajTag, Got: maj}
+		}
+		if extra != 2 {
+			return &ErrUnexpectedTag{Want: 2, Got: extra}
+		}
+		maj, extra, err = cr.ReadHeader()
+		if err != nil {
+			return err
+		}
+		if maj != MajByteString {
+			return &ErrWrongMajorType{Want: MajByteString, Got: maj}
+		}
+		if extra > 256 {
+			return fmt.Errorf("%s: %w", name, &ErrTooLong{Length: extra, Limit: 256})
+		}
+		i := big.NewInt(0)
+		if extra > 0 {
+			buf := make([]byte, extra)
+			if _, err := io.ReadFull(cr, buf); err != nil {
+				return err
+			}
+			i.SetBytes(buf)
+		}
+		if pointer {
+			v.Set(reflect.ValueOf(i))
+		} else {
+			v.Set(reflect.ValueOf(i).Elem())
+		}
+		return nil
+	case cidType:
+		if pointer {
+			null, err := readNull(cr)
+			if err != nil || null {
+				return err
+			}
+		}
+		ci, err := ReadCid(cr)
+		if err != nil {
+			return fmt.Errorf("failed to read cid field %s: %w", name, err)
+		}
+		if pointer {
+			v.Set(reflect.ValueOf(&ci))
+		} else {
+			v.Set(reflect.ValueOf(ci))
+		}
+		return nil
+	case deferredType:
+		// Deferred values keep nulls, so are always allocated.
+		if pointer {
+			v.Set(reflect.New(t))
+		} else {
+			v = v.Addr()
+		}
+		if err := c.decodeStruct(cr, v); err != nil {
+			return fmt.Errorf("failed to read deferred field: %w", err)
+		}
+		return nil
+	}
+
+	if !pointer {
+		if err := c.decodeStruct(cr, v.Addr()); err != nil {
+			return fmt.Errorf("unmarshaling %s: %w", name, err)
+		}
+		return nil
+	}
+
+	null, err := readNull(cr)
+	if err != nil || null {
+		return err
+	}
+	p := reflect.New(t)
+	if err := c.decodeStruct(cr, p); err != nil {
+		return fmt.Errorf("unmarshaling %s pointer: %w", name, err)
+	}
+	v.Set(p)
+	return nil
+}
+
+func (c reflectCodec) decodeSlice(cr *CborReader, f Field, name string, v reflect.Value) error {
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	isArray := f.Type.Kind() == reflect.Array
+	e := f.Type.Elem()
+	if e.Kind() == reflect.Uint8 {
+		if limit := uint64(c.maxByteLen(f.MaxLen)); extra > limit {
+			return fmt.Errorf("%s: %w", name, &ErrTooLong{Length: extra, Limit: limit})
+		}
+		if maj != MajByteString {
+			return &ErrWrongMajorType{Want: MajByteString, Got: maj}
+		}
+
+		switch {
+		case isArray:
+			if extra != uint64(f.Type.Len()) {
+				return fmt.Errorf("%s: %w", name, &ErrWrongLength{Want: uint64(f.Type.Len()), Got: extra})
+			}
+			v.Set(reflect.Zero(f.Type))
+		case c.g.ZeroCopyBytes:
+			if extra > 0 {
+				b, err := cr.ReadByteSlice(extra)
+				if err != nil {
+					return err
+				}
+				v.SetBytes(b)
+			}
+			return nil
+		case extra > 0:
+			if err := cr.Allocate(extra * allocSize(f.Type)); err != nil {
+				return err
+			}
+			v.Set(reflect.MakeSlice(f.Type, int(extra), int(extra)))
+		default:
+			return nil
+		}
+
+		_, err := io.ReadFull(cr, v.Slice(0, v.Len()).Bytes())
+		return err
+	}
+
+	if limit := uint64(c.maxLen(f.MaxLen)); extra > limit {
+		return fmt.Errorf("%s: %w", name, &ErrTooLong{Length: extra, Limit: limit})
+	}
+	if maj != MajArray {
+		return &ErrWrongMajorType{Want: MajArray, Got: maj}
+	}
+	if isArray {
+		if extra != uint64(f.Type.Len()) {
+			return fmt.Errorf("%s: %w", name, &ErrWrongLength{Want: uint64(f.Type.Len()), Got: extra})
+		}
+		v.Set(reflect.Zero(f.Type))
+	} else if extra > 0 {
+		if err := cr.Allocate(extra * allocSize(f.Type)); err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(f.Type, int(extra), int(extra)))
+	}
+
+	pointer := e.Kind() == reflect.Ptr
+	if pointer {
+		e = e.Elem()
+	}
+	subf := Field{Type: e, Pointer: pointer}
+	for i := 0; i < int(extra); i++ {
+		ev := v.Index(i)
+		if pointer && e.Kind() == reflect.Struct && e != cidType {
+			// Generated decoders don't accept nulls for these.
+			ev.Set(reflect.New(e))
+			ev = ev.Elem()
+		}
+		if err := c.decodeField(cr, subf, fmt.Sprintf("%s[%d]", name, i), ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c reflectCodec) decodeMap(cr *CborReader, name string, v reflect.Value) error {
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	if maj != MajMap {
+		return &ErrWrongMajorType{Want: MajMap, Got: maj}
+	}
+	if limit := uint64(c.maxMapLen()); extra > limit {
+		return fmt.Errorf("%s: %w", name, &ErrTooLong{Length: extra, Limit: limit})
+	}
+
+	t := v.Type()
+	if err := cr.Allocate(extra * allocSize(t)); err != nil {
+		return err
+	}
+	m := reflect.MakeMapWithSize(t, int(extra))
+	v.Set(m)
+
+	e := t.Elem()
+	for i := 0; i < int(extra); i++ {
+		k, err := ReadStringWithMax(cr, uint64(c.maxLen(0)))
+		if err != nil {
+			return err
+		}
+
+		ev := reflect.New(e).Elem()
+		st := e
+		if st.Kind() == reflect.Ptr {
+			st = st.Elem()
+		}
+		if err := c.decodeStructField(cr, st, "v", ev); err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), ev)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package typegen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// ErrStopScan can be returned by a ScanForLinksPath callback to stop the scan
+// early. ScanForLinksPath then returns nil.
+var ErrStopScan = errors.New("stop scanning for links")
+
+var (
+	ErrScanTooDeep      = errors.New("scan for links: input nested too deeply")
+	ErrScanTooManyLinks = errors.New("scan for links: input has too many links")
+)
+
+// ScanOptions limits the input accepted by ScanForLinksPath. Zero values mean
+// no limit.
+type ScanOptions struct {
+	// MaxDepth is the deepest arrays and maps may be nested. An array of maps
+	// has a depth of 2.
+	MaxDepth int
+
+	// MaxLinks is the most links the input may contain.
+	MaxLinks int
+}
+
+// scanFrame is an array or map being scanned.
+type scanFrame struct {
+	isMap     bool
+	remaining uint64
+
+	// The key of the map entry being scanned, or the number of array
+	// elements scanned so far.
+	key   string
+	index uint64
+}
+
+// ScanForLinksPath reads a single CBOR value from br and calls cb with each
+// link in it along with its path, made of the map keys and array indexes
+// leading to the link joined by "/". Map keys that aren't strings or integers
+// appear in the path as "?", and links inside them are not reported.
+//
+// If cb returns ErrStopScan the scan stops and ScanForLinksPath returns nil,
+// leaving the rest of the value unread. Any other error from cb is returned as
+// is. ErrScanTooDeep and ErrScanTooManyLinks are returned when the input
+// exceeds the limits in opts.
+func ScanForLinksPath(br io.Reader, opts ScanOptions, cb func(path string, c cid.Cid) error) (err error) {
+	if dopts, ok := lenientOptions(br); ok {
+		var buf bytes.Buffer
+		if err := normalizeValue(br, &buf, dopts); err != nil {
+			return err
+		}
+		return ScanForLinksPath(&buf, opts, cb)
+	}
+
+	hasReadOnce := false
+	defer func() {
+		if err == io.EOF && hasReadOnce {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	scratch := make([]byte, maxCidLength)
+	links := 0
+
+	// The bottom frame stands for the top level value, and has no path.
+	stack := []scanFrame{{remaining: 1}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.remaining == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		top.remaining--
+
+		if top.isMap {
+			key, err := readScanKey(br, scratch)
+			if err != nil {
+				return err
+			}
+			top.key = key
+		} else {
+			top.index++
+		}
+
+		maj, extra, err := CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		hasReadOnce = true
+
+		// Tags other than links apply to the next value.
+		for maj == MajTag && extra != 42 {
+			maj, extra, err = CborReadHeaderBuf(br, scratch)
+			if err != nil {
+				return err
+			}
+		}
+
+		switch maj {
+		case MajUnsignedInt, MajNegativeInt, MajOther:
+		case MajByteString, MajTextString:
+			if err := discard(br, int(extra)); err != nil {
+				return err
+			}
+		case MajTag:
+			c, err := readScanCid(br, scratch)
+			if err != nil {
+				return err
+			}
+
+			links++
+			if opts.MaxLinks > 0 && links > opts.MaxLinks {
+				return ErrScanTooManyLinks
+			}
+
+			if err := cb(scanPath(stack), c); err != nil {
+				if errors.Is(err, ErrStopScan) {
+					return nil
+				}
+				return err
+			}
+		case MajArray, MajMap:
+			if opts.MaxDepth > 0 && len(stack) > opts.MaxDepth {
+				return ErrScanTooDeep
+			}
+			stack = append(stack, scanFrame{isMap: maj == MajMap, remaining: extra})
+		default:
+			return fmt.Errorf("unhandled cbor type: %d", maj)
+		}
+	}
+	return nil
+}
+
+// scanPath joins the path segments of the elements being scanned.
+func scanPath(stack []scanFrame) string {
+	keys := make([]string, 0, len(stack)-1)
+	for _, f := range stack[1:] {
+		if f.isMap {
+			keys = append(keys, f.key)
+		} else {
+			keys = append(keys, strconv.FormatUint(f.index-1, 10))
+		}
+	}
+	return strings.Join(keys, "/")
+}
+
+// readScanCid reads the byte string of a link, after its tag.
+func readScanCid(br io.Reader, scratch []byte) (cid.Cid, error) {
+	maj, extra, err := CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	if maj != MajByteString {
+		return cid.Undef, &ErrWrongMajorType{Want: MajByteString, Got: maj}
+	}
+
+	if extra > maxCidLength {
+		return cid.Undef, &ErrTooLong{Length: extra, Limit: maxCidLength}
+	}
+
+	if _, err := io.ReadAtLeast(br, scratch[:extra], int(extra)); err != nil {
+		return cid.Undef, err
+	}
+
+	return bufToCid(scratch[:extra])
+}
+
+// readScanKey reads a map key and returns it as a path segment.
+func readScanKey(br io.Reader, scratch []byte) (string, error) {
+	maj, extra, err := CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return "", err
+	}
+
+	switch maj {
+	case MajTextString:
+		if extra > MaxLength {
+			return "", &ErrTooLong{Length: extra, Limit: MaxLength}
+		}
+		buf := make([]byte, extra)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	case MajUnsignedInt:
+		return strconv.FormatUint(extra, 10), nil
+	case MajNegativeInt:
+		if extra == 1<<64-1 {
+			return "-18446744073709551616", nil
+		}
+		return "-" + strconv.FormatUint(extra+1, 10), nil
+	}
+
+	// Skip the rest of any other key.
+	for remaining := uint64(1); ; {
+		switch maj {
+		case MajByteString, MajTextString:
+			if err := discard(br, int(extra)); err != nil {
+				return "", err
+			}
+		case MajTag:
+			remaining++
+		case MajArray:
+			remaining += extra
+		case MajMap:
+			remaining += extra * 2
+		}
+
+		remaining--
+		if remaining == 0 {
+			return "?", nil
+		}
+
+		maj, extra, err = CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return "", err
+		}
+	}
+}
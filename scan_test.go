@@ -0,0 +1,112 @@
+package typegen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// scanInput encodes {"a": [c, 1, {"b": c}], 5: c, [1]: c}.
+func scanInput(t *testing.T, c cid.Cid) []byte {
+	var buf bytes.Buffer
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := func(s string) {
+		must(WriteMajorTypeHeader(&buf, MajTextString, uint64(len(s))))
+		buf.WriteString(s)
+	}
+
+	must(WriteMajorTypeHeader(&buf, MajMap, 3))
+	text("a")
+	must(WriteMajorTypeHeader(&buf, MajArray, 3))
+	must(WriteCid(&buf, c))
+	must(WriteMajorTypeHeader(&buf, MajUnsignedInt, 1))
+	must(WriteMajorTypeHeader(&buf, MajMap, 1))
+	text("b")
+	must(WriteCid(&buf, c))
+	must(WriteMajorTypeHeader(&buf, MajUnsignedInt, 5))
+	must(WriteCid(&buf, c))
+	must(WriteMajorTypeHeader(&buf, MajArray, 1))
+	must(WriteMajorTypeHeader(&buf, MajUnsignedInt, 1))
+	must(WriteCid(&buf, c))
+	return buf.Bytes()
+}
+
+func TestScanForLinksPath(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	input := scanInput(t, c)
+
+	var paths []string
+	if err := ScanForLinksPath(bytes.NewReader(input), ScanOptions{}, func(path string, got cid.Cid) error {
+		if got != c {
+			t.Fatalf("expected %s, got %s", c, got)
+		}
+		paths = append(paths, path)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a/0", "a/2/b", "5", "?"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected paths %v, got %v", expected, paths)
+	}
+}
+
+func TestScanForLinksPathLimits(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	input := scanInput(t, c)
+	ignore := func(string, cid.Cid) error { return nil }
+
+	for _, tc := range []struct {
+		opts ScanOptions
+		err  error
+	}{
+		{ScanOptions{MaxDepth: 3, MaxLinks: 4}, nil},
+		{ScanOptions{MaxDepth: 2}, ErrScanTooDeep},
+		{ScanOptions{MaxLinks: 3}, ErrScanTooManyLinks},
+	} {
+		if err := ScanForLinksPath(bytes.NewReader(input), tc.opts, ignore); err != tc.err {
+			t.Errorf("%+v: expected %v, got %v", tc.opts, tc.err, err)
+		}
+	}
+
+	if err := ScanForLinksPath(bytes.NewReader(input[:len(input)-1]), ScanOptions{}, ignore); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if err := ScanForLinksPath(bytes.NewReader(nil), ScanOptions{}, ignore); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestScanForLinksPathStop(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	r := bytes.NewReader(scanInput(t, c))
+
+	var calls int
+	if err := ScanForLinksPath(r, ScanOptions{}, func(string, cid.Cid) error {
+		calls++
+		return ErrStopScan
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the scan to stop after one link, got %d", calls)
+	}
+	if r.Len() == 0 {
+		t.Fatal("expected the scan to stop before the end of the input")
+	}
+	// ErrStopScan may be wrapped.
+	if err := ScanForLinksPath(bytes.NewReader(scanInput(t, c)), ScanOptions{}, func(string, cid.Cid) error {
+		return fmt.Errorf("found one: %w", ErrStopScan)
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
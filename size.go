@@ -0,0 +1,69 @@
+package typegen
+
+import (
+	"io"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// CBORSizer is implemented by types that can compute the exact length of
+// their CBOR encoding without marshaling.
+type CBORSizer interface {
+	CBORSize() int
+}
+
+// CborHeaderSize returns the encoded length of a header with the extra value
+// l, matching CborEncodeMajorType.
+func CborHeaderSize(l uint64) int {
+	switch {
+	case l < 24:
+		return 1
+	case l < (1 << 8):
+		return 2
+	case l < (1 << 16):
+		return 3
+	case l < (1 << 32):
+		return 5
+	default:
+		return 9
+	}
+}
+
+// CborIntSize returns the encoded length of v as a CBOR integer.
+func CborIntSize(v int64) int {
+	if v >= 0 {
+		return CborHeaderSize(uint64(v))
+	}
+	return CborHeaderSize(uint64(-v) - 1)
+}
+
+// CidSize returns the encoded length of c as written by WriteCid.
+func CidSize(c cid.Cid) int {
+	l := c.ByteLen() + 1
+	return CborHeaderSize(42) + CborHeaderSize(uint64(l)) + l
+}
+
+// SizeOf returns the encoded length of v. It uses v's CBORSize method if it
+// has one, and otherwise marshals v to count the bytes written.
+//
+// SizeOf has no error to return, as it backs the generated CBORSize methods.
+// If marshaling v fails, it returns the number of bytes written before the
+// failure, and the error is left to be returned when v is actually marshaled.
+func SizeOf(v CBORMarshaler) int {
+	if s, ok := v.(CBORSizer); ok {
+		return s.CBORSize()
+	}
+
+	var cw countingWriter
+	_ = v.MarshalCBOR(&cw)
+	return int(cw)
+}
+
+type countingWriter int
+
+var _ io.Writer = (*countingWriter)(nil)
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	*cw += countingWriter(len(p))
+	return len(p), nil
+}
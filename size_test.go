@@ -0,0 +1,59 @@
+package typegen
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestCborHeaderSize(t *testing.T) {
+	for _, l := range []uint64{0, 23, 24, math.MaxUint8, math.MaxUint8 + 1, math.MaxUint16, math.MaxUint16 + 1, math.MaxUint32, math.MaxUint32 + 1, math.MaxUint64} {
+		if size, expected := CborHeaderSize(l), len(CborEncodeMajorType(MajByteString, l)); size != expected {
+			t.Fatalf("header for length %d: expected size %d, got %d", l, expected, size)
+		}
+	}
+}
+
+func TestSizeOf(t *testing.T) {
+	c, err := cid.Parse("bafy2bzacecnamqgqmifpluoeldx7zzglxcljo6oja4vrmtj7432rphldpdmm2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []CBORMarshaler{
+		CborInt(-1000),
+		CborInt(math.MinInt64),
+		CborBool(true),
+		CborCid(c),
+		&Deferred{Raw: []byte{0x82, 0x01, 0x02}},
+		(*Deferred)(nil),
+	} {
+		var buf bytes.Buffer
+		if err := v.MarshalCBOR(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if size := SizeOf(v); size != buf.Len() {
+			t.Fatalf("%T(%v): expected size %d, got %d", v, v, buf.Len(), size)
+		}
+	}
+}
+
+// failingMarshaler writes n bytes and then fails.
+type failingMarshaler int
+
+func (fm failingMarshaler) MarshalCBOR(w io.Writer) error {
+	if _, err := w.Write(make([]byte, fm)); err != nil {
+		return err
+	}
+	return errors.New("marshal failed")
+}
+
+func TestSizeOfError(t *testing.T) {
+	if size := SizeOf(failingMarshaler(3)); size != 3 {
+		t.Fatalf("expected the 3 bytes written before the error, got %d", size)
+	}
+}
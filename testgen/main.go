@@ -1,12 +1,43 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+
 	cbg "github.com/whyrusleeping/cbor-gen"
 	types "github.com/whyrusleeping/cbor-gen/testing"
 )
 
+var check = flag.Bool("check", false, "check that the generated files are up to date instead of writing them")
+
 func main() {
-	if err := cbg.WriteTupleEncodersToFile("testing/cbor_gen.go", "testing",
+	flag.Parse()
+
+	tupleGen := cbg.GenOptions{
+		AppendEncoders: true,
+		SizeMethods:    true,
+		BytesDecoders:  true,
+		ZeroCopyBytes:  true,
+		LinkWalkers:    true,
+		DecodeLimits:   true,
+		ErrorContext:   true,
+		JSONCodecs:     true,
+	}
+	mapGen := cbg.GenOptions{
+		SizeMethods:  true,
+		LinkWalkers:  true,
+		DecodeLimits: true,
+		ErrorContext: true,
+		JSONCodecs:   true,
+	}
+
+	writeTuple, writeMap := tupleGen.WriteTupleEncodersToFile, mapGen.WriteMapEncodersToFile
+	if *check {
+		writeTuple, writeMap = tupleGen.CheckTupleEncodersFile, mapGen.CheckMapEncodersFile
+	}
+
+	if err := writeTuple("testing/cbor_gen.go", "testing",
 		types.SignedArray{},
 		types.SimpleTypeOne{},
 		types.SimpleTypeTwo{},
@@ -14,17 +45,28 @@ func main() {
 		types.FixedArrays{},
 		types.ThingWithSomeTime{},
 		types.BigField{},
+		types.LongString{},
+		types.LongLog{},
 	); err != nil {
-		panic(err)
+		fail(err)
 	}
 
-	if err := cbg.WriteMapEncodersToFile("testing/cbor_map_gen.go", "testing",
+	if err := writeMap("testing/cbor_map_gen.go", "testing",
 		types.SimpleTypeTree{},
 		types.NeedScratchForMap{},
 		types.SimpleStructV1{},
 		types.SimpleStructV2{},
 		types.RenamedFields{},
+		types.LongMapLog{},
 	); err != nil {
-		panic(err)
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	if *check {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	panic(err)
 }
@@ -32,6 +32,28 @@ func BenchmarkMarshaling(b *testing.B) {
 	}
 }
 
+func BenchmarkAppending(b *testing.B) {
+	r := rand.New(rand.NewSource(56887))
+	val, ok := quick.Value(reflect.TypeOf(SimpleTypeTwo{}), r)
+	if !ok {
+		b.Fatal("failed to construct type")
+	}
+
+	tt := val.Interface().(SimpleTypeTwo)
+
+	var buf []byte
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = tt.AppendCBOR(buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkUnmarshaling(b *testing.B) {
 	r := rand.New(rand.NewSource(123456))
 	val, ok := quick.Value(reflect.TypeOf(SimpleTypeTwo{}), r)
@@ -21,37 +21,48 @@ var _ = sort.Sort
 var lengthBufSignedArray = []byte{129}
 
 func (t *SignedArray) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
 		return err
 	}
 
-	cw := cbg.NewCborWriter(w)
+	_, err = w.Write(b)
+	return err
+}
 
-	if _, err := cw.Write(lengthBufSignedArray); err != nil {
-		return err
+func (t *SignedArray) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
 	}
 
+	b = append(b, lengthBufSignedArray...)
+
 	// t.Signed ([]uint64) (slice)
 	if len(t.Signed) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.Signed was too long")
+		return nil, xerrors.Errorf("Slice value in field t.Signed was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.Signed))); err != nil {
-		return err
-	}
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.Signed)))
 	for _, v := range t.Signed {
-		if err := cw.CborWriteHeader(cbg.MajUnsignedInt, uint64(v)); err != nil {
-			return err
-		}
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(v))
 	}
-	return nil
+	return b, nil
 }
 
 func (t *SignedArray) UnmarshalCBOR(r io.Reader) (err error) {
 	*t = SignedArray{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "SignedArray", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -64,14 +75,15 @@ func (t *SignedArray) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra != 1 {
-		return fmt.Errorf("cbor input had wrong number of fields")
+		return &cbg.ErrWrongLength{Want: 1, Got: extra}
 	}
 
 	// t.Signed ([]uint64) (slice)
+	pos = cbg.FieldPos{Field: "Signed"}
 
 	maj, extra, err = cr.ReadHeader()
 	if err != nil {
@@ -79,18 +91,23 @@ func (t *SignedArray) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("t.Signed: array too large (%d)", extra)
+		return fmt.Errorf("t.Signed: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra > 0 {
+		if err := cr.Allocate(extra * 8); err != nil {
+			return err
+		}
 		t.Signed = make([]uint64, extra)
 	}
 
 	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
 
 		maj, val, err := cr.ReadHeader()
 		if err != nil {
@@ -98,7 +115,7 @@ func (t *SignedArray) UnmarshalCBOR(r io.Reader) (err error) {
 		}
 
 		if maj != cbg.MajUnsignedInt {
-			return xerrors.Errorf("value read for array t.Signed was not a uint, instead got %d", maj)
+			return xerrors.Errorf("value read for array t.Signed: %w", &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj})
 		}
 
 		t.Signed[i] = uint64(val)
@@ -107,80 +124,148 @@ func (t *SignedArray) UnmarshalCBOR(r io.Reader) (err error) {
 	return nil
 }
 
+func (t *SignedArray) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.Signed ([]uint64) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.Signed)))
+	for _, v := range t.Signed {
+
+		n += cbg.CborHeaderSize(uint64(v))
+
+	}
+	return n
+}
+
+func (t *SignedArray) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+func (t *SignedArray) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *SignedArray) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *SignedArray) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Signed ([]uint64) (slice)
+	b = append(b, "\"Signed\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Signed); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *SignedArray) UnmarshalJSON(b []byte) error {
+	*t = SignedArray{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Signed ([]uint64) (slice)
+		case "Signed":
+
+			if err := cbg.ReadJSON(v, &t.Signed); err != nil {
+				return err
+			}
+			if len(t.Signed) > cbg.MaxLength {
+				return fmt.Errorf("t.Signed: %w", &cbg.ErrTooLong{Length: uint64(len(t.Signed)), Limit: cbg.MaxLength})
+			}
+
+		}
+		return nil
+	})
+}
+
 var lengthBufSimpleTypeOne = []byte{133}
 
 func (t *SimpleTypeOne) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
 		return err
 	}
 
-	cw := cbg.NewCborWriter(w)
+	_, err = w.Write(b)
+	return err
+}
 
-	if _, err := cw.Write(lengthBufSimpleTypeOne); err != nil {
-		return err
+func (t *SimpleTypeOne) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
 	}
 
+	b = append(b, lengthBufSimpleTypeOne...)
+
 	// t.Foo (string) (string)
 	if len(t.Foo) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.Foo was too long")
+		return nil, xerrors.Errorf("Value in field t.Foo was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Foo))); err != nil {
-		return err
-	}
-	if _, err := io.WriteString(w, string(t.Foo)); err != nil {
-		return err
-	}
+	b = cbg.AppendString(b, string(t.Foo))
 
 	// t.Value (uint64) (uint64)
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.Value)); err != nil {
-		return err
-	}
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(t.Value))
 
 	// t.Binary ([]uint8) (slice)
 	if len(t.Binary) > cbg.ByteArrayMaxLen {
-		return xerrors.Errorf("Byte array in field t.Binary was too long")
-	}
-
-	if err := cw.WriteMajorTypeHeader(cbg.MajByteString, uint64(len(t.Binary))); err != nil {
-		return err
+		return nil, xerrors.Errorf("Byte array in field t.Binary was too long")
 	}
 
-	if _, err := cw.Write(t.Binary[:]); err != nil {
-		return err
-	}
+	b = cbg.AppendByteString(b, t.Binary[:])
 
 	// t.Signed (int64) (int64)
-	if t.Signed >= 0 {
-		if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.Signed)); err != nil {
-			return err
-		}
-	} else {
-		if err := cw.WriteMajorTypeHeader(cbg.MajNegativeInt, uint64(-t.Signed-1)); err != nil {
-			return err
-		}
-	}
+	b = cbg.AppendInt64(b, int64(t.Signed))
 
 	// t.NString (testing.NamedString) (string)
 	if len(t.NString) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.NString was too long")
+		return nil, xerrors.Errorf("Value in field t.NString was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.NString))); err != nil {
-		return err
-	}
-	if _, err := io.WriteString(w, string(t.NString)); err != nil {
-		return err
-	}
-	return nil
+	b = cbg.AppendString(b, string(t.NString))
+	return b, nil
 }
 
 func (t *SimpleTypeOne) UnmarshalCBOR(r io.Reader) (err error) {
 	*t = SimpleTypeOne{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "SimpleTypeOne", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -193,17 +278,18 @@ func (t *SimpleTypeOne) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra != 5 {
-		return fmt.Errorf("cbor input had wrong number of fields")
+		return &cbg.ErrWrongLength{Want: 5, Got: extra}
 	}
 
 	// t.Foo (string) (string)
+	pos = cbg.FieldPos{Field: "Foo"}
 
 	{
-		sval, err := cbg.ReadString(cr)
+		sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 		if err != nil {
 			return err
 		}
@@ -211,6 +297,7 @@ func (t *SimpleTypeOne) UnmarshalCBOR(r io.Reader) (err error) {
 		t.Foo = string(sval)
 	}
 	// t.Value (uint64) (uint64)
+	pos = cbg.FieldPos{Field: "Value"}
 
 	{
 
@@ -219,12 +306,13 @@ func (t *SimpleTypeOne) UnmarshalCBOR(r io.Reader) (err error) {
 			return err
 		}
 		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
+			return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 		}
 		t.Value = uint64(extra)
 
 	}
 	// t.Binary ([]uint8) (slice)
+	pos = cbg.FieldPos{Field: "Binary"}
 
 	maj, extra, err = cr.ReadHeader()
 	if err != nil {
@@ -232,20 +320,21 @@ func (t *SimpleTypeOne) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.ByteArrayMaxLen {
-		return fmt.Errorf("t.Binary: byte array too large (%d)", extra)
+		return fmt.Errorf("t.Binary: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.ByteArrayMaxLen})
 	}
 	if maj != cbg.MajByteString {
-		return fmt.Errorf("expected byte array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
 	}
 
 	if extra > 0 {
-		t.Binary = make([]uint8, extra)
+		t.Binary, err = cr.ReadByteSlice(extra)
+		if err != nil {
+			return err
+		}
 	}
 
-	if _, err := io.ReadFull(cr, t.Binary[:]); err != nil {
-		return err
-	}
 	// t.Signed (int64) (int64)
+	pos = cbg.FieldPos{Field: "Signed"}
 	{
 		maj, extra, err := cr.ReadHeader()
 		var extraI int64
@@ -256,24 +345,25 @@ func (t *SimpleTypeOne) UnmarshalCBOR(r io.Reader) (err error) {
 		case cbg.MajUnsignedInt:
 			extraI = int64(extra)
 			if extraI < 0 {
-				return fmt.Errorf("int64 positive overflow")
+				return &cbg.ErrIntOverflow{Type: "int64", Value: extra}
 			}
 		case cbg.MajNegativeInt:
 			extraI = int64(extra)
 			if extraI < 0 {
-				return fmt.Errorf("int64 negative oveflow")
+				return &cbg.ErrIntOverflow{Type: "int64", Value: extra, Negative: true}
 			}
 			extraI = -1 - extraI
 		default:
-			return fmt.Errorf("wrong type for int64 field: %d", maj)
+			return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 		}
 
 		t.Signed = int64(extraI)
 	}
 	// t.NString (testing.NamedString) (string)
+	pos = cbg.FieldPos{Field: "NString"}
 
 	{
-		sval, err := cbg.ReadString(cr)
+		sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 		if err != nil {
 			return err
 		}
@@ -283,151 +373,272 @@ func (t *SimpleTypeOne) UnmarshalCBOR(r io.Reader) (err error) {
 	return nil
 }
 
+func (t *SimpleTypeOne) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.Foo (string) (string)
+	n += cbg.CborHeaderSize(uint64(len(t.Foo))) + len(t.Foo)
+
+	// t.Value (uint64) (uint64)
+
+	n += cbg.CborHeaderSize(uint64(t.Value))
+
+	// t.Binary ([]uint8) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.Binary))) + len(t.Binary)
+
+	// t.Signed (int64) (int64)
+	n += cbg.CborIntSize(int64(t.Signed))
+
+	// t.NString (testing.NamedString) (string)
+	n += cbg.CborHeaderSize(uint64(len(t.NString))) + len(t.NString)
+	return n
+}
+
+func (t *SimpleTypeOne) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+func (t *SimpleTypeOne) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *SimpleTypeOne) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *SimpleTypeOne) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Binary ([]uint8) (slice)
+	b = append(b, "\"Binary\":"...)
+
+	b = cbg.AppendJSONBytes(b, t.Binary[:])
+
+	// t.Foo (string) (string)
+	b = append(b, ",\"Foo\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Foo); err != nil {
+		return nil, err
+	}
+
+	// t.NString (testing.NamedString) (string)
+	b = append(b, ",\"NString\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.NString); err != nil {
+		return nil, err
+	}
+
+	// t.Signed (int64) (int64)
+	b = append(b, ",\"Signed\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Signed); err != nil {
+		return nil, err
+	}
+
+	// t.Value (uint64) (uint64)
+	b = append(b, ",\"Value\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Value); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *SimpleTypeOne) UnmarshalJSON(b []byte) error {
+	*t = SimpleTypeOne{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Foo (string) (string)
+		case "Foo":
+
+			if err := cbg.ReadJSON(v, &t.Foo); err != nil {
+				return err
+			}
+			if len(t.Foo) > cbg.MaxLength {
+				return fmt.Errorf("t.Foo: %w", &cbg.ErrTooLong{Length: uint64(len(t.Foo)), Limit: cbg.MaxLength})
+			}
+		// t.Value (uint64) (uint64)
+		case "Value":
+
+			if err := cbg.ReadJSON(v, &t.Value); err != nil {
+				return err
+			}
+		// t.Binary ([]uint8) (slice)
+		case "Binary":
+
+			{
+				bs, err := cbg.ReadJSONBytes(v)
+				if err != nil {
+					return err
+				}
+				if len(bs) > cbg.ByteArrayMaxLen {
+					return fmt.Errorf("t.Binary: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: cbg.ByteArrayMaxLen})
+				}
+				t.Binary = bs
+			}
+		// t.Signed (int64) (int64)
+		case "Signed":
+
+			if err := cbg.ReadJSON(v, &t.Signed); err != nil {
+				return err
+			}
+		// t.NString (testing.NamedString) (string)
+		case "NString":
+
+			if err := cbg.ReadJSON(v, &t.NString); err != nil {
+				return err
+			}
+			if len(t.NString) > cbg.MaxLength {
+				return fmt.Errorf("t.NString: %w", &cbg.ErrTooLong{Length: uint64(len(t.NString)), Limit: cbg.MaxLength})
+			}
+
+		}
+		return nil
+	})
+}
+
 var lengthBufSimpleTypeTwo = []byte{137}
 
 func (t *SimpleTypeTwo) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
 		return err
 	}
 
-	cw := cbg.NewCborWriter(w)
+	_, err = w.Write(b)
+	return err
+}
 
-	if _, err := cw.Write(lengthBufSimpleTypeTwo); err != nil {
-		return err
+func (t *SimpleTypeTwo) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
 	}
 
+	b = append(b, lengthBufSimpleTypeTwo...)
+
 	// t.Stuff (testing.SimpleTypeTwo) (struct)
-	if err := t.Stuff.MarshalCBOR(cw); err != nil {
-		return err
+	if b, err = cbg.AppendMarshaler(b, t.Stuff); err != nil {
+		return nil, err
 	}
 
 	// t.Others ([]uint64) (slice)
 	if len(t.Others) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.Others was too long")
+		return nil, xerrors.Errorf("Slice value in field t.Others was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.Others))); err != nil {
-		return err
-	}
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.Others)))
 	for _, v := range t.Others {
-		if err := cw.CborWriteHeader(cbg.MajUnsignedInt, uint64(v)); err != nil {
-			return err
-		}
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(v))
 	}
 
 	// t.SignedOthers ([]int64) (slice)
 	if len(t.SignedOthers) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.SignedOthers was too long")
+		return nil, xerrors.Errorf("Slice value in field t.SignedOthers was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.SignedOthers))); err != nil {
-		return err
-	}
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.SignedOthers)))
 	for _, v := range t.SignedOthers {
-		if v >= 0 {
-			if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(v)); err != nil {
-				return err
-			}
-		} else {
-			if err := cw.WriteMajorTypeHeader(cbg.MajNegativeInt, uint64(-v-1)); err != nil {
-				return err
-			}
-		}
+		b = cbg.AppendInt64(b, int64(v))
 	}
 
 	// t.Test ([][]uint8) (slice)
 	if len(t.Test) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.Test was too long")
+		return nil, xerrors.Errorf("Slice value in field t.Test was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.Test))); err != nil {
-		return err
-	}
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.Test)))
 	for _, v := range t.Test {
 		if len(v) > cbg.ByteArrayMaxLen {
-			return xerrors.Errorf("Byte array in field v was too long")
+			return nil, xerrors.Errorf("Byte array in field v was too long")
 		}
 
-		if err := cw.WriteMajorTypeHeader(cbg.MajByteString, uint64(len(v))); err != nil {
-			return err
-		}
-
-		if _, err := cw.Write(v[:]); err != nil {
-			return err
-		}
+		b = cbg.AppendByteString(b, v[:])
 	}
 
 	// t.Dog (string) (string)
 	if len(t.Dog) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.Dog was too long")
+		return nil, xerrors.Errorf("Value in field t.Dog was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Dog))); err != nil {
-		return err
-	}
-	if _, err := io.WriteString(w, string(t.Dog)); err != nil {
-		return err
-	}
+	b = cbg.AppendString(b, string(t.Dog))
 
 	// t.Numbers ([]testing.NamedNumber) (slice)
 	if len(t.Numbers) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.Numbers was too long")
+		return nil, xerrors.Errorf("Slice value in field t.Numbers was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.Numbers))); err != nil {
-		return err
-	}
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.Numbers)))
 	for _, v := range t.Numbers {
-		if err := cw.CborWriteHeader(cbg.MajUnsignedInt, uint64(v)); err != nil {
-			return err
-		}
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(v))
 	}
 
 	// t.Pizza (uint64) (uint64)
 
 	if t.Pizza == nil {
-		if _, err := cw.Write(cbg.CborNull); err != nil {
-			return err
-		}
+		b = append(b, cbg.CborNull...)
 	} else {
-		if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(*t.Pizza)); err != nil {
-			return err
-		}
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(*t.Pizza))
 	}
 
 	// t.PointyPizza (testing.NamedNumber) (uint64)
 
 	if t.PointyPizza == nil {
-		if _, err := cw.Write(cbg.CborNull); err != nil {
-			return err
-		}
+		b = append(b, cbg.CborNull...)
 	} else {
-		if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(*t.PointyPizza)); err != nil {
-			return err
-		}
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(*t.PointyPizza))
 	}
 
 	// t.Arrrrrghay ([3]testing.SimpleTypeOne) (array)
 	if len(t.Arrrrrghay) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.Arrrrrghay was too long")
+		return nil, xerrors.Errorf("Slice value in field t.Arrrrrghay was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.Arrrrrghay))); err != nil {
-		return err
-	}
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.Arrrrrghay)))
 	for _, v := range t.Arrrrrghay {
-		if err := v.MarshalCBOR(cw); err != nil {
-			return err
+		if b, err = cbg.AppendMarshaler(b, &v); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	return b, nil
 }
 
 func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	*t = SimpleTypeTwo{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "SimpleTypeTwo", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -440,14 +651,15 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra != 9 {
-		return fmt.Errorf("cbor input had wrong number of fields")
+		return &cbg.ErrWrongLength{Want: 9, Got: extra}
 	}
 
 	// t.Stuff (testing.SimpleTypeTwo) (struct)
+	pos = cbg.FieldPos{Field: "Stuff"}
 
 	{
 
@@ -467,6 +679,7 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 
 	}
 	// t.Others ([]uint64) (slice)
+	pos = cbg.FieldPos{Field: "Others"}
 
 	maj, extra, err = cr.ReadHeader()
 	if err != nil {
@@ -474,18 +687,23 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("t.Others: array too large (%d)", extra)
+		return fmt.Errorf("t.Others: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra > 0 {
+		if err := cr.Allocate(extra * 8); err != nil {
+			return err
+		}
 		t.Others = make([]uint64, extra)
 	}
 
 	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
 
 		maj, val, err := cr.ReadHeader()
 		if err != nil {
@@ -493,13 +711,14 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 		}
 
 		if maj != cbg.MajUnsignedInt {
-			return xerrors.Errorf("value read for array t.Others was not a uint, instead got %d", maj)
+			return xerrors.Errorf("value read for array t.Others: %w", &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj})
 		}
 
 		t.Others[i] = uint64(val)
 	}
 
 	// t.SignedOthers ([]int64) (slice)
+	pos = cbg.FieldPos{Field: "SignedOthers"}
 
 	maj, extra, err = cr.ReadHeader()
 	if err != nil {
@@ -507,18 +726,23 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("t.SignedOthers: array too large (%d)", extra)
+		return fmt.Errorf("t.SignedOthers: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra > 0 {
+		if err := cr.Allocate(extra * 8); err != nil {
+			return err
+		}
 		t.SignedOthers = make([]int64, extra)
 	}
 
 	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
 		{
 			maj, extra, err := cr.ReadHeader()
 			var extraI int64
@@ -529,16 +753,16 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 			case cbg.MajUnsignedInt:
 				extraI = int64(extra)
 				if extraI < 0 {
-					return fmt.Errorf("int64 positive overflow")
+					return &cbg.ErrIntOverflow{Type: "int64", Value: extra}
 				}
 			case cbg.MajNegativeInt:
 				extraI = int64(extra)
 				if extraI < 0 {
-					return fmt.Errorf("int64 negative oveflow")
+					return &cbg.ErrIntOverflow{Type: "int64", Value: extra, Negative: true}
 				}
 				extraI = -1 - extraI
 			default:
-				return fmt.Errorf("wrong type for int64 field: %d", maj)
+				return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 			}
 
 			t.SignedOthers[i] = int64(extraI)
@@ -546,6 +770,7 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	// t.Test ([][]uint8) (slice)
+	pos = cbg.FieldPos{Field: "Test"}
 
 	maj, extra, err = cr.ReadHeader()
 	if err != nil {
@@ -553,18 +778,23 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("t.Test: array too large (%d)", extra)
+		return fmt.Errorf("t.Test: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra > 0 {
+		if err := cr.Allocate(extra * 24); err != nil {
+			return err
+		}
 		t.Test = make([][]uint8, extra)
 	}
 
 	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
 		{
 			var maj byte
 			var extra uint64
@@ -576,26 +806,27 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.Test[i]: byte array too large (%d)", extra)
+				return fmt.Errorf("t.Test[i]: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.ByteArrayMaxLen})
 			}
 			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
 			}
 
 			if extra > 0 {
-				t.Test[i] = make([]uint8, extra)
+				t.Test[i], err = cr.ReadByteSlice(extra)
+				if err != nil {
+					return err
+				}
 			}
 
-			if _, err := io.ReadFull(cr, t.Test[i][:]); err != nil {
-				return err
-			}
 		}
 	}
 
 	// t.Dog (string) (string)
+	pos = cbg.FieldPos{Field: "Dog"}
 
 	{
-		sval, err := cbg.ReadString(cr)
+		sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 		if err != nil {
 			return err
 		}
@@ -603,6 +834,7 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 		t.Dog = string(sval)
 	}
 	// t.Numbers ([]testing.NamedNumber) (slice)
+	pos = cbg.FieldPos{Field: "Numbers"}
 
 	maj, extra, err = cr.ReadHeader()
 	if err != nil {
@@ -610,18 +842,23 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("t.Numbers: array too large (%d)", extra)
+		return fmt.Errorf("t.Numbers: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra > 0 {
+		if err := cr.Allocate(extra * 8); err != nil {
+			return err
+		}
 		t.Numbers = make([]NamedNumber, extra)
 	}
 
 	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
 
 		maj, val, err := cr.ReadHeader()
 		if err != nil {
@@ -629,13 +866,14 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 		}
 
 		if maj != cbg.MajUnsignedInt {
-			return xerrors.Errorf("value read for array t.Numbers was not a uint, instead got %d", maj)
+			return xerrors.Errorf("value read for array t.Numbers: %w", &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj})
 		}
 
 		t.Numbers[i] = NamedNumber(val)
 	}
 
 	// t.Pizza (uint64) (uint64)
+	pos = cbg.FieldPos{Field: "Pizza"}
 
 	{
 
@@ -652,7 +890,7 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 				return err
 			}
 			if maj != cbg.MajUnsignedInt {
-				return fmt.Errorf("wrong type for uint64 field")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 			}
 			typed := uint64(extra)
 			t.Pizza = &typed
@@ -660,6 +898,7 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 
 	}
 	// t.PointyPizza (testing.NamedNumber) (uint64)
+	pos = cbg.FieldPos{Field: "PointyPizza"}
 
 	{
 
@@ -676,7 +915,7 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 				return err
 			}
 			if maj != cbg.MajUnsignedInt {
-				return fmt.Errorf("wrong type for uint64 field")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 			}
 			typed := NamedNumber(extra)
 			t.PointyPizza = &typed
@@ -684,6 +923,7 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 
 	}
 	// t.Arrrrrghay ([3]testing.SimpleTypeOne) (array)
+	pos = cbg.FieldPos{Field: "Arrrrrghay"}
 
 	maj, extra, err = cr.ReadHeader()
 	if err != nil {
@@ -691,20 +931,22 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("t.Arrrrrghay: array too large (%d)", extra)
+		return fmt.Errorf("t.Arrrrrghay: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra != 3 {
-		return fmt.Errorf("expected array to have 3 elements")
+		return fmt.Errorf("t.Arrrrrghay: %w", &cbg.ErrWrongLength{Want: 3, Got: extra})
 	}
 
 	t.Arrrrrghay = [3]SimpleTypeOne{}
 
 	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
 
 		var v SimpleTypeOne
 		if err := v.UnmarshalCBOR(cr); err != nil {
@@ -717,43 +959,368 @@ func (t *SimpleTypeTwo) UnmarshalCBOR(r io.Reader) (err error) {
 	return nil
 }
 
-var lengthBufDeferredContainer = []byte{131}
-
-func (t *DeferredContainer) MarshalCBOR(w io.Writer) error {
+func (t *SimpleTypeTwo) CBORSize() int {
 	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
+		return len(cbg.CborNull)
 	}
 
-	cw := cbg.NewCborWriter(w)
+	n := 1
 
-	if _, err := cw.Write(lengthBufDeferredContainer); err != nil {
-		return err
-	}
+	// t.Stuff (testing.SimpleTypeTwo) (struct)
+	n += cbg.SizeOf(t.Stuff)
+
+	// t.Others ([]uint64) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.Others)))
+	for _, v := range t.Others {
+
+		n += cbg.CborHeaderSize(uint64(v))
 
-	// t.Stuff (testing.SimpleTypeOne) (struct)
-	if err := t.Stuff.MarshalCBOR(cw); err != nil {
-		return err
 	}
 
-	// t.Deferred (typegen.Deferred) (struct)
-	if err := t.Deferred.MarshalCBOR(cw); err != nil {
-		return err
+	// t.SignedOthers ([]int64) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.SignedOthers)))
+	for _, v := range t.SignedOthers {
+		n += cbg.CborIntSize(int64(v))
 	}
 
-	// t.Value (uint64) (uint64)
+	// t.Test ([][]uint8) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.Test)))
+	for _, v := range t.Test {
+		n += cbg.CborHeaderSize(uint64(len(v))) + len(v)
+	}
+
+	// t.Dog (string) (string)
+	n += cbg.CborHeaderSize(uint64(len(t.Dog))) + len(t.Dog)
+
+	// t.Numbers ([]testing.NamedNumber) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.Numbers)))
+	for _, v := range t.Numbers {
+
+		n += cbg.CborHeaderSize(uint64(v))
+
+	}
+
+	// t.Pizza (uint64) (uint64)
+
+	if t.Pizza == nil {
+		n += len(cbg.CborNull)
+	} else {
+		n += cbg.CborHeaderSize(uint64(*t.Pizza))
+	}
+
+	// t.PointyPizza (testing.NamedNumber) (uint64)
+
+	if t.PointyPizza == nil {
+		n += len(cbg.CborNull)
+	} else {
+		n += cbg.CborHeaderSize(uint64(*t.PointyPizza))
+	}
+
+	// t.Arrrrrghay ([3]testing.SimpleTypeOne) (array)
+	n += cbg.CborHeaderSize(uint64(len(t.Arrrrrghay)))
+	for _, v := range t.Arrrrrghay {
+		n += cbg.SizeOf(&v)
+	}
+	return n
+}
+
+func (t *SimpleTypeTwo) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+func (t *SimpleTypeTwo) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.Stuff (testing.SimpleTypeTwo) (struct)
+
+	if t.Stuff != nil {
+
+		if err := cbg.ForEachLink(t.Stuff, fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.Arrrrrghay ([3]testing.SimpleTypeOne) (array)
+	for i := range t.Arrrrrghay {
+
+		if err := cbg.ForEachLink(&t.Arrrrrghay[i], fn); err != nil {
+			return err
+		}
+
+	}
+	return nil
+}
+
+func (t *SimpleTypeTwo) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.Stuff (testing.SimpleTypeTwo) (struct)
+
+	if t.Stuff != nil {
+
+		if err := cbg.ForEachLinkPath(t.Stuff, cbg.JoinPath(prefix, "Stuff"), fn); err != nil {
+			return err
+		}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.Value)); err != nil {
-		return err
 	}
 
+	// t.Arrrrrghay ([3]testing.SimpleTypeOne) (array)
+	for i := range t.Arrrrrghay {
+
+		if err := cbg.ForEachLinkPath(&t.Arrrrrghay[i], cbg.JoinPathIndex(cbg.JoinPath(prefix, "Arrrrrghay"), i), fn); err != nil {
+			return err
+		}
+
+	}
 	return nil
 }
 
+func (t *SimpleTypeTwo) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Arrrrrghay ([3]testing.SimpleTypeOne) (array)
+	b = append(b, "\"Arrrrrghay\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Arrrrrghay); err != nil {
+		return nil, err
+	}
+
+	// t.Dog (string) (string)
+	b = append(b, ",\"Dog\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Dog); err != nil {
+		return nil, err
+	}
+
+	// t.Numbers ([]testing.NamedNumber) (slice)
+	b = append(b, ",\"Numbers\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Numbers); err != nil {
+		return nil, err
+	}
+
+	// t.Others ([]uint64) (slice)
+	b = append(b, ",\"Others\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Others); err != nil {
+		return nil, err
+	}
+
+	// t.Pizza (uint64) (uint64)
+	b = append(b, ",\"Pizza\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Pizza); err != nil {
+		return nil, err
+	}
+
+	// t.PointyPizza (testing.NamedNumber) (uint64)
+	b = append(b, ",\"PointyPizza\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.PointyPizza); err != nil {
+		return nil, err
+	}
+
+	// t.SignedOthers ([]int64) (slice)
+	b = append(b, ",\"SignedOthers\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.SignedOthers); err != nil {
+		return nil, err
+	}
+
+	// t.Stuff (testing.SimpleTypeTwo) (struct)
+	b = append(b, ",\"Stuff\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Stuff); err != nil {
+		return nil, err
+	}
+
+	// t.Test ([][]uint8) (slice)
+	b = append(b, ",\"Test\":"...)
+
+	if t.Test == nil {
+		b = append(b, "null"...)
+	} else {
+		b = append(b, '[')
+		for i := range t.Test {
+			if i > 0 {
+				b = append(b, ',')
+			}
+
+			b = cbg.AppendJSONBytes(b, t.Test[i][:])
+
+		}
+		b = append(b, ']')
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *SimpleTypeTwo) UnmarshalJSON(b []byte) error {
+	*t = SimpleTypeTwo{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Stuff (testing.SimpleTypeTwo) (struct)
+		case "Stuff":
+
+			if err := cbg.ReadJSON(v, &t.Stuff); err != nil {
+				return err
+			}
+		// t.Others ([]uint64) (slice)
+		case "Others":
+
+			if err := cbg.ReadJSON(v, &t.Others); err != nil {
+				return err
+			}
+			if len(t.Others) > cbg.MaxLength {
+				return fmt.Errorf("t.Others: %w", &cbg.ErrTooLong{Length: uint64(len(t.Others)), Limit: cbg.MaxLength})
+			}
+		// t.SignedOthers ([]int64) (slice)
+		case "SignedOthers":
+
+			if err := cbg.ReadJSON(v, &t.SignedOthers); err != nil {
+				return err
+			}
+			if len(t.SignedOthers) > cbg.MaxLength {
+				return fmt.Errorf("t.SignedOthers: %w", &cbg.ErrTooLong{Length: uint64(len(t.SignedOthers)), Limit: cbg.MaxLength})
+			}
+		// t.Test ([][]uint8) (slice)
+		case "Test":
+
+			{
+				elems, err := cbg.ReadJSONArray(v)
+				if err != nil {
+					return err
+				}
+				if len(elems) > cbg.MaxLength {
+					return fmt.Errorf("t.Test: %w", &cbg.ErrTooLong{Length: uint64(len(elems)), Limit: cbg.MaxLength})
+				}
+				if elems != nil {
+					t.Test = make([][]uint8, len(elems))
+				}
+				for i, v := range elems {
+
+					{
+						bs, err := cbg.ReadJSONBytes(v)
+						if err != nil {
+							return err
+						}
+						if len(bs) > cbg.ByteArrayMaxLen {
+							return fmt.Errorf("t.Test[i]: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: cbg.ByteArrayMaxLen})
+						}
+						t.Test[i] = bs
+					}
+
+				}
+			}
+		// t.Dog (string) (string)
+		case "Dog":
+
+			if err := cbg.ReadJSON(v, &t.Dog); err != nil {
+				return err
+			}
+			if len(t.Dog) > cbg.MaxLength {
+				return fmt.Errorf("t.Dog: %w", &cbg.ErrTooLong{Length: uint64(len(t.Dog)), Limit: cbg.MaxLength})
+			}
+		// t.Numbers ([]testing.NamedNumber) (slice)
+		case "Numbers":
+
+			if err := cbg.ReadJSON(v, &t.Numbers); err != nil {
+				return err
+			}
+			if len(t.Numbers) > cbg.MaxLength {
+				return fmt.Errorf("t.Numbers: %w", &cbg.ErrTooLong{Length: uint64(len(t.Numbers)), Limit: cbg.MaxLength})
+			}
+		// t.Pizza (uint64) (uint64)
+		case "Pizza":
+
+			if err := cbg.ReadJSON(v, &t.Pizza); err != nil {
+				return err
+			}
+		// t.PointyPizza (testing.NamedNumber) (uint64)
+		case "PointyPizza":
+
+			if err := cbg.ReadJSON(v, &t.PointyPizza); err != nil {
+				return err
+			}
+		// t.Arrrrrghay ([3]testing.SimpleTypeOne) (array)
+		case "Arrrrrghay":
+
+			if err := cbg.ReadJSON(v, &t.Arrrrrghay); err != nil {
+				return err
+			}
+
+		}
+		return nil
+	})
+}
+
+var lengthBufDeferredContainer = []byte{131}
+
+func (t *DeferredContainer) MarshalCBOR(w io.Writer) error {
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func (t *DeferredContainer) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
+	}
+
+	b = append(b, lengthBufDeferredContainer...)
+
+	// t.Stuff (testing.SimpleTypeOne) (struct)
+	if b, err = cbg.AppendMarshaler(b, t.Stuff); err != nil {
+		return nil, err
+	}
+
+	// t.Deferred (typegen.Deferred) (struct)
+	if b, err = cbg.AppendMarshaler(b, t.Deferred); err != nil {
+		return nil, err
+	}
+
+	// t.Value (uint64) (uint64)
+
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(t.Value))
+
+	return b, nil
+}
+
 func (t *DeferredContainer) UnmarshalCBOR(r io.Reader) (err error) {
 	*t = DeferredContainer{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "DeferredContainer", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -766,14 +1333,15 @@ func (t *DeferredContainer) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra != 3 {
-		return fmt.Errorf("cbor input had wrong number of fields")
+		return &cbg.ErrWrongLength{Want: 3, Got: extra}
 	}
 
 	// t.Stuff (testing.SimpleTypeOne) (struct)
+	pos = cbg.FieldPos{Field: "Stuff"}
 
 	{
 
@@ -793,6 +1361,7 @@ func (t *DeferredContainer) UnmarshalCBOR(r io.Reader) (err error) {
 
 	}
 	// t.Deferred (typegen.Deferred) (struct)
+	pos = cbg.FieldPos{Field: "Deferred"}
 
 	{
 
@@ -803,6 +1372,7 @@ func (t *DeferredContainer) UnmarshalCBOR(r io.Reader) (err error) {
 		}
 	}
 	// t.Value (uint64) (uint64)
+	pos = cbg.FieldPos{Field: "Value"}
 
 	{
 
@@ -811,7 +1381,7 @@ func (t *DeferredContainer) UnmarshalCBOR(r io.Reader) (err error) {
 			return err
 		}
 		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
+			return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 		}
 		t.Value = uint64(extra)
 
@@ -819,66 +1389,1186 @@ func (t *DeferredContainer) UnmarshalCBOR(r io.Reader) (err error) {
 	return nil
 }
 
-var lengthBufFixedArrays = []byte{131}
+func (t *DeferredContainer) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
 
-func (t *FixedArrays) MarshalCBOR(w io.Writer) error {
+	n := 1
+
+	// t.Stuff (testing.SimpleTypeOne) (struct)
+	n += cbg.SizeOf(t.Stuff)
+
+	// t.Deferred (typegen.Deferred) (struct)
+	n += cbg.SizeOf(t.Deferred)
+
+	// t.Value (uint64) (uint64)
+
+	n += cbg.CborHeaderSize(uint64(t.Value))
+
+	return n
+}
+
+func (t *DeferredContainer) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+func (t *DeferredContainer) ForEachLink(fn func(cid.Cid) error) error {
 	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
+		return nil
 	}
 
-	cw := cbg.NewCborWriter(w)
+	// t.Stuff (testing.SimpleTypeOne) (struct)
+
+	if t.Stuff != nil {
+
+		if err := cbg.ForEachLink(t.Stuff, fn); err != nil {
+			return err
+		}
 
-	if _, err := cw.Write(lengthBufFixedArrays); err != nil {
-		return err
 	}
 
-	// t.Bytes ([20]uint8) (array)
-	if len(t.Bytes) > cbg.ByteArrayMaxLen {
-		return xerrors.Errorf("Byte array in field t.Bytes was too long")
+	// t.Deferred (typegen.Deferred) (struct)
+
+	if t.Deferred != nil {
+
+		if err := cbg.ForEachLink(t.Deferred, fn); err != nil {
+			return err
+		}
+
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajByteString, uint64(len(t.Bytes))); err != nil {
-		return err
+	return nil
+}
+
+func (t *DeferredContainer) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
 	}
 
-	if _, err := cw.Write(t.Bytes[:]); err != nil {
-		return err
+	// t.Stuff (testing.SimpleTypeOne) (struct)
+
+	if t.Stuff != nil {
+
+		if err := cbg.ForEachLinkPath(t.Stuff, cbg.JoinPath(prefix, "Stuff"), fn); err != nil {
+			return err
+		}
+
 	}
 
-	// t.Uint8 ([20]uint8) (array)
-	if len(t.Uint8) > cbg.ByteArrayMaxLen {
-		return xerrors.Errorf("Byte array in field t.Uint8 was too long")
+	// t.Deferred (typegen.Deferred) (struct)
+
+	if t.Deferred != nil {
+
+		if err := cbg.ForEachLinkPath(t.Deferred, cbg.JoinPath(prefix, "Deferred"), fn); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+func (t *DeferredContainer) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Deferred (typegen.Deferred) (struct)
+	b = append(b, "\"Deferred\":"...)
+
+	if b, err = cbg.AppendJSONDeferred(b, t.Deferred); err != nil {
+		return nil, err
+	}
+
+	// t.Stuff (testing.SimpleTypeOne) (struct)
+	b = append(b, ",\"Stuff\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Stuff); err != nil {
+		return nil, err
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajByteString, uint64(len(t.Uint8))); err != nil {
-		return err
+	// t.Value (uint64) (uint64)
+	b = append(b, ",\"Value\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Value); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *DeferredContainer) UnmarshalJSON(b []byte) error {
+	*t = DeferredContainer{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Stuff (testing.SimpleTypeOne) (struct)
+		case "Stuff":
+
+			if err := cbg.ReadJSON(v, &t.Stuff); err != nil {
+				return err
+			}
+		// t.Deferred (typegen.Deferred) (struct)
+		case "Deferred":
+
+			{
+				d, err := cbg.ReadJSONDeferred(v)
+				if err != nil {
+					return err
+				}
+				t.Deferred = d
+			}
+		// t.Value (uint64) (uint64)
+		case "Value":
+
+			if err := cbg.ReadJSON(v, &t.Value); err != nil {
+				return err
+			}
+
+		}
+		return nil
+	})
+}
+
+var lengthBufFixedArrays = []byte{131}
+
+func (t *FixedArrays) MarshalCBOR(w io.Writer) error {
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func (t *FixedArrays) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
+	}
+
+	b = append(b, lengthBufFixedArrays...)
+
+	// t.Bytes ([20]uint8) (array)
+	if len(t.Bytes) > cbg.ByteArrayMaxLen {
+		return nil, xerrors.Errorf("Byte array in field t.Bytes was too long")
+	}
+
+	b = cbg.AppendByteString(b, t.Bytes[:])
+
+	// t.Uint8 ([20]uint8) (array)
+	if len(t.Uint8) > cbg.ByteArrayMaxLen {
+		return nil, xerrors.Errorf("Byte array in field t.Uint8 was too long")
+	}
+
+	b = cbg.AppendByteString(b, t.Uint8[:])
+
+	// t.Uint64 ([20]uint64) (array)
+	if len(t.Uint64) > cbg.MaxLength {
+		return nil, xerrors.Errorf("Slice value in field t.Uint64 was too long")
+	}
+
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.Uint64)))
+	for _, v := range t.Uint64 {
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(v))
+	}
+	return b, nil
+}
+
+func (t *FixedArrays) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = FixedArrays{}
+
+	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "FixedArrays", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+	}
+
+	if extra != 3 {
+		return &cbg.ErrWrongLength{Want: 3, Got: extra}
+	}
+
+	// t.Bytes ([20]uint8) (array)
+	pos = cbg.FieldPos{Field: "Bytes"}
+
+	maj, extra, err = cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.ByteArrayMaxLen {
+		return fmt.Errorf("t.Bytes: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.ByteArrayMaxLen})
+	}
+	if maj != cbg.MajByteString {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
+	}
+
+	if extra != 20 {
+		return fmt.Errorf("t.Bytes: %w", &cbg.ErrWrongLength{Want: 20, Got: extra})
+	}
+
+	t.Bytes = [20]uint8{}
+
+	if _, err := io.ReadFull(cr, t.Bytes[:]); err != nil {
+		return err
+	}
+	// t.Uint8 ([20]uint8) (array)
+	pos = cbg.FieldPos{Field: "Uint8"}
+
+	maj, extra, err = cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.ByteArrayMaxLen {
+		return fmt.Errorf("t.Uint8: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.ByteArrayMaxLen})
+	}
+	if maj != cbg.MajByteString {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
+	}
+
+	if extra != 20 {
+		return fmt.Errorf("t.Uint8: %w", &cbg.ErrWrongLength{Want: 20, Got: extra})
+	}
+
+	t.Uint8 = [20]uint8{}
+
+	if _, err := io.ReadFull(cr, t.Uint8[:]); err != nil {
+		return err
+	}
+	// t.Uint64 ([20]uint64) (array)
+	pos = cbg.FieldPos{Field: "Uint64"}
+
+	maj, extra, err = cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Uint64: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
+	}
+
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+	}
+
+	if extra != 20 {
+		return fmt.Errorf("t.Uint64: %w", &cbg.ErrWrongLength{Want: 20, Got: extra})
+	}
+
+	t.Uint64 = [20]uint64{}
+
+	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
+
+		maj, val, err := cr.ReadHeader()
+		if err != nil {
+			return xerrors.Errorf("failed to read uint64 for t.Uint64 slice: %w", err)
+		}
+
+		if maj != cbg.MajUnsignedInt {
+			return xerrors.Errorf("value read for array t.Uint64: %w", &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj})
+		}
+
+		t.Uint64[i] = uint64(val)
+	}
+
+	return nil
+}
+
+func (t *FixedArrays) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.Bytes ([20]uint8) (array)
+	n += cbg.CborHeaderSize(uint64(len(t.Bytes))) + len(t.Bytes)
+
+	// t.Uint8 ([20]uint8) (array)
+	n += cbg.CborHeaderSize(uint64(len(t.Uint8))) + len(t.Uint8)
+
+	// t.Uint64 ([20]uint64) (array)
+	n += cbg.CborHeaderSize(uint64(len(t.Uint64)))
+	for _, v := range t.Uint64 {
+
+		n += cbg.CborHeaderSize(uint64(v))
+
+	}
+	return n
+}
+
+func (t *FixedArrays) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+func (t *FixedArrays) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *FixedArrays) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *FixedArrays) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Bytes ([20]uint8) (array)
+	b = append(b, "\"Bytes\":"...)
+
+	b = cbg.AppendJSONBytes(b, t.Bytes[:])
+
+	// t.Uint64 ([20]uint64) (array)
+	b = append(b, ",\"Uint64\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Uint64); err != nil {
+		return nil, err
+	}
+
+	// t.Uint8 ([20]uint8) (array)
+	b = append(b, ",\"Uint8\":"...)
+
+	b = cbg.AppendJSONBytes(b, t.Uint8[:])
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *FixedArrays) UnmarshalJSON(b []byte) error {
+	*t = FixedArrays{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Bytes ([20]uint8) (array)
+		case "Bytes":
+
+			{
+				bs, err := cbg.ReadJSONBytes(v)
+				if err != nil {
+					return err
+				}
+				if len(bs) > cbg.ByteArrayMaxLen {
+					return fmt.Errorf("t.Bytes: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: cbg.ByteArrayMaxLen})
+				}
+				if len(bs) != 20 {
+					return fmt.Errorf("t.Bytes: %w", &cbg.ErrWrongLength{Want: 20, Got: uint64(len(bs))})
+				}
+				copy(t.Bytes[:], bs)
+			}
+		// t.Uint8 ([20]uint8) (array)
+		case "Uint8":
+
+			{
+				bs, err := cbg.ReadJSONBytes(v)
+				if err != nil {
+					return err
+				}
+				if len(bs) > cbg.ByteArrayMaxLen {
+					return fmt.Errorf("t.Uint8: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: cbg.ByteArrayMaxLen})
+				}
+				if len(bs) != 20 {
+					return fmt.Errorf("t.Uint8: %w", &cbg.ErrWrongLength{Want: 20, Got: uint64(len(bs))})
+				}
+				copy(t.Uint8[:], bs)
+			}
+		// t.Uint64 ([20]uint64) (array)
+		case "Uint64":
+
+			if err := cbg.ReadJSON(v, &t.Uint64); err != nil {
+				return err
+			}
+
+		}
+		return nil
+	})
+}
+
+var lengthBufThingWithSomeTime = []byte{131}
+
+func (t *ThingWithSomeTime) MarshalCBOR(w io.Writer) error {
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func (t *ThingWithSomeTime) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
+	}
+
+	b = append(b, lengthBufThingWithSomeTime...)
+
+	// t.When (typegen.CborTime) (struct)
+	if b, err = cbg.AppendMarshaler(b, &t.When); err != nil {
+		return nil, err
+	}
+
+	// t.Stuff (int64) (int64)
+	b = cbg.AppendInt64(b, int64(t.Stuff))
+
+	// t.CatName (string) (string)
+	if len(t.CatName) > cbg.MaxLength {
+		return nil, xerrors.Errorf("Value in field t.CatName was too long")
+	}
+
+	b = cbg.AppendString(b, string(t.CatName))
+	return b, nil
+}
+
+func (t *ThingWithSomeTime) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = ThingWithSomeTime{}
+
+	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "ThingWithSomeTime", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+	}
+
+	if extra != 3 {
+		return &cbg.ErrWrongLength{Want: 3, Got: extra}
+	}
+
+	// t.When (typegen.CborTime) (struct)
+	pos = cbg.FieldPos{Field: "When"}
+
+	{
+
+		if err := t.When.UnmarshalCBOR(cr); err != nil {
+			return xerrors.Errorf("unmarshaling t.When: %w", err)
+		}
+
+	}
+	// t.Stuff (int64) (int64)
+	pos = cbg.FieldPos{Field: "Stuff"}
+	{
+		maj, extra, err := cr.ReadHeader()
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return &cbg.ErrIntOverflow{Type: "int64", Value: extra}
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return &cbg.ErrIntOverflow{Type: "int64", Value: extra, Negative: true}
+			}
+			extraI = -1 - extraI
+		default:
+			return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
+		}
+
+		t.Stuff = int64(extraI)
+	}
+	// t.CatName (string) (string)
+	pos = cbg.FieldPos{Field: "CatName"}
+
+	{
+		sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
+		if err != nil {
+			return err
+		}
+
+		t.CatName = string(sval)
+	}
+	return nil
+}
+
+func (t *ThingWithSomeTime) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.When (typegen.CborTime) (struct)
+	n += cbg.SizeOf(&t.When)
+
+	// t.Stuff (int64) (int64)
+	n += cbg.CborIntSize(int64(t.Stuff))
+
+	// t.CatName (string) (string)
+	n += cbg.CborHeaderSize(uint64(len(t.CatName))) + len(t.CatName)
+	return n
+}
+
+func (t *ThingWithSomeTime) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+func (t *ThingWithSomeTime) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.When (typegen.CborTime) (struct)
+
+	if err := cbg.ForEachLink(&t.When, fn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *ThingWithSomeTime) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.When (typegen.CborTime) (struct)
+
+	if err := cbg.ForEachLinkPath(&t.When, cbg.JoinPath(prefix, "When"), fn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *ThingWithSomeTime) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.CatName (string) (string)
+	b = append(b, "\"CatName\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.CatName); err != nil {
+		return nil, err
+	}
+
+	// t.Stuff (int64) (int64)
+	b = append(b, ",\"Stuff\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Stuff); err != nil {
+		return nil, err
+	}
+
+	// t.When (typegen.CborTime) (struct)
+	b = append(b, ",\"When\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.When); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *ThingWithSomeTime) UnmarshalJSON(b []byte) error {
+	*t = ThingWithSomeTime{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.When (typegen.CborTime) (struct)
+		case "When":
+
+			if err := cbg.ReadJSON(v, &t.When); err != nil {
+				return err
+			}
+		// t.Stuff (int64) (int64)
+		case "Stuff":
+
+			if err := cbg.ReadJSON(v, &t.Stuff); err != nil {
+				return err
+			}
+		// t.CatName (string) (string)
+		case "CatName":
+
+			if err := cbg.ReadJSON(v, &t.CatName); err != nil {
+				return err
+			}
+			if len(t.CatName) > cbg.MaxLength {
+				return fmt.Errorf("t.CatName: %w", &cbg.ErrTooLong{Length: uint64(len(t.CatName)), Limit: cbg.MaxLength})
+			}
+
+		}
+		return nil
+	})
+}
+
+var lengthBufBigField = []byte{129}
+
+func (t *BigField) MarshalCBOR(w io.Writer) error {
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func (t *BigField) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
+	}
+
+	b = append(b, lengthBufBigField...)
+
+	// t.LargeBytes ([]uint8) (slice)
+	if len(t.LargeBytes) > 10000000 {
+		return nil, xerrors.Errorf("Byte array in field t.LargeBytes was too long")
+	}
+
+	b = cbg.AppendByteString(b, t.LargeBytes[:])
+	return b, nil
+}
+
+func (t *BigField) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = BigField{}
+
+	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "BigField", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+	}
+
+	if extra != 1 {
+		return &cbg.ErrWrongLength{Want: 1, Got: extra}
+	}
+
+	// t.LargeBytes ([]uint8) (slice)
+	pos = cbg.FieldPos{Field: "LargeBytes"}
+
+	maj, extra, err = cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	if extra > 10000000 {
+		return fmt.Errorf("t.LargeBytes: %w", &cbg.ErrTooLong{Length: extra, Limit: 10000000})
+	}
+	if maj != cbg.MajByteString {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
+	}
+
+	if extra > 0 {
+		t.LargeBytes, err = cr.ReadByteSlice(extra)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *BigField) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.LargeBytes ([]uint8) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.LargeBytes))) + len(t.LargeBytes)
+	return n
+}
+
+func (t *BigField) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+func (t *BigField) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *BigField) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *BigField) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.LargeBytes ([]uint8) (slice)
+	b = append(b, "\"LargeBytes\":"...)
+
+	b = cbg.AppendJSONBytes(b, t.LargeBytes[:])
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *BigField) UnmarshalJSON(b []byte) error {
+	*t = BigField{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.LargeBytes ([]uint8) (slice)
+		case "LargeBytes":
+
+			{
+				bs, err := cbg.ReadJSONBytes(v)
+				if err != nil {
+					return err
+				}
+				if len(bs) > 10000000 {
+					return fmt.Errorf("t.LargeBytes: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: 10000000})
+				}
+				t.LargeBytes = bs
+			}
+
+		}
+		return nil
+	})
+}
+
+var lengthBufLongString = []byte{129}
+
+func (t *LongString) MarshalCBOR(w io.Writer) error {
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func (t *LongString) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
+	}
+
+	b = append(b, lengthBufLongString...)
+
+	// t.Text (string) (string)
+	if len(t.Text) > 20000 {
+		return nil, xerrors.Errorf("Value in field t.Text was too long")
+	}
+
+	b = cbg.AppendString(b, string(t.Text))
+	return b, nil
+}
+
+func (t *LongString) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = LongString{}
+
+	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "LongString", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+	}
+
+	if extra != 1 {
+		return &cbg.ErrWrongLength{Want: 1, Got: extra}
+	}
+
+	// t.Text (string) (string)
+	pos = cbg.FieldPos{Field: "Text"}
+
+	{
+		sval, err := cbg.ReadStringWithMax(cr, 20000)
+		if err != nil {
+			return err
+		}
+
+		t.Text = string(sval)
+	}
+	return nil
+}
+
+func (t *LongString) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.Text (string) (string)
+	n += cbg.CborHeaderSize(uint64(len(t.Text))) + len(t.Text)
+	return n
+}
+
+func (t *LongString) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
+	}
+	return cr.Remaining(), nil
+}
+
+func (t *LongString) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *LongString) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *LongString) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Text (string) (string)
+	b = append(b, "\"Text\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Text); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *LongString) UnmarshalJSON(b []byte) error {
+	*t = LongString{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Text (string) (string)
+		case "Text":
+
+			if err := cbg.ReadJSON(v, &t.Text); err != nil {
+				return err
+			}
+			if len(t.Text) > 20000 {
+				return fmt.Errorf("t.Text: %w", &cbg.ErrTooLong{Length: uint64(len(t.Text)), Limit: 20000})
+			}
+
+		}
+		return nil
+	})
+}
+
+var lengthBufLongLog = []byte{131}
+
+func (t *LongLog) MarshalCBOR(w io.Writer) error {
+	b, err := t.AppendCBOR(make([]byte, 0, t.CBORSize()))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+func (t *LongLog) AppendCBOR(b []byte) (_ []byte, err error) {
+	if t == nil {
+		return append(b, cbg.CborNull...), nil
+	}
+
+	b = append(b, lengthBufLongLog...)
+
+	// t.Name (string) (string)
+	if len(t.Name) > cbg.MaxLength {
+		return nil, xerrors.Errorf("Value in field t.Name was too long")
+	}
+
+	b = cbg.AppendString(b, string(t.Name))
+
+	// t.Entries ([]*testing.SimpleTypeOne) (slice)
+	if len(t.Entries) > cbg.MaxLength {
+		return nil, xerrors.Errorf("Slice value in field t.Entries was too long")
+	}
+
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.Entries)))
+	for _, v := range t.Entries {
+		if b, err = cbg.AppendMarshaler(b, v); err != nil {
+			return nil, err
+		}
+	}
+
+	// t.Heights ([]uint64) (slice)
+	if len(t.Heights) > cbg.MaxLength {
+		return nil, xerrors.Errorf("Slice value in field t.Heights was too long")
+	}
+
+	b = cbg.AppendMajorTypeHeader(b, cbg.MajArray, uint64(len(t.Heights)))
+	for _, v := range t.Heights {
+		b = cbg.AppendMajorTypeHeader(b, cbg.MajUnsignedInt, uint64(v))
+	}
+	return b, nil
+}
+
+func (t *LongLog) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = LongLog{}
+
+	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "LongLog", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+	}
+
+	if extra != 3 {
+		return &cbg.ErrWrongLength{Want: 3, Got: extra}
+	}
+
+	// t.Name (string) (string)
+	pos = cbg.FieldPos{Field: "Name"}
+
+	{
+		sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
+		if err != nil {
+			return err
+		}
+
+		t.Name = string(sval)
+	}
+	// t.Entries ([]*testing.SimpleTypeOne) (slice)
+	pos = cbg.FieldPos{Field: "Entries"}
+
+	maj, extra, err = cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Entries: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
+	}
+
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+	}
+
+	if extra > 0 {
+		if err := cr.Allocate(extra * 8); err != nil {
+			return err
+		}
+		t.Entries = make([]*SimpleTypeOne, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
+
+		var v SimpleTypeOne
+		if err := v.UnmarshalCBOR(cr); err != nil {
+			return err
+		}
+
+		t.Entries[i] = &v
 	}
 
-	if _, err := cw.Write(t.Uint8[:]); err != nil {
+	// t.Heights ([]uint64) (slice)
+	pos = cbg.FieldPos{Field: "Heights"}
+
+	maj, extra, err = cr.ReadHeader()
+	if err != nil {
 		return err
 	}
 
-	// t.Uint64 ([20]uint64) (array)
-	if len(t.Uint64) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.Uint64 was too long")
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Heights: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.Uint64))); err != nil {
-		return err
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
-	for _, v := range t.Uint64 {
-		if err := cw.CborWriteHeader(cbg.MajUnsignedInt, uint64(v)); err != nil {
+
+	if extra > 0 {
+		if err := cr.Allocate(extra * 8); err != nil {
 			return err
 		}
+		t.Heights = make([]uint64, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
+
+		maj, val, err := cr.ReadHeader()
+		if err != nil {
+			return xerrors.Errorf("failed to read uint64 for t.Heights slice: %w", err)
+		}
+
+		if maj != cbg.MajUnsignedInt {
+			return xerrors.Errorf("value read for array t.Heights: %w", &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj})
+		}
+
+		t.Heights[i] = uint64(val)
 	}
+
 	return nil
 }
 
-func (t *FixedArrays) UnmarshalCBOR(r io.Reader) (err error) {
-	*t = FixedArrays{}
+// ForEachEntries decodes t from r like UnmarshalCBOR, but instead of
+// collecting the elements of Entries it calls fn with each of them in
+// turn, leaving the field empty. The number of elements is not limited.
+func (t *LongLog) ForEachEntries(r io.Reader, fn func(*SimpleTypeOne) error) (err error) {
+	*t = LongLog{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "LongLog", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -891,60 +2581,64 @@ func (t *FixedArrays) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra != 3 {
-		return fmt.Errorf("cbor input had wrong number of fields")
+		return &cbg.ErrWrongLength{Want: 3, Got: extra}
 	}
 
-	// t.Bytes ([20]uint8) (array)
+	// t.Name (string) (string)
+	pos = cbg.FieldPos{Field: "Name"}
 
-	maj, extra, err = cr.ReadHeader()
-	if err != nil {
-		return err
-	}
+	{
+		sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
+		if err != nil {
+			return err
+		}
 
-	if extra > cbg.ByteArrayMaxLen {
-		return fmt.Errorf("t.Bytes: byte array too large (%d)", extra)
-	}
-	if maj != cbg.MajByteString {
-		return fmt.Errorf("expected byte array")
+		t.Name = string(sval)
 	}
+	// t.Entries ([]*testing.SimpleTypeOne) (slice)
+	pos = cbg.FieldPos{Field: "Entries"}
 
-	if extra != 20 {
-		return fmt.Errorf("expected array to have 20 elements")
-	}
+	{
+		length, err := cr.ReadArrayHeader()
+		if err != nil {
+			return err
+		}
 
-	t.Bytes = [20]uint8{}
+		for i := uint64(0); i < length; i++ {
+			var v *SimpleTypeOne
+			pos.Index[0] = int(i)
+			pos.Depth = 1
 
-	if _, err := io.ReadFull(cr, t.Bytes[:]); err != nil {
-		return err
-	}
-	// t.Uint8 ([20]uint8) (array)
+			{
 
-	maj, extra, err = cr.ReadHeader()
-	if err != nil {
-		return err
-	}
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+					v = new(SimpleTypeOne)
+					if err := v.UnmarshalCBOR(cr); err != nil {
+						return xerrors.Errorf("unmarshaling v pointer: %w", err)
+					}
+				}
 
-	if extra > cbg.ByteArrayMaxLen {
-		return fmt.Errorf("t.Uint8: byte array too large (%d)", extra)
-	}
-	if maj != cbg.MajByteString {
-		return fmt.Errorf("expected byte array")
-	}
+			}
 
-	if extra != 20 {
-		return fmt.Errorf("expected array to have 20 elements")
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
 	}
 
-	t.Uint8 = [20]uint8{}
-
-	if _, err := io.ReadFull(cr, t.Uint8[:]); err != nil {
-		return err
-	}
-	// t.Uint64 ([20]uint64) (array)
+	// t.Heights ([]uint64) (slice)
+	pos = cbg.FieldPos{Field: "Heights"}
 
 	maj, extra, err = cr.ReadHeader()
 	if err != nil {
@@ -952,84 +2646,56 @@ func (t *FixedArrays) UnmarshalCBOR(r io.Reader) (err error) {
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("t.Uint64: array too large (%d)", extra)
+		return fmt.Errorf("t.Heights: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
-	if extra != 20 {
-		return fmt.Errorf("expected array to have 20 elements")
+	if extra > 0 {
+		if err := cr.Allocate(extra * 8); err != nil {
+			return err
+		}
+		t.Heights = make([]uint64, extra)
 	}
 
-	t.Uint64 = [20]uint64{}
-
 	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
 
 		maj, val, err := cr.ReadHeader()
 		if err != nil {
-			return xerrors.Errorf("failed to read uint64 for t.Uint64 slice: %w", err)
+			return xerrors.Errorf("failed to read uint64 for t.Heights slice: %w", err)
 		}
 
 		if maj != cbg.MajUnsignedInt {
-			return xerrors.Errorf("value read for array t.Uint64 was not a uint, instead got %d", maj)
+			return xerrors.Errorf("value read for array t.Heights: %w", &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj})
 		}
 
-		t.Uint64[i] = uint64(val)
+		t.Heights[i] = uint64(val)
 	}
 
 	return nil
 }
 
-var lengthBufThingWithSomeTime = []byte{131}
-
-func (t *ThingWithSomeTime) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
-	}
-
-	cw := cbg.NewCborWriter(w)
-
-	if _, err := cw.Write(lengthBufThingWithSomeTime); err != nil {
-		return err
-	}
-
-	// t.When (typegen.CborTime) (struct)
-	if err := t.When.MarshalCBOR(cw); err != nil {
-		return err
-	}
+// ForEachHeights decodes t from r like UnmarshalCBOR, but instead of
+// collecting the elements of Heights it calls fn with each of them in
+// turn, leaving the field empty. The number of elements is not limited.
+func (t *LongLog) ForEachHeights(r io.Reader, fn func(uint64) error) (err error) {
+	*t = LongLog{}
 
-	// t.Stuff (int64) (int64)
-	if t.Stuff >= 0 {
-		if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.Stuff)); err != nil {
-			return err
-		}
-	} else {
-		if err := cw.WriteMajorTypeHeader(cbg.MajNegativeInt, uint64(-t.Stuff-1)); err != nil {
-			return err
+	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "LongLog", pos, cr)
 		}
-	}
-
-	// t.CatName (string) (string)
-	if len(t.CatName) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.CatName was too long")
-	}
-
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.CatName))); err != nil {
-		return err
-	}
-	if _, err := io.WriteString(w, string(t.CatName)); err != nil {
+	}()
+	if err := cr.EnterNested(); err != nil {
 		return err
 	}
-	return nil
-}
-
-func (t *ThingWithSomeTime) UnmarshalCBOR(r io.Reader) (err error) {
-	*t = ThingWithSomeTime{}
-
-	cr := cbg.NewCborReader(r)
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -1042,132 +2708,237 @@ func (t *ThingWithSomeTime) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 	}
 
 	if extra != 3 {
-		return fmt.Errorf("cbor input had wrong number of fields")
+		return &cbg.ErrWrongLength{Want: 3, Got: extra}
 	}
 
-	// t.When (typegen.CborTime) (struct)
+	// t.Name (string) (string)
+	pos = cbg.FieldPos{Field: "Name"}
 
 	{
-
-		if err := t.When.UnmarshalCBOR(cr); err != nil {
-			return xerrors.Errorf("unmarshaling t.When: %w", err)
+		sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
+		if err != nil {
+			return err
 		}
 
+		t.Name = string(sval)
 	}
-	// t.Stuff (int64) (int64)
-	{
-		maj, extra, err := cr.ReadHeader()
-		var extraI int64
-		if err != nil {
+	// t.Entries ([]*testing.SimpleTypeOne) (slice)
+	pos = cbg.FieldPos{Field: "Entries"}
+
+	maj, extra, err = cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Entries: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
+	}
+
+	if maj != cbg.MajArray {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+	}
+
+	if extra > 0 {
+		if err := cr.Allocate(extra * 8); err != nil {
 			return err
 		}
-		switch maj {
-		case cbg.MajUnsignedInt:
-			extraI = int64(extra)
-			if extraI < 0 {
-				return fmt.Errorf("int64 positive overflow")
-			}
-		case cbg.MajNegativeInt:
-			extraI = int64(extra)
-			if extraI < 0 {
-				return fmt.Errorf("int64 negative oveflow")
-			}
-			extraI = -1 - extraI
-		default:
-			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		t.Entries = make([]*SimpleTypeOne, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+		pos.Index[0] = i
+		pos.Depth = 1
+
+		var v SimpleTypeOne
+		if err := v.UnmarshalCBOR(cr); err != nil {
+			return err
 		}
 
-		t.Stuff = int64(extraI)
+		t.Entries[i] = &v
 	}
-	// t.CatName (string) (string)
+
+	// t.Heights ([]uint64) (slice)
+	pos = cbg.FieldPos{Field: "Heights"}
 
 	{
-		sval, err := cbg.ReadString(cr)
+		length, err := cr.ReadArrayHeader()
 		if err != nil {
 			return err
 		}
 
-		t.CatName = string(sval)
+		for i := uint64(0); i < length; i++ {
+			var v uint64
+			pos.Index[0] = int(i)
+			pos.Depth = 1
+
+			{
+
+				maj, extra, err = cr.ReadHeader()
+				if err != nil {
+					return err
+				}
+				if maj != cbg.MajUnsignedInt {
+					return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
+				}
+				v = uint64(extra)
+
+			}
+
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
 	}
+
 	return nil
 }
 
-var lengthBufBigField = []byte{129}
-
-func (t *BigField) MarshalCBOR(w io.Writer) error {
+func (t *LongLog) CBORSize() int {
 	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
+		return len(cbg.CborNull)
 	}
 
-	cw := cbg.NewCborWriter(w)
+	n := 1
 
-	if _, err := cw.Write(lengthBufBigField); err != nil {
-		return err
-	}
+	// t.Name (string) (string)
+	n += cbg.CborHeaderSize(uint64(len(t.Name))) + len(t.Name)
 
-	// t.LargeBytes ([]uint8) (slice)
-	if len(t.LargeBytes) > 10000000 {
-		return xerrors.Errorf("Byte array in field t.LargeBytes was too long")
+	// t.Entries ([]*testing.SimpleTypeOne) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.Entries)))
+	for _, v := range t.Entries {
+		n += cbg.SizeOf(v)
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajByteString, uint64(len(t.LargeBytes))); err != nil {
-		return err
+	// t.Heights ([]uint64) (slice)
+	n += cbg.CborHeaderSize(uint64(len(t.Heights)))
+	for _, v := range t.Heights {
+
+		n += cbg.CborHeaderSize(uint64(v))
+
 	}
+	return n
+}
 
-	if _, err := cw.Write(t.LargeBytes[:]); err != nil {
-		return err
+func (t *LongLog) UnmarshalCBORBytes(b []byte) (rest []byte, err error) {
+	cr := cbg.NewCborReaderBytes(b)
+	if err := t.UnmarshalCBOR(cr); err != nil {
+		return nil, err
 	}
-	return nil
+	return cr.Remaining(), nil
 }
 
-func (t *BigField) UnmarshalCBOR(r io.Reader) (err error) {
-	*t = BigField{}
+func (t *LongLog) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
 
-	cr := cbg.NewCborReader(r)
+	// t.Entries ([]*testing.SimpleTypeOne) (slice)
+	for i := range t.Entries {
+
+		if t.Entries[i] != nil {
+
+			if err := cbg.ForEachLink(t.Entries[i], fn); err != nil {
+				return err
+			}
 
-	maj, extra, err := cr.ReadHeader()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err == io.EOF {
-			err = io.ErrUnexpectedEOF
 		}
-	}()
 
-	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
 	}
+	return nil
+}
 
-	if extra != 1 {
-		return fmt.Errorf("cbor input had wrong number of fields")
+func (t *LongLog) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
 	}
 
-	// t.LargeBytes ([]uint8) (slice)
+	// t.Entries ([]*testing.SimpleTypeOne) (slice)
+	for i := range t.Entries {
+
+		if t.Entries[i] != nil {
+
+			if err := cbg.ForEachLinkPath(t.Entries[i], cbg.JoinPathIndex(cbg.JoinPath(prefix, "Entries"), i), fn); err != nil {
+				return err
+			}
+
+		}
 
-	maj, extra, err = cr.ReadHeader()
-	if err != nil {
-		return err
 	}
+	return nil
+}
 
-	if extra > 10000000 {
-		return fmt.Errorf("t.LargeBytes: byte array too large (%d)", extra)
+func (t *LongLog) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
 	}
-	if maj != cbg.MajByteString {
-		return fmt.Errorf("expected byte array")
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Entries ([]*testing.SimpleTypeOne) (slice)
+	b = append(b, "\"Entries\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Entries); err != nil {
+		return nil, err
 	}
 
-	if extra > 0 {
-		t.LargeBytes = make([]uint8, extra)
+	// t.Heights ([]uint64) (slice)
+	b = append(b, ",\"Heights\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Heights); err != nil {
+		return nil, err
 	}
 
-	if _, err := io.ReadFull(cr, t.LargeBytes[:]); err != nil {
-		return err
+	// t.Name (string) (string)
+	b = append(b, ",\"Name\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Name); err != nil {
+		return nil, err
 	}
-	return nil
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *LongLog) UnmarshalJSON(b []byte) error {
+	*t = LongLog{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Name (string) (string)
+		case "Name":
+
+			if err := cbg.ReadJSON(v, &t.Name); err != nil {
+				return err
+			}
+			if len(t.Name) > cbg.MaxLength {
+				return fmt.Errorf("t.Name: %w", &cbg.ErrTooLong{Length: uint64(len(t.Name)), Limit: cbg.MaxLength})
+			}
+		// t.Entries ([]*testing.SimpleTypeOne) (slice)
+		case "Entries":
+
+			if err := cbg.ReadJSON(v, &t.Entries); err != nil {
+				return err
+			}
+			if len(t.Entries) > cbg.MaxLength {
+				return fmt.Errorf("t.Entries: %w", &cbg.ErrTooLong{Length: uint64(len(t.Entries)), Limit: cbg.MaxLength})
+			}
+		// t.Heights ([]uint64) (slice)
+		case "Heights":
+
+			if err := cbg.ReadJSON(v, &t.Heights); err != nil {
+				return err
+			}
+			if len(t.Heights) > cbg.MaxLength {
+				return fmt.Errorf("t.Heights: %w", &cbg.ErrTooLong{Length: uint64(len(t.Heights)), Limit: cbg.MaxLength})
+			}
+
+		}
+		return nil
+	})
 }
@@ -194,6 +194,16 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 	*t = SimpleTypeTree{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "SimpleTypeTree", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -206,20 +216,21 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajMap {
-		return fmt.Errorf("cbor input should be of type map")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("SimpleTypeTree: map struct too large (%d)", extra)
+		return fmt.Errorf("SimpleTypeTree: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	var name string
 	n := extra
 
 	for i := uint64(0); i < n; i++ {
+		pos = cbg.FieldPos{}
 
 		{
-			sval, err := cbg.ReadString(cr)
+			sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 			if err != nil {
 				return err
 			}
@@ -230,6 +241,7 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 		switch name {
 		// t.Stuff (testing.SimpleTypeTree) (struct)
 		case "Stuff":
+			pos = cbg.FieldPos{Field: "Stuff"}
 
 			{
 
@@ -250,6 +262,7 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.Stufff (testing.SimpleTypeTwo) (struct)
 		case "Stufff":
+			pos = cbg.FieldPos{Field: "Stufff"}
 
 			{
 
@@ -270,6 +283,7 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.Others ([]uint64) (slice)
 		case "Others":
+			pos = cbg.FieldPos{Field: "Others"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
@@ -277,18 +291,23 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.MaxLength {
-				return fmt.Errorf("t.Others: array too large (%d)", extra)
+				return fmt.Errorf("t.Others: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 			}
 
 			if maj != cbg.MajArray {
-				return fmt.Errorf("expected cbor array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 			}
 
 			if extra > 0 {
+				if err := cr.Allocate(extra * 8); err != nil {
+					return err
+				}
 				t.Others = make([]uint64, extra)
 			}
 
 			for i := 0; i < int(extra); i++ {
+				pos.Index[0] = i
+				pos.Depth = 1
 
 				maj, val, err := cr.ReadHeader()
 				if err != nil {
@@ -296,7 +315,7 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 				}
 
 				if maj != cbg.MajUnsignedInt {
-					return xerrors.Errorf("value read for array t.Others was not a uint, instead got %d", maj)
+					return xerrors.Errorf("value read for array t.Others: %w", &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj})
 				}
 
 				t.Others[i] = uint64(val)
@@ -304,6 +323,7 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 
 			// t.Test ([][]uint8) (slice)
 		case "Test":
+			pos = cbg.FieldPos{Field: "Test"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
@@ -311,18 +331,23 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.MaxLength {
-				return fmt.Errorf("t.Test: array too large (%d)", extra)
+				return fmt.Errorf("t.Test: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 			}
 
 			if maj != cbg.MajArray {
-				return fmt.Errorf("expected cbor array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 			}
 
 			if extra > 0 {
+				if err := cr.Allocate(extra * 24); err != nil {
+					return err
+				}
 				t.Test = make([][]uint8, extra)
 			}
 
 			for i := 0; i < int(extra); i++ {
+				pos.Index[0] = i
+				pos.Depth = 1
 				{
 					var maj byte
 					var extra uint64
@@ -334,13 +359,16 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 					}
 
 					if extra > cbg.ByteArrayMaxLen {
-						return fmt.Errorf("t.Test[i]: byte array too large (%d)", extra)
+						return fmt.Errorf("t.Test[i]: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.ByteArrayMaxLen})
 					}
 					if maj != cbg.MajByteString {
-						return fmt.Errorf("expected byte array")
+						return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
 					}
 
 					if extra > 0 {
+						if err := cr.Allocate(extra * 1); err != nil {
+							return err
+						}
 						t.Test[i] = make([]uint8, extra)
 					}
 
@@ -352,9 +380,10 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 
 			// t.Dog (string) (string)
 		case "Dog":
+			pos = cbg.FieldPos{Field: "Dog"}
 
 			{
-				sval, err := cbg.ReadString(cr)
+				sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 				if err != nil {
 					return err
 				}
@@ -363,6 +392,7 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.SixtyThreeBitIntegerWithASignBit (int64) (int64)
 		case "SixtyThreeBitIntegerWithASignBit":
+			pos = cbg.FieldPos{Field: "SixtyThreeBitIntegerWithASignBit"}
 			{
 				maj, extra, err := cr.ReadHeader()
 				var extraI int64
@@ -373,22 +403,23 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 				case cbg.MajUnsignedInt:
 					extraI = int64(extra)
 					if extraI < 0 {
-						return fmt.Errorf("int64 positive overflow")
+						return &cbg.ErrIntOverflow{Type: "int64", Value: extra}
 					}
 				case cbg.MajNegativeInt:
 					extraI = int64(extra)
 					if extraI < 0 {
-						return fmt.Errorf("int64 negative oveflow")
+						return &cbg.ErrIntOverflow{Type: "int64", Value: extra, Negative: true}
 					}
 					extraI = -1 - extraI
 				default:
-					return fmt.Errorf("wrong type for int64 field: %d", maj)
+					return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 				}
 
 				t.SixtyThreeBitIntegerWithASignBit = int64(extraI)
 			}
 			// t.NotPizza (uint64) (uint64)
 		case "NotPizza":
+			pos = cbg.FieldPos{Field: "NotPizza"}
 
 			{
 
@@ -405,7 +436,7 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 						return err
 					}
 					if maj != cbg.MajUnsignedInt {
-						return fmt.Errorf("wrong type for uint64 field")
+						return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 					}
 					typed := uint64(extra)
 					t.NotPizza = &typed
@@ -415,12 +446,282 @@ func (t *SimpleTypeTree) UnmarshalCBOR(r io.Reader) (err error) {
 
 		default:
 			// Field doesn't exist on this type, so ignore it
-			cbg.ScanForLinks(r, func(cid.Cid) {})
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+func (t *SimpleTypeTree) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.Stuff (testing.SimpleTypeTree) (struct)
+	n += cbg.CborHeaderSize(uint64(len("Stuff"))) + len("Stuff")
+
+	n += cbg.SizeOf(t.Stuff)
+
+	// t.Stufff (testing.SimpleTypeTwo) (struct)
+	n += cbg.CborHeaderSize(uint64(len("Stufff"))) + len("Stufff")
+
+	n += cbg.SizeOf(t.Stufff)
+
+	// t.Others ([]uint64) (slice)
+	n += cbg.CborHeaderSize(uint64(len("Others"))) + len("Others")
+
+	n += cbg.CborHeaderSize(uint64(len(t.Others)))
+	for _, v := range t.Others {
+
+		n += cbg.CborHeaderSize(uint64(v))
+
+	}
+
+	// t.Test ([][]uint8) (slice)
+	n += cbg.CborHeaderSize(uint64(len("Test"))) + len("Test")
+
+	n += cbg.CborHeaderSize(uint64(len(t.Test)))
+	for _, v := range t.Test {
+		n += cbg.CborHeaderSize(uint64(len(v))) + len(v)
+	}
+
+	// t.Dog (string) (string)
+	n += cbg.CborHeaderSize(uint64(len("Dog"))) + len("Dog")
+
+	n += cbg.CborHeaderSize(uint64(len(t.Dog))) + len(t.Dog)
+
+	// t.SixtyThreeBitIntegerWithASignBit (int64) (int64)
+	n += cbg.CborHeaderSize(uint64(len("SixtyThreeBitIntegerWithASignBit"))) + len("SixtyThreeBitIntegerWithASignBit")
+
+	n += cbg.CborIntSize(int64(t.SixtyThreeBitIntegerWithASignBit))
+
+	// t.NotPizza (uint64) (uint64)
+	n += cbg.CborHeaderSize(uint64(len("NotPizza"))) + len("NotPizza")
+
+	if t.NotPizza == nil {
+		n += len(cbg.CborNull)
+	} else {
+		n += cbg.CborHeaderSize(uint64(*t.NotPizza))
+	}
+
+	return n
+}
+
+func (t *SimpleTypeTree) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.Stuff (testing.SimpleTypeTree) (struct)
+
+	if t.Stuff != nil {
+
+		if err := cbg.ForEachLink(t.Stuff, fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.Stufff (testing.SimpleTypeTwo) (struct)
+
+	if t.Stufff != nil {
+
+		if err := cbg.ForEachLink(t.Stufff, fn); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+func (t *SimpleTypeTree) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.Stuff (testing.SimpleTypeTree) (struct)
+
+	if t.Stuff != nil {
+
+		if err := cbg.ForEachLinkPath(t.Stuff, cbg.JoinPath(prefix, "Stuff"), fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.Stufff (testing.SimpleTypeTwo) (struct)
+
+	if t.Stufff != nil {
+
+		if err := cbg.ForEachLinkPath(t.Stufff, cbg.JoinPath(prefix, "Stufff"), fn); err != nil {
+			return err
 		}
+
 	}
 
 	return nil
 }
+
+func (t *SimpleTypeTree) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Dog (string) (string)
+	b = append(b, "\"Dog\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Dog); err != nil {
+		return nil, err
+	}
+
+	// t.NotPizza (uint64) (uint64)
+	b = append(b, ",\"NotPizza\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.NotPizza); err != nil {
+		return nil, err
+	}
+
+	// t.Others ([]uint64) (slice)
+	b = append(b, ",\"Others\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Others); err != nil {
+		return nil, err
+	}
+
+	// t.SixtyThreeBitIntegerWithASignBit (int64) (int64)
+	b = append(b, ",\"SixtyThreeBitIntegerWithASignBit\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.SixtyThreeBitIntegerWithASignBit); err != nil {
+		return nil, err
+	}
+
+	// t.Stuff (testing.SimpleTypeTree) (struct)
+	b = append(b, ",\"Stuff\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Stuff); err != nil {
+		return nil, err
+	}
+
+	// t.Stufff (testing.SimpleTypeTwo) (struct)
+	b = append(b, ",\"Stufff\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Stufff); err != nil {
+		return nil, err
+	}
+
+	// t.Test ([][]uint8) (slice)
+	b = append(b, ",\"Test\":"...)
+
+	if t.Test == nil {
+		b = append(b, "null"...)
+	} else {
+		b = append(b, '[')
+		for i := range t.Test {
+			if i > 0 {
+				b = append(b, ',')
+			}
+
+			b = cbg.AppendJSONBytes(b, t.Test[i][:])
+
+		}
+		b = append(b, ']')
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *SimpleTypeTree) UnmarshalJSON(b []byte) error {
+	*t = SimpleTypeTree{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Stuff (testing.SimpleTypeTree) (struct)
+		case "Stuff":
+
+			if err := cbg.ReadJSON(v, &t.Stuff); err != nil {
+				return err
+			}
+		// t.Stufff (testing.SimpleTypeTwo) (struct)
+		case "Stufff":
+
+			if err := cbg.ReadJSON(v, &t.Stufff); err != nil {
+				return err
+			}
+		// t.Others ([]uint64) (slice)
+		case "Others":
+
+			if err := cbg.ReadJSON(v, &t.Others); err != nil {
+				return err
+			}
+			if len(t.Others) > cbg.MaxLength {
+				return fmt.Errorf("t.Others: %w", &cbg.ErrTooLong{Length: uint64(len(t.Others)), Limit: cbg.MaxLength})
+			}
+		// t.Test ([][]uint8) (slice)
+		case "Test":
+
+			{
+				elems, err := cbg.ReadJSONArray(v)
+				if err != nil {
+					return err
+				}
+				if len(elems) > cbg.MaxLength {
+					return fmt.Errorf("t.Test: %w", &cbg.ErrTooLong{Length: uint64(len(elems)), Limit: cbg.MaxLength})
+				}
+				if elems != nil {
+					t.Test = make([][]uint8, len(elems))
+				}
+				for i, v := range elems {
+
+					{
+						bs, err := cbg.ReadJSONBytes(v)
+						if err != nil {
+							return err
+						}
+						if len(bs) > cbg.ByteArrayMaxLen {
+							return fmt.Errorf("t.Test[i]: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: cbg.ByteArrayMaxLen})
+						}
+						t.Test[i] = bs
+					}
+
+				}
+			}
+		// t.Dog (string) (string)
+		case "Dog":
+
+			if err := cbg.ReadJSON(v, &t.Dog); err != nil {
+				return err
+			}
+			if len(t.Dog) > cbg.MaxLength {
+				return fmt.Errorf("t.Dog: %w", &cbg.ErrTooLong{Length: uint64(len(t.Dog)), Limit: cbg.MaxLength})
+			}
+		// t.SixtyThreeBitIntegerWithASignBit (int64) (int64)
+		case "SixtyThreeBitIntegerWithASignBit":
+
+			if err := cbg.ReadJSON(v, &t.SixtyThreeBitIntegerWithASignBit); err != nil {
+				return err
+			}
+		// t.NotPizza (uint64) (uint64)
+		case "NotPizza":
+
+			if err := cbg.ReadJSON(v, &t.NotPizza); err != nil {
+				return err
+			}
+
+		}
+		return nil
+	})
+}
+
 func (t *NeedScratchForMap) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
@@ -455,6 +756,16 @@ func (t *NeedScratchForMap) UnmarshalCBOR(r io.Reader) (err error) {
 	*t = NeedScratchForMap{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "NeedScratchForMap", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -467,20 +778,21 @@ func (t *NeedScratchForMap) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajMap {
-		return fmt.Errorf("cbor input should be of type map")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("NeedScratchForMap: map struct too large (%d)", extra)
+		return fmt.Errorf("NeedScratchForMap: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	var name string
 	n := extra
 
 	for i := uint64(0); i < n; i++ {
+		pos = cbg.FieldPos{}
 
 		{
-			sval, err := cbg.ReadString(cr)
+			sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 			if err != nil {
 				return err
 			}
@@ -491,13 +803,14 @@ func (t *NeedScratchForMap) UnmarshalCBOR(r io.Reader) (err error) {
 		switch name {
 		// t.Thing (bool) (bool)
 		case "Thing":
+			pos = cbg.FieldPos{Field: "Thing"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
 				return err
 			}
 			if maj != cbg.MajOther {
-				return fmt.Errorf("booleans must be major type 7")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajOther, Got: maj}
 			}
 			switch extra {
 			case 20:
@@ -510,12 +823,79 @@ func (t *NeedScratchForMap) UnmarshalCBOR(r io.Reader) (err error) {
 
 		default:
 			// Field doesn't exist on this type, so ignore it
-			cbg.ScanForLinks(r, func(cid.Cid) {})
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
+func (t *NeedScratchForMap) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.Thing (bool) (bool)
+	n += cbg.CborHeaderSize(uint64(len("Thing"))) + len("Thing")
+
+	n += 1
+	return n
+}
+
+func (t *NeedScratchForMap) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *NeedScratchForMap) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *NeedScratchForMap) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Thing (bool) (bool)
+	b = append(b, "\"Thing\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Thing); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *NeedScratchForMap) UnmarshalJSON(b []byte) error {
+	*t = NeedScratchForMap{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Thing (bool) (bool)
+		case "Thing":
+
+			if err := cbg.ReadJSON(v, &t.Thing); err != nil {
+				return err
+			}
+
+		}
+		return nil
+	})
+}
+
 func (t *SimpleStructV1) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
@@ -707,6 +1087,16 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 	*t = SimpleStructV1{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "SimpleStructV1", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -719,20 +1109,21 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajMap {
-		return fmt.Errorf("cbor input should be of type map")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("SimpleStructV1: map struct too large (%d)", extra)
+		return fmt.Errorf("SimpleStructV1: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	var name string
 	n := extra
 
 	for i := uint64(0); i < n; i++ {
+		pos = cbg.FieldPos{}
 
 		{
-			sval, err := cbg.ReadString(cr)
+			sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 			if err != nil {
 				return err
 			}
@@ -743,9 +1134,10 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 		switch name {
 		// t.OldStr (string) (string)
 		case "OldStr":
+			pos = cbg.FieldPos{Field: "OldStr"}
 
 			{
-				sval, err := cbg.ReadString(cr)
+				sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 				if err != nil {
 					return err
 				}
@@ -754,6 +1146,7 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.OldBytes ([]uint8) (slice)
 		case "OldBytes":
+			pos = cbg.FieldPos{Field: "OldBytes"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
@@ -761,13 +1154,16 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.OldBytes: byte array too large (%d)", extra)
+				return fmt.Errorf("t.OldBytes: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.ByteArrayMaxLen})
 			}
 			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
 			}
 
 			if extra > 0 {
+				if err := cr.Allocate(extra * 1); err != nil {
+					return err
+				}
 				t.OldBytes = make([]uint8, extra)
 			}
 
@@ -776,6 +1172,7 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.OldNum (uint64) (uint64)
 		case "OldNum":
+			pos = cbg.FieldPos{Field: "OldNum"}
 
 			{
 
@@ -784,13 +1181,14 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 					return err
 				}
 				if maj != cbg.MajUnsignedInt {
-					return fmt.Errorf("wrong type for uint64 field")
+					return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 				}
 				t.OldNum = uint64(extra)
 
 			}
 			// t.OldPtr (cid.Cid) (struct)
 		case "OldPtr":
+			pos = cbg.FieldPos{Field: "OldPtr"}
 
 			{
 
@@ -814,32 +1212,40 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.OldMap (map[string]testing.SimpleTypeOne) (map)
 		case "OldMap":
+			pos = cbg.FieldPos{Field: "OldMap"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
 				return err
 			}
 			if maj != cbg.MajMap {
-				return fmt.Errorf("expected a map (major type 5)")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 			}
 			if extra > 4096 {
-				return fmt.Errorf("t.OldMap: map too large")
+				return fmt.Errorf("t.OldMap: %w", &cbg.ErrTooLong{Length: extra, Limit: 4096})
+			}
+
+			if err := cr.Allocate(extra * 88); err != nil {
+				return err
 			}
 
 			t.OldMap = make(map[string]SimpleTypeOne, extra)
 
 			for i, l := 0, int(extra); i < l; i++ {
+				pos.HasKey = false
 
 				var k string
 
 				{
-					sval, err := cbg.ReadString(cr)
+					sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 					if err != nil {
 						return err
 					}
 
 					k = string(sval)
 				}
+				pos.Key = k
+				pos.HasKey = true
 
 				var v SimpleTypeOne
 
@@ -856,6 +1262,7 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.OldArray ([]testing.SimpleTypeOne) (slice)
 		case "OldArray":
+			pos = cbg.FieldPos{Field: "OldArray"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
@@ -863,18 +1270,23 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.MaxLength {
-				return fmt.Errorf("t.OldArray: array too large (%d)", extra)
+				return fmt.Errorf("t.OldArray: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 			}
 
 			if maj != cbg.MajArray {
-				return fmt.Errorf("expected cbor array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 			}
 
 			if extra > 0 {
+				if err := cr.Allocate(extra * 72); err != nil {
+					return err
+				}
 				t.OldArray = make([]SimpleTypeOne, extra)
 			}
 
 			for i := 0; i < int(extra); i++ {
+				pos.Index[0] = i
+				pos.Depth = 1
 
 				var v SimpleTypeOne
 				if err := v.UnmarshalCBOR(cr); err != nil {
@@ -886,6 +1298,7 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 
 			// t.OldStruct (testing.SimpleTypeOne) (struct)
 		case "OldStruct":
+			pos = cbg.FieldPos{Field: "OldStruct"}
 
 			{
 
@@ -897,39 +1310,360 @@ func (t *SimpleStructV1) UnmarshalCBOR(r io.Reader) (err error) {
 
 		default:
 			// Field doesn't exist on this type, so ignore it
-			cbg.ScanForLinks(r, func(cid.Cid) {})
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
-func (t *SimpleStructV2) MarshalCBOR(w io.Writer) error {
+func (t *SimpleStructV1) CBORSize() int {
 	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
+		return len(cbg.CborNull)
 	}
 
-	cw := cbg.NewCborWriter(w)
-
-	if _, err := cw.Write([]byte{174}); err != nil {
-		return err
-	}
+	n := 1
 
 	// t.OldStr (string) (string)
-	if len("OldStr") > cbg.MaxLength {
-		return xerrors.Errorf("Value in field \"OldStr\" was too long")
-	}
+	n += cbg.CborHeaderSize(uint64(len("OldStr"))) + len("OldStr")
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("OldStr"))); err != nil {
-		return err
-	}
-	if _, err := io.WriteString(w, string("OldStr")); err != nil {
-		return err
-	}
+	n += cbg.CborHeaderSize(uint64(len(t.OldStr))) + len(t.OldStr)
 
-	if len(t.OldStr) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.OldStr was too long")
-	}
+	// t.OldBytes ([]uint8) (slice)
+	n += cbg.CborHeaderSize(uint64(len("OldBytes"))) + len("OldBytes")
+
+	n += cbg.CborHeaderSize(uint64(len(t.OldBytes))) + len(t.OldBytes)
+
+	// t.OldNum (uint64) (uint64)
+	n += cbg.CborHeaderSize(uint64(len("OldNum"))) + len("OldNum")
+
+	n += cbg.CborHeaderSize(uint64(t.OldNum))
+
+	// t.OldPtr (cid.Cid) (struct)
+	n += cbg.CborHeaderSize(uint64(len("OldPtr"))) + len("OldPtr")
+
+	if t.OldPtr == nil {
+		n += len(cbg.CborNull)
+	} else {
+		n += cbg.CidSize(*t.OldPtr)
+	}
+
+	// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+	n += cbg.CborHeaderSize(uint64(len("OldMap"))) + len("OldMap")
+
+	n += cbg.CborHeaderSize(uint64(len(t.OldMap)))
+	for k, v := range t.OldMap {
+
+		n += cbg.CborHeaderSize(uint64(len(k))) + len(k)
+
+		n += cbg.SizeOf(&v)
+
+	}
+
+	// t.OldArray ([]testing.SimpleTypeOne) (slice)
+	n += cbg.CborHeaderSize(uint64(len("OldArray"))) + len("OldArray")
+
+	n += cbg.CborHeaderSize(uint64(len(t.OldArray)))
+	for _, v := range t.OldArray {
+		n += cbg.SizeOf(&v)
+	}
+
+	// t.OldStruct (testing.SimpleTypeOne) (struct)
+	n += cbg.CborHeaderSize(uint64(len("OldStruct"))) + len("OldStruct")
+
+	n += cbg.SizeOf(&t.OldStruct)
+	return n
+}
+
+func (t *SimpleStructV1) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.OldPtr (cid.Cid) (struct)
+
+	if t.OldPtr != nil && t.OldPtr.Defined() {
+		if err := fn(*t.OldPtr); err != nil {
+			return err
+		}
+	}
+
+	// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+	{
+		keys := make([]string, 0, len(t.OldMap))
+		for k := range t.OldMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := t.OldMap[k]
+
+			if err := cbg.ForEachLink(&v, fn); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.OldArray ([]testing.SimpleTypeOne) (slice)
+	for i := range t.OldArray {
+
+		if err := cbg.ForEachLink(&t.OldArray[i], fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.OldStruct (testing.SimpleTypeOne) (struct)
+
+	if err := cbg.ForEachLink(&t.OldStruct, fn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *SimpleStructV1) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.OldPtr (cid.Cid) (struct)
+
+	if t.OldPtr != nil && t.OldPtr.Defined() {
+		if err := fn(cbg.JoinPath(prefix, "OldPtr"), *t.OldPtr); err != nil {
+			return err
+		}
+	}
+
+	// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+	{
+		keys := make([]string, 0, len(t.OldMap))
+		for k := range t.OldMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := t.OldMap[k]
+
+			if err := cbg.ForEachLinkPath(&v, cbg.JoinPath(cbg.JoinPath(prefix, "OldMap"), k), fn); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.OldArray ([]testing.SimpleTypeOne) (slice)
+	for i := range t.OldArray {
+
+		if err := cbg.ForEachLinkPath(&t.OldArray[i], cbg.JoinPathIndex(cbg.JoinPath(prefix, "OldArray"), i), fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.OldStruct (testing.SimpleTypeOne) (struct)
+
+	if err := cbg.ForEachLinkPath(&t.OldStruct, cbg.JoinPath(prefix, "OldStruct"), fn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *SimpleStructV1) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.OldArray ([]testing.SimpleTypeOne) (slice)
+	b = append(b, "\"OldArray\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldArray); err != nil {
+		return nil, err
+	}
+
+	// t.OldBytes ([]uint8) (slice)
+	b = append(b, ",\"OldBytes\":"...)
+
+	b = cbg.AppendJSONBytes(b, t.OldBytes[:])
+
+	// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+	b = append(b, ",\"OldMap\":"...)
+
+	if t.OldMap == nil {
+		b = append(b, "null"...)
+	} else {
+		keys := make([]string, 0, len(t.OldMap))
+		for k := range t.OldMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = cbg.AppendJSON(b, k); err != nil {
+				return nil, err
+			}
+			b = append(b, ':')
+			v := t.OldMap[k]
+
+			if b, err = cbg.AppendJSON(b, &v); err != nil {
+				return nil, err
+			}
+
+		}
+		b = append(b, '}')
+	}
+
+	// t.OldNum (uint64) (uint64)
+	b = append(b, ",\"OldNum\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldNum); err != nil {
+		return nil, err
+	}
+
+	// t.OldPtr (cid.Cid) (struct)
+	b = append(b, ",\"OldPtr\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldPtr); err != nil {
+		return nil, err
+	}
+
+	// t.OldStr (string) (string)
+	b = append(b, ",\"OldStr\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldStr); err != nil {
+		return nil, err
+	}
+
+	// t.OldStruct (testing.SimpleTypeOne) (struct)
+	b = append(b, ",\"OldStruct\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldStruct); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *SimpleStructV1) UnmarshalJSON(b []byte) error {
+	*t = SimpleStructV1{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.OldStr (string) (string)
+		case "OldStr":
+
+			if err := cbg.ReadJSON(v, &t.OldStr); err != nil {
+				return err
+			}
+			if len(t.OldStr) > cbg.MaxLength {
+				return fmt.Errorf("t.OldStr: %w", &cbg.ErrTooLong{Length: uint64(len(t.OldStr)), Limit: cbg.MaxLength})
+			}
+		// t.OldBytes ([]uint8) (slice)
+		case "OldBytes":
+
+			{
+				bs, err := cbg.ReadJSONBytes(v)
+				if err != nil {
+					return err
+				}
+				if len(bs) > cbg.ByteArrayMaxLen {
+					return fmt.Errorf("t.OldBytes: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: cbg.ByteArrayMaxLen})
+				}
+				t.OldBytes = bs
+			}
+		// t.OldNum (uint64) (uint64)
+		case "OldNum":
+
+			if err := cbg.ReadJSON(v, &t.OldNum); err != nil {
+				return err
+			}
+		// t.OldPtr (cid.Cid) (struct)
+		case "OldPtr":
+
+			if err := cbg.ReadJSON(v, &t.OldPtr); err != nil {
+				return err
+			}
+		// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+		case "OldMap":
+
+			if !cbg.IsJSONNull(v) {
+				t.OldMap = make(map[string]SimpleTypeOne)
+				if err := cbg.ReadJSONObject(v, func(k string, v []byte) error {
+					if len(t.OldMap) >= 4096 {
+						return fmt.Errorf("t.OldMap: %w", &cbg.ErrTooLong{Length: uint64(len(t.OldMap)) + 1, Limit: 4096})
+					}
+					var val SimpleTypeOne
+
+					if err := cbg.ReadJSON(v, &val); err != nil {
+						return err
+					}
+
+					t.OldMap[k] = val
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		// t.OldArray ([]testing.SimpleTypeOne) (slice)
+		case "OldArray":
+
+			if err := cbg.ReadJSON(v, &t.OldArray); err != nil {
+				return err
+			}
+			if len(t.OldArray) > cbg.MaxLength {
+				return fmt.Errorf("t.OldArray: %w", &cbg.ErrTooLong{Length: uint64(len(t.OldArray)), Limit: cbg.MaxLength})
+			}
+		// t.OldStruct (testing.SimpleTypeOne) (struct)
+		case "OldStruct":
+
+			if err := cbg.ReadJSON(v, &t.OldStruct); err != nil {
+				return err
+			}
+
+		}
+		return nil
+	})
+}
+
+func (t *SimpleStructV2) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	cw := cbg.NewCborWriter(w)
+
+	if _, err := cw.Write([]byte{174}); err != nil {
+		return err
+	}
+
+	// t.OldStr (string) (string)
+	if len("OldStr") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"OldStr\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("OldStr"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("OldStr")); err != nil {
+		return err
+	}
+
+	if len(t.OldStr) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.OldStr was too long")
+	}
 
 	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.OldStr))); err != nil {
 		return err
@@ -1267,6 +2001,16 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 	*t = SimpleStructV2{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "SimpleStructV2", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -1279,20 +2023,21 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajMap {
-		return fmt.Errorf("cbor input should be of type map")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("SimpleStructV2: map struct too large (%d)", extra)
+		return fmt.Errorf("SimpleStructV2: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	var name string
 	n := extra
 
 	for i := uint64(0); i < n; i++ {
+		pos = cbg.FieldPos{}
 
 		{
-			sval, err := cbg.ReadString(cr)
+			sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 			if err != nil {
 				return err
 			}
@@ -1303,9 +2048,10 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 		switch name {
 		// t.OldStr (string) (string)
 		case "OldStr":
+			pos = cbg.FieldPos{Field: "OldStr"}
 
 			{
-				sval, err := cbg.ReadString(cr)
+				sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 				if err != nil {
 					return err
 				}
@@ -1314,9 +2060,10 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.NewStr (string) (string)
 		case "NewStr":
+			pos = cbg.FieldPos{Field: "NewStr"}
 
 			{
-				sval, err := cbg.ReadString(cr)
+				sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 				if err != nil {
 					return err
 				}
@@ -1325,6 +2072,7 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.OldBytes ([]uint8) (slice)
 		case "OldBytes":
+			pos = cbg.FieldPos{Field: "OldBytes"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
@@ -1332,13 +2080,16 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.OldBytes: byte array too large (%d)", extra)
+				return fmt.Errorf("t.OldBytes: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.ByteArrayMaxLen})
 			}
 			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
 			}
 
 			if extra > 0 {
+				if err := cr.Allocate(extra * 1); err != nil {
+					return err
+				}
 				t.OldBytes = make([]uint8, extra)
 			}
 
@@ -1347,6 +2098,7 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.NewBytes ([]uint8) (slice)
 		case "NewBytes":
+			pos = cbg.FieldPos{Field: "NewBytes"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
@@ -1354,13 +2106,16 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.ByteArrayMaxLen {
-				return fmt.Errorf("t.NewBytes: byte array too large (%d)", extra)
+				return fmt.Errorf("t.NewBytes: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.ByteArrayMaxLen})
 			}
 			if maj != cbg.MajByteString {
-				return fmt.Errorf("expected byte array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajByteString, Got: maj}
 			}
 
 			if extra > 0 {
+				if err := cr.Allocate(extra * 1); err != nil {
+					return err
+				}
 				t.NewBytes = make([]uint8, extra)
 			}
 
@@ -1369,6 +2124,7 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.OldNum (uint64) (uint64)
 		case "OldNum":
+			pos = cbg.FieldPos{Field: "OldNum"}
 
 			{
 
@@ -1377,13 +2133,14 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 					return err
 				}
 				if maj != cbg.MajUnsignedInt {
-					return fmt.Errorf("wrong type for uint64 field")
+					return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 				}
 				t.OldNum = uint64(extra)
 
 			}
 			// t.NewNum (uint64) (uint64)
 		case "NewNum":
+			pos = cbg.FieldPos{Field: "NewNum"}
 
 			{
 
@@ -1392,13 +2149,14 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 					return err
 				}
 				if maj != cbg.MajUnsignedInt {
-					return fmt.Errorf("wrong type for uint64 field")
+					return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
 				}
 				t.NewNum = uint64(extra)
 
 			}
 			// t.OldPtr (cid.Cid) (struct)
 		case "OldPtr":
+			pos = cbg.FieldPos{Field: "OldPtr"}
 
 			{
 
@@ -1422,6 +2180,7 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.NewPtr (cid.Cid) (struct)
 		case "NewPtr":
+			pos = cbg.FieldPos{Field: "NewPtr"}
 
 			{
 
@@ -1445,32 +2204,40 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.OldMap (map[string]testing.SimpleTypeOne) (map)
 		case "OldMap":
+			pos = cbg.FieldPos{Field: "OldMap"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
 				return err
 			}
 			if maj != cbg.MajMap {
-				return fmt.Errorf("expected a map (major type 5)")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 			}
 			if extra > 4096 {
-				return fmt.Errorf("t.OldMap: map too large")
+				return fmt.Errorf("t.OldMap: %w", &cbg.ErrTooLong{Length: extra, Limit: 4096})
+			}
+
+			if err := cr.Allocate(extra * 88); err != nil {
+				return err
 			}
 
 			t.OldMap = make(map[string]SimpleTypeOne, extra)
 
 			for i, l := 0, int(extra); i < l; i++ {
+				pos.HasKey = false
 
 				var k string
 
 				{
-					sval, err := cbg.ReadString(cr)
+					sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 					if err != nil {
 						return err
 					}
 
 					k = string(sval)
 				}
+				pos.Key = k
+				pos.HasKey = true
 
 				var v SimpleTypeOne
 
@@ -1487,32 +2254,40 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.NewMap (map[string]testing.SimpleTypeOne) (map)
 		case "NewMap":
+			pos = cbg.FieldPos{Field: "NewMap"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
 				return err
 			}
 			if maj != cbg.MajMap {
-				return fmt.Errorf("expected a map (major type 5)")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 			}
 			if extra > 4096 {
-				return fmt.Errorf("t.NewMap: map too large")
+				return fmt.Errorf("t.NewMap: %w", &cbg.ErrTooLong{Length: extra, Limit: 4096})
+			}
+
+			if err := cr.Allocate(extra * 88); err != nil {
+				return err
 			}
 
 			t.NewMap = make(map[string]SimpleTypeOne, extra)
 
 			for i, l := 0, int(extra); i < l; i++ {
+				pos.HasKey = false
 
 				var k string
 
 				{
-					sval, err := cbg.ReadString(cr)
+					sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 					if err != nil {
 						return err
 					}
 
 					k = string(sval)
 				}
+				pos.Key = k
+				pos.HasKey = true
 
 				var v SimpleTypeOne
 
@@ -1529,6 +2304,7 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.OldArray ([]testing.SimpleTypeOne) (slice)
 		case "OldArray":
+			pos = cbg.FieldPos{Field: "OldArray"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
@@ -1536,18 +2312,23 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.MaxLength {
-				return fmt.Errorf("t.OldArray: array too large (%d)", extra)
+				return fmt.Errorf("t.OldArray: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 			}
 
 			if maj != cbg.MajArray {
-				return fmt.Errorf("expected cbor array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 			}
 
 			if extra > 0 {
+				if err := cr.Allocate(extra * 72); err != nil {
+					return err
+				}
 				t.OldArray = make([]SimpleTypeOne, extra)
 			}
 
 			for i := 0; i < int(extra); i++ {
+				pos.Index[0] = i
+				pos.Depth = 1
 
 				var v SimpleTypeOne
 				if err := v.UnmarshalCBOR(cr); err != nil {
@@ -1559,6 +2340,7 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 
 			// t.NewArray ([]testing.SimpleTypeOne) (slice)
 		case "NewArray":
+			pos = cbg.FieldPos{Field: "NewArray"}
 
 			maj, extra, err = cr.ReadHeader()
 			if err != nil {
@@ -1566,18 +2348,23 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 
 			if extra > cbg.MaxLength {
-				return fmt.Errorf("t.NewArray: array too large (%d)", extra)
+				return fmt.Errorf("t.NewArray: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 			}
 
 			if maj != cbg.MajArray {
-				return fmt.Errorf("expected cbor array")
+				return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
 			}
 
 			if extra > 0 {
+				if err := cr.Allocate(extra * 72); err != nil {
+					return err
+				}
 				t.NewArray = make([]SimpleTypeOne, extra)
 			}
 
 			for i := 0; i < int(extra); i++ {
+				pos.Index[0] = i
+				pos.Depth = 1
 
 				var v SimpleTypeOne
 				if err := v.UnmarshalCBOR(cr); err != nil {
@@ -1589,6 +2376,7 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 
 			// t.OldStruct (testing.SimpleTypeOne) (struct)
 		case "OldStruct":
+			pos = cbg.FieldPos{Field: "OldStruct"}
 
 			{
 
@@ -1599,6 +2387,7 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 			}
 			// t.NewStruct (testing.SimpleTypeOne) (struct)
 		case "NewStruct":
+			pos = cbg.FieldPos{Field: "NewStruct"}
 
 			{
 
@@ -1610,75 +2399,923 @@ func (t *SimpleStructV2) UnmarshalCBOR(r io.Reader) (err error) {
 
 		default:
 			// Field doesn't exist on this type, so ignore it
-			cbg.ScanForLinks(r, func(cid.Cid) {})
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
-func (t *RenamedFields) MarshalCBOR(w io.Writer) error {
+func (t *SimpleStructV2) CBORSize() int {
 	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
+		return len(cbg.CborNull)
 	}
 
-	cw := cbg.NewCborWriter(w)
+	n := 1
 
-	if _, err := cw.Write([]byte{162}); err != nil {
-		return err
-	}
+	// t.OldStr (string) (string)
+	n += cbg.CborHeaderSize(uint64(len("OldStr"))) + len("OldStr")
+
+	n += cbg.CborHeaderSize(uint64(len(t.OldStr))) + len(t.OldStr)
+
+	// t.NewStr (string) (string)
+	n += cbg.CborHeaderSize(uint64(len("NewStr"))) + len("NewStr")
+
+	n += cbg.CborHeaderSize(uint64(len(t.NewStr))) + len(t.NewStr)
+
+	// t.OldBytes ([]uint8) (slice)
+	n += cbg.CborHeaderSize(uint64(len("OldBytes"))) + len("OldBytes")
+
+	n += cbg.CborHeaderSize(uint64(len(t.OldBytes))) + len(t.OldBytes)
+
+	// t.NewBytes ([]uint8) (slice)
+	n += cbg.CborHeaderSize(uint64(len("NewBytes"))) + len("NewBytes")
+
+	n += cbg.CborHeaderSize(uint64(len(t.NewBytes))) + len(t.NewBytes)
+
+	// t.OldNum (uint64) (uint64)
+	n += cbg.CborHeaderSize(uint64(len("OldNum"))) + len("OldNum")
+
+	n += cbg.CborHeaderSize(uint64(t.OldNum))
+
+	// t.NewNum (uint64) (uint64)
+	n += cbg.CborHeaderSize(uint64(len("NewNum"))) + len("NewNum")
+
+	n += cbg.CborHeaderSize(uint64(t.NewNum))
+
+	// t.OldPtr (cid.Cid) (struct)
+	n += cbg.CborHeaderSize(uint64(len("OldPtr"))) + len("OldPtr")
+
+	if t.OldPtr == nil {
+		n += len(cbg.CborNull)
+	} else {
+		n += cbg.CidSize(*t.OldPtr)
+	}
+
+	// t.NewPtr (cid.Cid) (struct)
+	n += cbg.CborHeaderSize(uint64(len("NewPtr"))) + len("NewPtr")
+
+	if t.NewPtr == nil {
+		n += len(cbg.CborNull)
+	} else {
+		n += cbg.CidSize(*t.NewPtr)
+	}
+
+	// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+	n += cbg.CborHeaderSize(uint64(len("OldMap"))) + len("OldMap")
+
+	n += cbg.CborHeaderSize(uint64(len(t.OldMap)))
+	for k, v := range t.OldMap {
+
+		n += cbg.CborHeaderSize(uint64(len(k))) + len(k)
+
+		n += cbg.SizeOf(&v)
+
+	}
+
+	// t.NewMap (map[string]testing.SimpleTypeOne) (map)
+	n += cbg.CborHeaderSize(uint64(len("NewMap"))) + len("NewMap")
+
+	n += cbg.CborHeaderSize(uint64(len(t.NewMap)))
+	for k, v := range t.NewMap {
+
+		n += cbg.CborHeaderSize(uint64(len(k))) + len(k)
+
+		n += cbg.SizeOf(&v)
+
+	}
+
+	// t.OldArray ([]testing.SimpleTypeOne) (slice)
+	n += cbg.CborHeaderSize(uint64(len("OldArray"))) + len("OldArray")
+
+	n += cbg.CborHeaderSize(uint64(len(t.OldArray)))
+	for _, v := range t.OldArray {
+		n += cbg.SizeOf(&v)
+	}
+
+	// t.NewArray ([]testing.SimpleTypeOne) (slice)
+	n += cbg.CborHeaderSize(uint64(len("NewArray"))) + len("NewArray")
+
+	n += cbg.CborHeaderSize(uint64(len(t.NewArray)))
+	for _, v := range t.NewArray {
+		n += cbg.SizeOf(&v)
+	}
+
+	// t.OldStruct (testing.SimpleTypeOne) (struct)
+	n += cbg.CborHeaderSize(uint64(len("OldStruct"))) + len("OldStruct")
+
+	n += cbg.SizeOf(&t.OldStruct)
+
+	// t.NewStruct (testing.SimpleTypeOne) (struct)
+	n += cbg.CborHeaderSize(uint64(len("NewStruct"))) + len("NewStruct")
+
+	n += cbg.SizeOf(&t.NewStruct)
+	return n
+}
+
+func (t *SimpleStructV2) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.OldPtr (cid.Cid) (struct)
+
+	if t.OldPtr != nil && t.OldPtr.Defined() {
+		if err := fn(*t.OldPtr); err != nil {
+			return err
+		}
+	}
+
+	// t.NewPtr (cid.Cid) (struct)
+
+	if t.NewPtr != nil && t.NewPtr.Defined() {
+		if err := fn(*t.NewPtr); err != nil {
+			return err
+		}
+	}
+
+	// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+	{
+		keys := make([]string, 0, len(t.OldMap))
+		for k := range t.OldMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := t.OldMap[k]
+
+			if err := cbg.ForEachLink(&v, fn); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.NewMap (map[string]testing.SimpleTypeOne) (map)
+	{
+		keys := make([]string, 0, len(t.NewMap))
+		for k := range t.NewMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := t.NewMap[k]
+
+			if err := cbg.ForEachLink(&v, fn); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.OldArray ([]testing.SimpleTypeOne) (slice)
+	for i := range t.OldArray {
+
+		if err := cbg.ForEachLink(&t.OldArray[i], fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.NewArray ([]testing.SimpleTypeOne) (slice)
+	for i := range t.NewArray {
+
+		if err := cbg.ForEachLink(&t.NewArray[i], fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.OldStruct (testing.SimpleTypeOne) (struct)
+
+	if err := cbg.ForEachLink(&t.OldStruct, fn); err != nil {
+		return err
+	}
+
+	// t.NewStruct (testing.SimpleTypeOne) (struct)
+
+	if err := cbg.ForEachLink(&t.NewStruct, fn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *SimpleStructV2) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.OldPtr (cid.Cid) (struct)
+
+	if t.OldPtr != nil && t.OldPtr.Defined() {
+		if err := fn(cbg.JoinPath(prefix, "OldPtr"), *t.OldPtr); err != nil {
+			return err
+		}
+	}
+
+	// t.NewPtr (cid.Cid) (struct)
+
+	if t.NewPtr != nil && t.NewPtr.Defined() {
+		if err := fn(cbg.JoinPath(prefix, "NewPtr"), *t.NewPtr); err != nil {
+			return err
+		}
+	}
+
+	// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+	{
+		keys := make([]string, 0, len(t.OldMap))
+		for k := range t.OldMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := t.OldMap[k]
+
+			if err := cbg.ForEachLinkPath(&v, cbg.JoinPath(cbg.JoinPath(prefix, "OldMap"), k), fn); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.NewMap (map[string]testing.SimpleTypeOne) (map)
+	{
+		keys := make([]string, 0, len(t.NewMap))
+		for k := range t.NewMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := t.NewMap[k]
+
+			if err := cbg.ForEachLinkPath(&v, cbg.JoinPath(cbg.JoinPath(prefix, "NewMap"), k), fn); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.OldArray ([]testing.SimpleTypeOne) (slice)
+	for i := range t.OldArray {
+
+		if err := cbg.ForEachLinkPath(&t.OldArray[i], cbg.JoinPathIndex(cbg.JoinPath(prefix, "OldArray"), i), fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.NewArray ([]testing.SimpleTypeOne) (slice)
+	for i := range t.NewArray {
+
+		if err := cbg.ForEachLinkPath(&t.NewArray[i], cbg.JoinPathIndex(cbg.JoinPath(prefix, "NewArray"), i), fn); err != nil {
+			return err
+		}
+
+	}
+
+	// t.OldStruct (testing.SimpleTypeOne) (struct)
+
+	if err := cbg.ForEachLinkPath(&t.OldStruct, cbg.JoinPath(prefix, "OldStruct"), fn); err != nil {
+		return err
+	}
+
+	// t.NewStruct (testing.SimpleTypeOne) (struct)
+
+	if err := cbg.ForEachLinkPath(&t.NewStruct, cbg.JoinPath(prefix, "NewStruct"), fn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *SimpleStructV2) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.NewArray ([]testing.SimpleTypeOne) (slice)
+	b = append(b, "\"NewArray\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.NewArray); err != nil {
+		return nil, err
+	}
+
+	// t.NewBytes ([]uint8) (slice)
+	b = append(b, ",\"NewBytes\":"...)
+
+	b = cbg.AppendJSONBytes(b, t.NewBytes[:])
+
+	// t.NewMap (map[string]testing.SimpleTypeOne) (map)
+	b = append(b, ",\"NewMap\":"...)
+
+	if t.NewMap == nil {
+		b = append(b, "null"...)
+	} else {
+		keys := make([]string, 0, len(t.NewMap))
+		for k := range t.NewMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = cbg.AppendJSON(b, k); err != nil {
+				return nil, err
+			}
+			b = append(b, ':')
+			v := t.NewMap[k]
+
+			if b, err = cbg.AppendJSON(b, &v); err != nil {
+				return nil, err
+			}
+
+		}
+		b = append(b, '}')
+	}
+
+	// t.NewNum (uint64) (uint64)
+	b = append(b, ",\"NewNum\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.NewNum); err != nil {
+		return nil, err
+	}
+
+	// t.NewPtr (cid.Cid) (struct)
+	b = append(b, ",\"NewPtr\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.NewPtr); err != nil {
+		return nil, err
+	}
+
+	// t.NewStr (string) (string)
+	b = append(b, ",\"NewStr\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.NewStr); err != nil {
+		return nil, err
+	}
+
+	// t.NewStruct (testing.SimpleTypeOne) (struct)
+	b = append(b, ",\"NewStruct\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.NewStruct); err != nil {
+		return nil, err
+	}
+
+	// t.OldArray ([]testing.SimpleTypeOne) (slice)
+	b = append(b, ",\"OldArray\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldArray); err != nil {
+		return nil, err
+	}
+
+	// t.OldBytes ([]uint8) (slice)
+	b = append(b, ",\"OldBytes\":"...)
+
+	b = cbg.AppendJSONBytes(b, t.OldBytes[:])
+
+	// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+	b = append(b, ",\"OldMap\":"...)
+
+	if t.OldMap == nil {
+		b = append(b, "null"...)
+	} else {
+		keys := make([]string, 0, len(t.OldMap))
+		for k := range t.OldMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = cbg.AppendJSON(b, k); err != nil {
+				return nil, err
+			}
+			b = append(b, ':')
+			v := t.OldMap[k]
+
+			if b, err = cbg.AppendJSON(b, &v); err != nil {
+				return nil, err
+			}
+
+		}
+		b = append(b, '}')
+	}
+
+	// t.OldNum (uint64) (uint64)
+	b = append(b, ",\"OldNum\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldNum); err != nil {
+		return nil, err
+	}
+
+	// t.OldPtr (cid.Cid) (struct)
+	b = append(b, ",\"OldPtr\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldPtr); err != nil {
+		return nil, err
+	}
+
+	// t.OldStr (string) (string)
+	b = append(b, ",\"OldStr\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldStr); err != nil {
+		return nil, err
+	}
+
+	// t.OldStruct (testing.SimpleTypeOne) (struct)
+	b = append(b, ",\"OldStruct\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.OldStruct); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *SimpleStructV2) UnmarshalJSON(b []byte) error {
+	*t = SimpleStructV2{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.OldStr (string) (string)
+		case "OldStr":
+
+			if err := cbg.ReadJSON(v, &t.OldStr); err != nil {
+				return err
+			}
+			if len(t.OldStr) > cbg.MaxLength {
+				return fmt.Errorf("t.OldStr: %w", &cbg.ErrTooLong{Length: uint64(len(t.OldStr)), Limit: cbg.MaxLength})
+			}
+		// t.NewStr (string) (string)
+		case "NewStr":
+
+			if err := cbg.ReadJSON(v, &t.NewStr); err != nil {
+				return err
+			}
+			if len(t.NewStr) > cbg.MaxLength {
+				return fmt.Errorf("t.NewStr: %w", &cbg.ErrTooLong{Length: uint64(len(t.NewStr)), Limit: cbg.MaxLength})
+			}
+		// t.OldBytes ([]uint8) (slice)
+		case "OldBytes":
+
+			{
+				bs, err := cbg.ReadJSONBytes(v)
+				if err != nil {
+					return err
+				}
+				if len(bs) > cbg.ByteArrayMaxLen {
+					return fmt.Errorf("t.OldBytes: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: cbg.ByteArrayMaxLen})
+				}
+				t.OldBytes = bs
+			}
+		// t.NewBytes ([]uint8) (slice)
+		case "NewBytes":
+
+			{
+				bs, err := cbg.ReadJSONBytes(v)
+				if err != nil {
+					return err
+				}
+				if len(bs) > cbg.ByteArrayMaxLen {
+					return fmt.Errorf("t.NewBytes: %w", &cbg.ErrTooLong{Length: uint64(len(bs)), Limit: cbg.ByteArrayMaxLen})
+				}
+				t.NewBytes = bs
+			}
+		// t.OldNum (uint64) (uint64)
+		case "OldNum":
+
+			if err := cbg.ReadJSON(v, &t.OldNum); err != nil {
+				return err
+			}
+		// t.NewNum (uint64) (uint64)
+		case "NewNum":
+
+			if err := cbg.ReadJSON(v, &t.NewNum); err != nil {
+				return err
+			}
+		// t.OldPtr (cid.Cid) (struct)
+		case "OldPtr":
+
+			if err := cbg.ReadJSON(v, &t.OldPtr); err != nil {
+				return err
+			}
+		// t.NewPtr (cid.Cid) (struct)
+		case "NewPtr":
+
+			if err := cbg.ReadJSON(v, &t.NewPtr); err != nil {
+				return err
+			}
+		// t.OldMap (map[string]testing.SimpleTypeOne) (map)
+		case "OldMap":
+
+			if !cbg.IsJSONNull(v) {
+				t.OldMap = make(map[string]SimpleTypeOne)
+				if err := cbg.ReadJSONObject(v, func(k string, v []byte) error {
+					if len(t.OldMap) >= 4096 {
+						return fmt.Errorf("t.OldMap: %w", &cbg.ErrTooLong{Length: uint64(len(t.OldMap)) + 1, Limit: 4096})
+					}
+					var val SimpleTypeOne
+
+					if err := cbg.ReadJSON(v, &val); err != nil {
+						return err
+					}
+
+					t.OldMap[k] = val
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		// t.NewMap (map[string]testing.SimpleTypeOne) (map)
+		case "NewMap":
+
+			if !cbg.IsJSONNull(v) {
+				t.NewMap = make(map[string]SimpleTypeOne)
+				if err := cbg.ReadJSONObject(v, func(k string, v []byte) error {
+					if len(t.NewMap) >= 4096 {
+						return fmt.Errorf("t.NewMap: %w", &cbg.ErrTooLong{Length: uint64(len(t.NewMap)) + 1, Limit: 4096})
+					}
+					var val SimpleTypeOne
+
+					if err := cbg.ReadJSON(v, &val); err != nil {
+						return err
+					}
+
+					t.NewMap[k] = val
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		// t.OldArray ([]testing.SimpleTypeOne) (slice)
+		case "OldArray":
+
+			if err := cbg.ReadJSON(v, &t.OldArray); err != nil {
+				return err
+			}
+			if len(t.OldArray) > cbg.MaxLength {
+				return fmt.Errorf("t.OldArray: %w", &cbg.ErrTooLong{Length: uint64(len(t.OldArray)), Limit: cbg.MaxLength})
+			}
+		// t.NewArray ([]testing.SimpleTypeOne) (slice)
+		case "NewArray":
+
+			if err := cbg.ReadJSON(v, &t.NewArray); err != nil {
+				return err
+			}
+			if len(t.NewArray) > cbg.MaxLength {
+				return fmt.Errorf("t.NewArray: %w", &cbg.ErrTooLong{Length: uint64(len(t.NewArray)), Limit: cbg.MaxLength})
+			}
+		// t.OldStruct (testing.SimpleTypeOne) (struct)
+		case "OldStruct":
+
+			if err := cbg.ReadJSON(v, &t.OldStruct); err != nil {
+				return err
+			}
+		// t.NewStruct (testing.SimpleTypeOne) (struct)
+		case "NewStruct":
+
+			if err := cbg.ReadJSON(v, &t.NewStruct); err != nil {
+				return err
+			}
+
+		}
+		return nil
+	})
+}
+
+func (t *RenamedFields) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	cw := cbg.NewCborWriter(w)
+
+	if _, err := cw.Write([]byte{162}); err != nil {
+		return err
+	}
+
+	// t.Foo (int64) (int64)
+	if len("foo") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"foo\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("foo"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("foo")); err != nil {
+		return err
+	}
+
+	if t.Foo >= 0 {
+		if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.Foo)); err != nil {
+			return err
+		}
+	} else {
+		if err := cw.WriteMajorTypeHeader(cbg.MajNegativeInt, uint64(-t.Foo-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.Bar (string) (string)
+	if len("beep") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"beep\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("beep"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("beep")); err != nil {
+		return err
+	}
+
+	if len(t.Bar) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Bar was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Bar))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string(t.Bar)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RenamedFields) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = RenamedFields{}
+
+	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "RenamedFields", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajMap {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("RenamedFields: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+		pos = cbg.FieldPos{}
+
+		{
+			sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.Foo (int64) (int64)
+		case "foo":
+			pos = cbg.FieldPos{Field: "Foo"}
+			{
+				maj, extra, err := cr.ReadHeader()
+				var extraI int64
+				if err != nil {
+					return err
+				}
+				switch maj {
+				case cbg.MajUnsignedInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return &cbg.ErrIntOverflow{Type: "int64", Value: extra}
+					}
+				case cbg.MajNegativeInt:
+					extraI = int64(extra)
+					if extraI < 0 {
+						return &cbg.ErrIntOverflow{Type: "int64", Value: extra, Negative: true}
+					}
+					extraI = -1 - extraI
+				default:
+					return &cbg.ErrWrongMajorType{Want: cbg.MajUnsignedInt, Got: maj}
+				}
+
+				t.Foo = int64(extraI)
+			}
+			// t.Bar (string) (string)
+		case "beep":
+			pos = cbg.FieldPos{Field: "Bar"}
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
+				if err != nil {
+					return err
+				}
+
+				t.Bar = string(sval)
+			}
+
+		default:
+			// Field doesn't exist on this type, so ignore it
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+func (t *RenamedFields) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.Foo (int64) (int64)
+	n += cbg.CborHeaderSize(uint64(len("foo"))) + len("foo")
+
+	n += cbg.CborIntSize(int64(t.Foo))
+
+	// t.Bar (string) (string)
+	n += cbg.CborHeaderSize(uint64(len("beep"))) + len("beep")
+
+	n += cbg.CborHeaderSize(uint64(len(t.Bar))) + len(t.Bar)
+	return n
+}
+
+func (t *RenamedFields) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *RenamedFields) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+	return nil
+}
+
+func (t *RenamedFields) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Bar (string) (string)
+	b = append(b, "\"beep\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Bar); err != nil {
+		return nil, err
+	}
 
 	// t.Foo (int64) (int64)
-	if len("foo") > cbg.MaxLength {
-		return xerrors.Errorf("Value in field \"foo\" was too long")
+	b = append(b, ",\"foo\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Foo); err != nil {
+		return nil, err
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("foo"))); err != nil {
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *RenamedFields) UnmarshalJSON(b []byte) error {
+	*t = RenamedFields{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Foo (int64) (int64)
+		case "foo":
+
+			if err := cbg.ReadJSON(v, &t.Foo); err != nil {
+				return err
+			}
+		// t.Bar (string) (string)
+		case "beep":
+
+			if err := cbg.ReadJSON(v, &t.Bar); err != nil {
+				return err
+			}
+			if len(t.Bar) > cbg.MaxLength {
+				return fmt.Errorf("t.Bar: %w", &cbg.ErrTooLong{Length: uint64(len(t.Bar)), Limit: cbg.MaxLength})
+			}
+
+		}
+		return nil
+	})
+}
+
+func (t *LongMapLog) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := io.WriteString(w, string("foo")); err != nil {
+
+	cw := cbg.NewCborWriter(w)
+
+	if _, err := cw.Write([]byte{162}); err != nil {
 		return err
 	}
 
-	if t.Foo >= 0 {
-		if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, uint64(t.Foo)); err != nil {
-			return err
-		}
-	} else {
-		if err := cw.WriteMajorTypeHeader(cbg.MajNegativeInt, uint64(-t.Foo-1)); err != nil {
-			return err
-		}
+	// t.Name (string) (string)
+	if len("Name") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"Name\" was too long")
 	}
 
-	// t.Bar (string) (string)
-	if len("beep") > cbg.MaxLength {
-		return xerrors.Errorf("Value in field \"beep\" was too long")
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("Name"))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, string("Name")); err != nil {
+		return err
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("beep"))); err != nil {
+	if len(t.Name) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Name was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Name))); err != nil {
 		return err
 	}
-	if _, err := io.WriteString(w, string("beep")); err != nil {
+	if _, err := io.WriteString(w, string(t.Name)); err != nil {
 		return err
 	}
 
-	if len(t.Bar) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.Bar was too long")
+	// t.Links ([]cid.Cid) (slice)
+	if len("links") > cbg.MaxLength {
+		return xerrors.Errorf("Value in field \"links\" was too long")
 	}
 
-	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Bar))); err != nil {
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("links"))); err != nil {
 		return err
 	}
-	if _, err := io.WriteString(w, string(t.Bar)); err != nil {
+	if _, err := io.WriteString(w, string("links")); err != nil {
+		return err
+	}
+
+	if len(t.Links) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Links was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.Links))); err != nil {
 		return err
 	}
+	for _, v := range t.Links {
+		if err := cbg.WriteCid(w, v); err != nil {
+			return xerrors.Errorf("failed writing cid field t.Links: %w", err)
+		}
+	}
 	return nil
 }
 
-func (t *RenamedFields) UnmarshalCBOR(r io.Reader) (err error) {
-	*t = RenamedFields{}
+func (t *LongMapLog) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = LongMapLog{}
 
 	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "LongMapLog", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
 
 	maj, extra, err := cr.ReadHeader()
 	if err != nil {
@@ -1691,20 +3328,21 @@ func (t *RenamedFields) UnmarshalCBOR(r io.Reader) (err error) {
 	}()
 
 	if maj != cbg.MajMap {
-		return fmt.Errorf("cbor input should be of type map")
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
 	}
 
 	if extra > cbg.MaxLength {
-		return fmt.Errorf("RenamedFields: map struct too large (%d)", extra)
+		return fmt.Errorf("LongMapLog: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
 	}
 
 	var name string
 	n := extra
 
 	for i := uint64(0); i < n; i++ {
+		pos = cbg.FieldPos{}
 
 		{
-			sval, err := cbg.ReadString(cr)
+			sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 			if err != nil {
 				return err
 			}
@@ -1713,49 +3351,282 @@ func (t *RenamedFields) UnmarshalCBOR(r io.Reader) (err error) {
 		}
 
 		switch name {
-		// t.Foo (int64) (int64)
-		case "foo":
+		// t.Name (string) (string)
+		case "Name":
+			pos = cbg.FieldPos{Field: "Name"}
+
 			{
-				maj, extra, err := cr.ReadHeader()
-				var extraI int64
+				sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 				if err != nil {
 					return err
 				}
-				switch maj {
-				case cbg.MajUnsignedInt:
-					extraI = int64(extra)
-					if extraI < 0 {
-						return fmt.Errorf("int64 positive overflow")
-					}
-				case cbg.MajNegativeInt:
-					extraI = int64(extra)
-					if extraI < 0 {
-						return fmt.Errorf("int64 negative oveflow")
-					}
-					extraI = -1 - extraI
-				default:
-					return fmt.Errorf("wrong type for int64 field: %d", maj)
+
+				t.Name = string(sval)
+			}
+			// t.Links ([]cid.Cid) (slice)
+		case "links":
+			pos = cbg.FieldPos{Field: "Links"}
+
+			maj, extra, err = cr.ReadHeader()
+			if err != nil {
+				return err
+			}
+
+			if extra > cbg.MaxLength {
+				return fmt.Errorf("t.Links: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
+			}
+
+			if maj != cbg.MajArray {
+				return &cbg.ErrWrongMajorType{Want: cbg.MajArray, Got: maj}
+			}
+
+			if extra > 0 {
+				if err := cr.Allocate(extra * 16); err != nil {
+					return err
 				}
+				t.Links = make([]cid.Cid, extra)
+			}
 
-				t.Foo = int64(extraI)
+			for i := 0; i < int(extra); i++ {
+				pos.Index[0] = i
+				pos.Depth = 1
+
+				c, err := cbg.ReadCid(cr)
+				if err != nil {
+					return xerrors.Errorf("reading cid field t.Links failed: %w", err)
+				}
+				t.Links[i] = c
 			}
-			// t.Bar (string) (string)
-		case "beep":
+
+		default:
+			// Field doesn't exist on this type, so ignore it
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ForEachLinks decodes t from r like UnmarshalCBOR, but instead of
+// collecting the elements of Links it calls fn with each of them in
+// turn, leaving the field empty. The number of elements is not limited.
+func (t *LongMapLog) ForEachLinks(r io.Reader, fn func(cid.Cid) error) (err error) {
+	*t = LongMapLog{}
+
+	cr := cbg.NewCborReader(r)
+	var pos cbg.FieldPos
+	defer func() {
+		if err != nil {
+			err = cbg.WrapDecodeError(err, "LongMapLog", pos, cr)
+		}
+	}()
+	if err := cr.EnterNested(); err != nil {
+		return err
+	}
+	defer cr.LeaveNested()
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajMap {
+		return &cbg.ErrWrongMajorType{Want: cbg.MajMap, Got: maj}
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("LongMapLog: %w", &cbg.ErrTooLong{Length: extra, Limit: cbg.MaxLength})
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+		pos = cbg.FieldPos{}
+
+		{
+			sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.Name (string) (string)
+		case "Name":
+			pos = cbg.FieldPos{Field: "Name"}
 
 			{
-				sval, err := cbg.ReadString(cr)
+				sval, err := cbg.ReadStringWithMax(cr, cbg.MaxLength)
 				if err != nil {
 					return err
 				}
 
-				t.Bar = string(sval)
+				t.Name = string(sval)
+			}
+			// t.Links ([]cid.Cid) (slice)
+		case "links":
+			pos = cbg.FieldPos{Field: "Links"}
+
+			{
+				length, err := cr.ReadArrayHeader()
+				if err != nil {
+					return err
+				}
+
+				for i := uint64(0); i < length; i++ {
+					var v cid.Cid
+					pos.Index[0] = int(i)
+					pos.Depth = 1
+
+					{
+
+						c, err := cbg.ReadCid(cr)
+						if err != nil {
+							return xerrors.Errorf("failed to read cid field v: %w", err)
+						}
+
+						v = c
+
+					}
+
+					if err := fn(v); err != nil {
+						return err
+					}
+				}
 			}
 
 		default:
 			// Field doesn't exist on this type, so ignore it
-			cbg.ScanForLinks(r, func(cid.Cid) {})
+			if err := cbg.ScanForLinks(cr, func(cid.Cid) {}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+func (t *LongMapLog) CBORSize() int {
+	if t == nil {
+		return len(cbg.CborNull)
+	}
+
+	n := 1
+
+	// t.Name (string) (string)
+	n += cbg.CborHeaderSize(uint64(len("Name"))) + len("Name")
+
+	n += cbg.CborHeaderSize(uint64(len(t.Name))) + len(t.Name)
+
+	// t.Links ([]cid.Cid) (slice)
+	n += cbg.CborHeaderSize(uint64(len("links"))) + len("links")
+
+	n += cbg.CborHeaderSize(uint64(len(t.Links)))
+	for _, v := range t.Links {
+
+		n += cbg.CidSize(v)
+
+	}
+	return n
+}
+
+func (t *LongMapLog) ForEachLink(fn func(cid.Cid) error) error {
+	if t == nil {
+		return nil
+	}
+
+	// t.Links ([]cid.Cid) (slice)
+	for i := range t.Links {
+
+		if t.Links[i].Defined() {
+			if err := fn(t.Links[i]); err != nil {
+				return err
+			}
 		}
+
+	}
+	return nil
+}
+
+func (t *LongMapLog) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	if t == nil {
+		return nil
 	}
 
+	// t.Links ([]cid.Cid) (slice)
+	for i := range t.Links {
+
+		if t.Links[i].Defined() {
+			if err := fn(cbg.JoinPathIndex(cbg.JoinPath(prefix, "links"), i), t.Links[i]); err != nil {
+				return err
+			}
+		}
+
+	}
 	return nil
 }
+
+func (t *LongMapLog) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("null"), nil
+	}
+
+	var err error
+	_ = err
+	b := []byte{'{'}
+
+	// t.Name (string) (string)
+	b = append(b, "\"Name\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Name); err != nil {
+		return nil, err
+	}
+
+	// t.Links ([]cid.Cid) (slice)
+	b = append(b, ",\"links\":"...)
+
+	if b, err = cbg.AppendJSON(b, &t.Links); err != nil {
+		return nil, err
+	}
+
+	b = append(b, '}')
+	return b, nil
+}
+
+func (t *LongMapLog) UnmarshalJSON(b []byte) error {
+	*t = LongMapLog{}
+
+	return cbg.ReadJSONObject(b, func(key string, v []byte) error {
+		switch key {
+		// t.Name (string) (string)
+		case "Name":
+
+			if err := cbg.ReadJSON(v, &t.Name); err != nil {
+				return err
+			}
+			if len(t.Name) > cbg.MaxLength {
+				return fmt.Errorf("t.Name: %w", &cbg.ErrTooLong{Length: uint64(len(t.Name)), Limit: cbg.MaxLength})
+			}
+		// t.Links ([]cid.Cid) (slice)
+		case "links":
+
+			if err := cbg.ReadJSON(v, &t.Links); err != nil {
+				return err
+			}
+			if len(t.Links) > cbg.MaxLength {
+				return fmt.Errorf("t.Links: %w", &cbg.ErrTooLong{Length: uint64(len(t.Links)), Limit: cbg.MaxLength})
+			}
+
+		}
+		return nil
+	})
+}
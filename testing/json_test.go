@@ -0,0 +1,174 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/ipfs/go-cid"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(56887))
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf(SignedArray{}),
+		reflect.TypeOf(SimpleTypeOne{}),
+		reflect.TypeOf(SimpleTypeTwo{}),
+		reflect.TypeOf(FixedArrays{}),
+		reflect.TypeOf(SimpleTypeTree{}),
+		reflect.TypeOf(NeedScratchForMap{}),
+		reflect.TypeOf(RenamedFields{}),
+	} {
+		for i := 0; i < 100; i++ {
+			val, ok := quick.Value(typ, r)
+			if !ok {
+				t.Fatal("failed to generate test value")
+			}
+			obj := val.Addr().Interface().(cbg.CBORMarshaler)
+
+			js, err := json.Marshal(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nobj := reflect.New(typ).Interface()
+			if err := json.Unmarshal(js, nobj); err != nil {
+				t.Fatalf("%T: %s: %s", obj, err, js)
+			}
+
+			// The CBOR encodings are compared because it doesn't tell nil
+			// and empty slices apart.
+			var want, got bytes.Buffer
+			if err := obj.MarshalCBOR(&want); err != nil {
+				t.Fatal(err)
+			}
+			if err := nobj.(cbg.CBORMarshaler).MarshalCBOR(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(want.Bytes(), got.Bytes()) {
+				t.Fatalf("%T: value changed going through %s", obj, js)
+			}
+		}
+	}
+}
+
+func TestJSONMatchesDagJSON(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	for _, obj := range []cbg.CBORMarshaler{
+		&RenamedFields{Foo: -5, Bar: "bar"},
+		&LongMapLog{Name: "log", Links: []cid.Cid{c, c}},
+		&NeedScratchForMap{Thing: true},
+	} {
+		var buf bytes.Buffer
+		if err := obj.MarshalCBOR(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var want bytes.Buffer
+		if err := cbg.CBORToDagJSON(&buf, &want); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := json.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want.String() {
+			t.Fatalf("%T: expected %s, got %s", obj, want.String(), got)
+		}
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	obj := &SimpleStructV1{
+		OldStr:   "str",
+		OldBytes: []byte{1, 2, 0xff},
+		OldPtr:   &c,
+		OldMap:   map[string]SimpleTypeOne{"b": {Foo: "b"}, "a": {Foo: "a"}},
+	}
+	js, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"OldArray":null,"OldBytes":{"/":{"bytes":"AQL/"}},` +
+		`"OldMap":{"a":{"Binary":{"/":{"bytes":""}},"Foo":"a","NString":"","Signed":0,"Value":0},` +
+		`"b":{"Binary":{"/":{"bytes":""}},"Foo":"b","NString":"","Signed":0,"Value":0}},` +
+		`"OldNum":0,"OldPtr":{"/":"bafkqaaa"},"OldStr":"str",` +
+		`"OldStruct":{"Binary":{"/":{"bytes":""}},"Foo":"","NString":"","Signed":0,"Value":0}}`
+	if string(js) != want {
+		t.Fatalf("expected %s, got %s", want, js)
+	}
+
+	var out SimpleStructV1
+	if err := json.Unmarshal(js, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.OldBytes, obj.OldBytes) || !out.OldPtr.Equals(c) || out.OldMap["b"].Foo != "b" {
+		t.Fatalf("value changed going through JSON: %#v", out)
+	}
+
+	// Unknown fields are skipped, as they are in CBOR.
+	if err := json.Unmarshal([]byte(`{"OldStr":"a","Unknown":[1]}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.OldStr != "a" || out.OldPtr != nil {
+		t.Fatalf("expected the value to be reset, got %#v", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"OldBytes":"AQL/"}`), &out); err == nil {
+		t.Fatal("expected bytes not in DAG-JSON form to be rejected")
+	}
+}
+
+func TestJSONLengthLimits(t *testing.T) {
+	var tooLong *cbg.ErrTooLong
+	var wrongLength *cbg.ErrWrongLength
+
+	binary := base64.RawStdEncoding.EncodeToString(make([]byte, cbg.ByteArrayMaxLen+1))
+	var one SimpleTypeOne
+	err := json.Unmarshal([]byte(`{"Binary":{"/":{"bytes":"`+binary+`"}}}`), &one)
+	if !errors.As(err, &tooLong) || tooLong.Limit != cbg.ByteArrayMaxLen {
+		t.Fatalf("expected ErrTooLong for Binary, got %v", err)
+	}
+
+	foo, _ := json.Marshal(strings.Repeat("a", cbg.MaxLength+1))
+	err = json.Unmarshal([]byte(`{"Foo":`+string(foo)+`}`), &one)
+	if !errors.As(err, &tooLong) || tooLong.Limit != cbg.MaxLength {
+		t.Fatalf("expected ErrTooLong for Foo, got %v", err)
+	}
+
+	var fixed FixedArrays
+	err = json.Unmarshal([]byte(`{"Bytes":{"/":{"bytes":"AQI"}}}`), &fixed)
+	if !errors.As(err, &wrongLength) || wrongLength.Want != 20 || wrongLength.Got != 2 {
+		t.Fatalf("expected ErrWrongLength for Bytes, got %v", err)
+	}
+}
+
+func TestJSONDeferred(t *testing.T) {
+	obj := &DeferredContainer{
+		Deferred: &cbg.Deferred{Raw: cbg.MustParseDiagnostic(`{"a": h'01'}`)},
+	}
+	js, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Deferred":{"a":{"/":{"bytes":"AQ"}}},"Stuff":null,"Value":0}`
+	if string(js) != want {
+		t.Fatalf("expected %s, got %s", want, js)
+	}
+
+	var out DeferredContainer
+	if err := json.Unmarshal(js, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Deferred.Raw, obj.Deferred.Raw) {
+		t.Fatalf("expected %x, got %x", obj.Deferred.Raw, out.Deferred.Raw)
+	}
+}
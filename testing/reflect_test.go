@@ -0,0 +1,231 @@
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/ipfs/go-cid"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// The plain types have the same fields as the generated ones but no methods,
+// so that cbg.Marshal and cbg.Unmarshal encode them with reflection.
+type (
+	plainSignedArray       SignedArray
+	plainSimpleTypeOne     SimpleTypeOne
+	plainSimpleTypeTwo     SimpleTypeTwo
+	plainDeferredContainer DeferredContainer
+	plainFixedArrays       FixedArrays
+	plainThingWithSomeTime ThingWithSomeTime
+	plainBigField          BigField
+	plainLongString        LongString
+	plainLongLog           LongLog
+	plainSimpleTypeTree    SimpleTypeTree
+	plainNeedScratchForMap NeedScratchForMap
+	plainSimpleStructV1    SimpleStructV1
+	plainSimpleStructV2    SimpleStructV2
+	plainRenamedFields     RenamedFields
+	plainLongMapLog        LongMapLog
+)
+
+// nestedTypeTwo is SimpleTypeTwo with nested values that have no methods
+// either.
+type nestedTypeTwo struct {
+	Stuff        *nestedTypeTwo
+	Others       []uint64
+	SignedOthers []int64
+	Test         [][]byte
+	Dog          string
+	Numbers      []NamedNumber
+	Pizza        *uint64
+	PointyPizza  *NamedNumber
+	Arrrrrghay   [Thingc]plainSimpleTypeOne
+}
+
+// testReflectMatches checks that obj, converted to plain, is marshaled and
+// unmarshaled with reflection exactly like with the generated code.
+func testReflectMatches(t *testing.T, obj cbg.CBORMarshaler, plain reflect.Type, mapRepr bool) {
+	marshal, unmarshal := cbg.Marshal, cbg.Unmarshal
+	if mapRepr {
+		marshal, unmarshal = cbg.MarshalMap, cbg.UnmarshalMap
+	}
+
+	buf := new(bytes.Buffer)
+	if err := obj.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	pv := reflect.ValueOf(obj).Elem().Convert(plain)
+	enc, err := marshal(pv.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, buf.Bytes()) {
+		t.Fatalf("%T: reflection and generated code differ: %x != %x", obj, enc, buf.Bytes())
+	}
+
+	out := reflect.New(plain)
+	if err := unmarshal(enc, out.Interface()); err != nil {
+		t.Fatalf("%T: %s", obj, err)
+	}
+	reenc, err := marshal(out.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reenc, enc) {
+		t.Fatalf("%T: remarshaled encoding differs: %x != %x", obj, reenc, enc)
+	}
+}
+
+func TestReflectMatchesGenerated(t *testing.T) {
+	r := rand.New(rand.NewSource(56887))
+	for _, tc := range []struct {
+		typ     reflect.Type
+		plain   reflect.Type
+		mapRepr bool
+	}{
+		{reflect.TypeOf(SignedArray{}), reflect.TypeOf(plainSignedArray{}), false},
+		{reflect.TypeOf(SimpleTypeOne{}), reflect.TypeOf(plainSimpleTypeOne{}), false},
+		{reflect.TypeOf(SimpleTypeTwo{}), reflect.TypeOf(plainSimpleTypeTwo{}), false},
+		{reflect.TypeOf(FixedArrays{}), reflect.TypeOf(plainFixedArrays{}), false},
+		{reflect.TypeOf(SimpleTypeTree{}), reflect.TypeOf(plainSimpleTypeTree{}), true},
+		{reflect.TypeOf(NeedScratchForMap{}), reflect.TypeOf(plainNeedScratchForMap{}), true},
+		{reflect.TypeOf(RenamedFields{}), reflect.TypeOf(plainRenamedFields{}), true},
+	} {
+		for i := 0; i < 100; i++ {
+			val, ok := quick.Value(tc.typ, r)
+			if !ok {
+				t.Fatal("failed to generate test value")
+			}
+			testReflectMatches(t, val.Addr().Interface().(cbg.CBORMarshaler), tc.plain, tc.mapRepr)
+		}
+	}
+
+	// quick can't generate CIDs, and generates nil elements in slices of
+	// pointers, which the generated decoders don't accept.
+	c, _ := cid.Parse("bafkqaaa")
+	one := SimpleTypeOne{Foo: "foo", Binary: []byte{1}}
+	testReflectMatches(t, &LongLog{Name: "log", Entries: []*SimpleTypeOne{&one}, Heights: []uint64{1, 2}}, reflect.TypeOf(plainLongLog{}), false)
+	testReflectMatches(t, &SimpleStructV1{OldPtr: &c, OldMap: map[string]SimpleTypeOne{"b": one, "a": {}}}, reflect.TypeOf(plainSimpleStructV1{}), true)
+	testReflectMatches(t, &SimpleStructV2{NewPtr: &c, NewArray: []SimpleTypeOne{one}}, reflect.TypeOf(plainSimpleStructV2{}), true)
+	testReflectMatches(t, &LongMapLog{Name: "log", Links: []cid.Cid{c, c}}, reflect.TypeOf(plainLongMapLog{}), true)
+	testReflectMatches(t, &DeferredContainer{Stuff: &one, Deferred: &cbg.Deferred{Raw: []byte{0xf6}}}, reflect.TypeOf(plainDeferredContainer{}), false)
+	testReflectMatches(t, &ThingWithSomeTime{When: cbg.CborTime(time.Unix(1, 0)), Stuff: -5}, reflect.TypeOf(plainThingWithSomeTime{}), false)
+	testReflectMatches(t, &BigField{LargeBytes: make([]byte, 70000)}, reflect.TypeOf(plainBigField{}), false)
+	testReflectMatches(t, &LongString{Text: string(make([]byte, 10000))}, reflect.TypeOf(plainLongString{}), false)
+}
+
+func TestReflectNested(t *testing.T) {
+	r := rand.New(rand.NewSource(56887))
+	for i := 0; i < 100; i++ {
+		val, ok := quick.Value(reflect.TypeOf(SimpleTypeTwo{}), r)
+		if !ok {
+			t.Fatal("failed to generate test value")
+		}
+		obj := val.Addr().Interface().(*SimpleTypeTwo)
+
+		buf := new(bytes.Buffer)
+		if err := obj.MarshalCBOR(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var nested nestedTypeTwo
+		if err := cbg.Unmarshal(buf.Bytes(), &nested); err != nil {
+			t.Fatal(err)
+		}
+		enc, err := cbg.Marshal(&nested)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(enc, buf.Bytes()) {
+			t.Fatalf("reflection and generated code differ: %x != %x", enc, buf.Bytes())
+		}
+	}
+}
+
+func TestReflectMethods(t *testing.T) {
+	// Types with CBOR methods are encoded with them.
+	obj := &SimpleTypeOne{Foo: "foo"}
+	buf := new(bytes.Buffer)
+	if err := obj.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := cbg.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, buf.Bytes()) {
+		t.Fatalf("expected the MarshalCBOR encoding %x, got %x", buf.Bytes(), enc)
+	}
+
+	var out SimpleTypeOne
+	if err := cbg.Unmarshal(enc, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Foo != "foo" {
+		t.Fatalf("expected Foo to be decoded, got %#v", out)
+	}
+}
+
+func TestReflectErrors(t *testing.T) {
+	enc, err := cbg.Marshal(plainSimpleTypeOne{Foo: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out plainSimpleTypeOne
+	if err := cbg.Unmarshal(append(enc, 0), &out); err == nil {
+		t.Fatal("expected trailing data to be rejected")
+	}
+	if err := cbg.Unmarshal(enc, out); err == nil {
+		t.Fatal("expected a non-pointer to be rejected")
+	}
+
+	enc, err = cbg.MarshalMap(plainRenamedFields{Foo: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree plainSimpleTypeTree
+	if err := cbg.UnmarshalMap(enc, &tree); err != nil {
+		t.Fatalf("expected unknown fields to be skipped, got %v", err)
+	}
+	if err := (cbg.GenOptions{Strict: true}).UnmarshalMap(enc, &tree); err == nil {
+		t.Fatal("expected unknown fields to be rejected in strict mode")
+	}
+
+	type unsupported struct {
+		F float64
+	}
+	if _, err := cbg.Marshal(unsupported{}); err == nil {
+		t.Fatal("expected a type no code can be generated for to be rejected")
+	}
+}
+
+func TestReflectMaxLength(t *testing.T) {
+	// Map keys and field names follow GenOptions.MaxLength, as in generated
+	// decoders.
+	long := string(bytes.Repeat([]byte{'k'}, 9000))
+	opts := cbg.GenOptions{MaxLength: 10000}
+	withKey, err := opts.MarshalMap(plainSimpleStructV1{OldMap: map[string]SimpleTypeOne{long: {}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withField := cbg.AppendString(cbg.CborEncodeMajorType(cbg.MajMap, 1), long)
+	withField = append(withField, 0x01)
+
+	for _, enc := range [][]byte{withKey, withField} {
+		var out plainSimpleStructV1
+		if err := opts.UnmarshalMap(enc, &out); err != nil {
+			t.Fatal(err)
+		}
+		var tooLong *cbg.ErrTooLong
+		if err := cbg.UnmarshalMap(enc, &out); !errors.As(err, &tooLong) || tooLong.Limit != cbg.MaxLength {
+			t.Fatalf("expected ErrTooLong, got %v", err)
+		}
+	}
+}
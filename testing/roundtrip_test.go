@@ -55,6 +55,10 @@ func testValueRoundtrip(t *testing.T, obj cbg.CBORMarshaler, nobj cbg.CBORUnmars
 
 	enc := buf.Bytes()
 
+	if sizer, ok := obj.(cbg.CBORSizer); ok && sizer.CBORSize() != len(enc) {
+		t.Fatalf("CBORSize returned %d, but the encoding is %d bytes", sizer.CBORSize(), len(enc))
+	}
+
 	if err := nobj.UnmarshalCBOR(bytes.NewReader(enc)); err != nil {
 		t.Logf("got bad bytes: %x", enc)
 		t.Fatal("failed to round trip object: ", err)
@@ -65,6 +69,20 @@ func testValueRoundtrip(t *testing.T, obj cbg.CBORMarshaler, nobj cbg.CBORUnmars
 		t.Log("not equal after round trip!")
 	}
 
+	if _, ok := nobj.(bytesUnmarshaler); ok {
+		bobj := reflect.New(reflect.TypeOf(nobj).Elem()).Interface().(bytesUnmarshaler)
+		rest, err := bobj.UnmarshalCBORBytes(enc)
+		if err != nil {
+			t.Fatal("failed to round trip object with UnmarshalCBORBytes: ", err)
+		}
+		if len(rest) != 0 {
+			t.Fatalf("UnmarshalCBORBytes left %d bytes unread", len(rest))
+		}
+		if !cmp.Equal(nobj, bobj, alwaysEqualOpt) {
+			t.Fatalf("UnmarshalCBOR and UnmarshalCBORBytes differ: %#v != %#v", nobj, bobj)
+		}
+	}
+
 	nbuf := new(bytes.Buffer)
 	if err := nobj.(cbg.CBORMarshaler).MarshalCBOR(nbuf); err != nil {
 		t.Fatal("failed to remarshal object: ", err)
@@ -89,6 +107,537 @@ func testTypeRoundtrips(t *testing.T, typ reflect.Type) {
 	}
 }
 
+func TestAppendCBOR(t *testing.T) {
+	r := rand.New(rand.NewSource(56887))
+	for i := 0; i < 100; i++ {
+		val, ok := quick.Value(reflect.TypeOf(SimpleTypeTwo{}), r)
+		if !ok {
+			t.Fatal("failed to generate test value")
+		}
+		obj := val.Addr().Interface().(*SimpleTypeTwo)
+
+		buf := new(bytes.Buffer)
+		if err := obj.MarshalCBOR(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		prefix := []byte{0xde, 0xad}
+		out, err := obj.AppendCBOR(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out[:2], prefix) {
+			t.Fatal("AppendCBOR overwrote the start of the slice")
+		}
+		if !bytes.Equal(out[2:], buf.Bytes()) {
+			t.Fatalf("AppendCBOR and MarshalCBOR differ: %x != %x", out[2:], buf.Bytes())
+		}
+	}
+
+	// Map encoded types are only marshaled through io.Writer, but must give
+	// the same bytes when nested in a type with an AppendCBOR method.
+	tree := &SimpleTypeTree{Stufff: &SimpleTypeTwo{Dog: "dog"}}
+	buf := new(bytes.Buffer)
+	if err := tree.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cbg.AppendMarshaler(nil, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, buf.Bytes()) {
+		t.Fatalf("AppendMarshaler and MarshalCBOR differ: %x != %x", out, buf.Bytes())
+	}
+}
+
+func TestCBORSize(t *testing.T) {
+	dummyCid, _ := cid.Parse("bafkqaaa")
+	for _, obj := range []cbg.CBORMarshaler{
+		&SimpleStructV1{OldPtr: &dummyCid, OldMap: map[string]SimpleTypeOne{"a": {Foo: "foo"}}},
+		&DeferredContainer{Deferred: &cbg.Deferred{Raw: []byte{0xf6}}},
+		&ThingWithSomeTime{When: cbg.CborTime(time.Now())},
+		&BigField{LargeBytes: make([]byte, 70000)},
+		(*SimpleTypeOne)(nil),
+	} {
+		buf := new(bytes.Buffer)
+		if err := obj.MarshalCBOR(buf); err != nil {
+			t.Fatal(err)
+		}
+		if size := cbg.SizeOf(obj); size != buf.Len() {
+			t.Fatalf("%T: CBORSize returned %d, but the encoding is %d bytes", obj, size, buf.Len())
+		}
+	}
+}
+
+func TestLongString(t *testing.T) {
+	// The field's maxlen is above cbg.MaxLength, which limits other strings.
+	obj := &LongString{Text: string(bytes.Repeat([]byte{'a'}, 10000))}
+	buf := new(bytes.Buffer)
+	if err := obj.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+
+	var out LongString
+	if err := out.UnmarshalCBOR(bytes.NewReader(enc)); err != nil {
+		t.Fatal(err)
+	}
+	if out.Text != obj.Text {
+		t.Fatal("the string did not round trip")
+	}
+	if _, err := out.UnmarshalCBORBytes(enc); err != nil || out.Text != obj.Text {
+		t.Fatalf("the string did not round trip from bytes: %v", err)
+	}
+
+	long := append([]byte{0x81}, cbg.CborEncodeMajorType(cbg.MajTextString, 20001)...)
+	long = append(long, make([]byte, 20001)...)
+	var tooLong *cbg.ErrTooLong
+	if err := out.UnmarshalCBOR(bytes.NewReader(long)); !errors.As(err, &tooLong) || tooLong.Limit != 20000 {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+}
+
+type bytesUnmarshaler interface {
+	cbg.CBORUnmarshaler
+	UnmarshalCBORBytes([]byte) ([]byte, error)
+}
+
+func TestUnmarshalCBORBytes(t *testing.T) {
+	one := &SimpleTypeOne{Foo: "foo", Binary: []byte("binary")}
+	two := &SimpleTypeOne{Foo: "bar", Value: 7}
+
+	buf := new(bytes.Buffer)
+	if err := one.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := two.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+
+	var got SimpleTypeOne
+	rest, err := got.UnmarshalCBORBytes(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(one, &got) {
+		t.Fatalf("%#v != %#v", one, &got)
+	}
+
+	// Byte fields alias the input.
+	enc[bytes.Index(enc, []byte("binary"))] = 'B'
+	if string(got.Binary) != "Binary" {
+		t.Fatalf("expected Binary to alias the input, got %q", got.Binary)
+	}
+	if cap(got.Binary) != len(got.Binary) {
+		t.Fatal("appending to a decoded field could overwrite the input")
+	}
+
+	var next SimpleTypeOne
+	rest, err = next.UnmarshalCBORBytes(rest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(two, &next, alwaysEqualOpt) {
+		t.Fatalf("%#v != %#v", two, &next)
+	}
+	if len(rest) != 0 {
+		t.Fatalf("expected no input left, got %x", rest)
+	}
+
+	if _, err := next.UnmarshalCBORBytes(enc[:len(enc)/2]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF for truncated input, got %v", err)
+	}
+}
+
+func TestUnmarshalCBORBytesAllocs(t *testing.T) {
+	obj := &BigField{LargeBytes: make([]byte, 1<<16)}
+	buf := new(bytes.Buffer)
+	if err := obj.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+
+	var out BigField
+	copying := testing.AllocsPerRun(10, func() {
+		if err := out.UnmarshalCBOR(bytes.NewReader(enc)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	zeroCopy := testing.AllocsPerRun(10, func() {
+		if _, err := out.UnmarshalCBORBytes(enc); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if zeroCopy >= copying {
+		t.Fatalf("expected fewer allocations decoding from bytes: %v >= %v", zeroCopy, copying)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	log := &LongLog{Name: "log", Heights: []uint64{1, 2, 3}}
+	for i := 0; i < cbg.MaxLength+10; i++ {
+		if i%100 == 0 {
+			log.Entries = append(log.Entries, nil)
+			continue
+		}
+		log.Entries = append(log.Entries, &SimpleTypeOne{Value: uint64(i)})
+	}
+
+	// MarshalCBOR won't write more than MaxLength entries, so encode the log
+	// by hand.
+	buf := new(bytes.Buffer)
+	cw := cbg.NewCborWriter(buf)
+	if err := cw.WriteMajorTypeHeader(cbg.MajArray, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(log.Name))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cw.WriteString(log.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(log.Entries))); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range log.Entries {
+		if err := e.MarshalCBOR(cw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(log.Heights))); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range log.Heights {
+		if err := cw.WriteMajorTypeHeader(cbg.MajUnsignedInt, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enc := buf.Bytes()
+
+	var out LongLog
+	if err := out.UnmarshalCBOR(bytes.NewReader(enc)); err == nil {
+		t.Fatal("expected UnmarshalCBOR to reject the long array")
+	}
+
+	var entries []*SimpleTypeOne
+	if err := out.ForEachEntries(bytes.NewReader(enc), func(e *SimpleTypeOne) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(log.Entries, entries) {
+		t.Fatal("streamed entries differ from the encoded ones")
+	}
+	if out.Entries != nil {
+		t.Fatal("expected the streamed field to be left empty")
+	}
+	if out.Name != log.Name || !cmp.Equal(out.Heights, log.Heights) {
+		t.Fatalf("other fields not decoded: %#v", out)
+	}
+
+	stop := errors.New("stop")
+	var seen int
+	if err := out.ForEachEntries(bytes.NewReader(enc), func(*SimpleTypeOne) error {
+		seen++
+		return stop
+	}); !errors.Is(err, stop) || seen != 1 {
+		t.Fatalf("expected the callback error after one entry, got %v after %d", err, seen)
+	}
+
+	if err := out.ForEachHeights(bytes.NewReader(enc), func(uint64) error {
+		return nil
+	}); err == nil {
+		t.Fatal("expected an error for the long array in the other field")
+	}
+}
+
+func TestForEachMap(t *testing.T) {
+	c1, _ := cid.Parse("bafkqaaa")
+	c2, _ := cid.Parse("bafkqaab")
+	log := &LongMapLog{Name: "log", Links: []cid.Cid{c1, c2, c1}}
+
+	buf := new(bytes.Buffer)
+	if err := log.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out LongMapLog
+	var links []cid.Cid
+	if err := out.ForEachLinks(bytes.NewReader(buf.Bytes()), func(c cid.Cid) error {
+		links = append(links, c)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(log.Links, links) || out.Name != log.Name {
+		t.Fatalf("expected %v and %q, got %v and %q", log.Links, log.Name, links, out.Name)
+	}
+}
+
+func TestForEachLink(t *testing.T) {
+	c1, _ := cid.Parse("bafkqaaa")
+	c2, _ := cid.Parse("bafkqaab")
+
+	deferred := new(bytes.Buffer)
+	if err := (&LongMapLog{Links: []cid.Cid{c2}}).MarshalCBOR(deferred); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		obj   cbg.CBORMarshaler
+		paths []string
+	}{{
+		obj: &SimpleStructV1{
+			OldPtr:    &c1,
+			OldMap:    map[string]SimpleTypeOne{"a": {}},
+			OldArray:  []SimpleTypeOne{{}},
+			OldStruct: SimpleTypeOne{},
+		},
+		paths: []string{"root/OldPtr"},
+	}, {
+		obj:   &LongMapLog{Links: []cid.Cid{c1, c2}},
+		paths: []string{"root/links/0", "root/links/1"},
+	}, {
+		obj:   &DeferredContainer{Stuff: &SimpleTypeOne{}, Deferred: &cbg.Deferred{Raw: deferred.Bytes()}},
+		paths: []string{"root/Deferred"},
+	}, {
+		obj:   &SimpleTypeTwo{Stuff: &SimpleTypeTwo{}},
+		paths: nil,
+	}} {
+		buf := new(bytes.Buffer)
+		if err := tc.obj.MarshalCBOR(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var scanned []cid.Cid
+		if err := cbg.ScanForLinks(buf, func(c cid.Cid) {
+			scanned = append(scanned, c)
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		var walked []cid.Cid
+		if err := tc.obj.(cbg.LinkWalker).ForEachLink(func(c cid.Cid) error {
+			walked = append(walked, c)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(walked, scanned) {
+			t.Fatalf("%T: walked %v, but scanned %v", tc.obj, walked, scanned)
+		}
+
+		var paths []string
+		if err := tc.obj.(cbg.LinkPathWalker).ForEachLinkPath("root", func(path string, c cid.Cid) error {
+			paths = append(paths, path)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(paths, tc.paths) {
+			t.Fatalf("%T: expected paths %v, got %v", tc.obj, tc.paths, paths)
+		}
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	// Byte strings alias the input when decoding from bytes, but strings are
+	// always allocated.
+	obj := &SimpleTypeTwo{Dog: string(make([]byte, 1000))}
+	for i := 0; i < 9; i++ {
+		obj = &SimpleTypeTwo{Stuff: obj}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := obj.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+
+	for _, tc := range []struct {
+		opts cbg.DecodeOptions
+		err  error
+	}{
+		// The innermost value nests SimpleTypeOne at depth 11.
+		{cbg.DecodeOptions{MaxDepth: 11, MaxBytes: int64(len(enc)), MaxAlloc: 2000}, nil},
+		{cbg.DecodeOptions{MaxDepth: 10}, cbg.ErrDecodeTooDeep},
+		{cbg.DecodeOptions{MaxBytes: int64(len(enc)) - 1}, cbg.ErrDecodeReadLimit},
+		{cbg.DecodeOptions{MaxAlloc: 999}, cbg.ErrDecodeAllocLimit},
+	} {
+		for _, cr := range []*cbg.CborReader{
+			cbg.NewCborReaderWithOptions(bytes.NewReader(enc), tc.opts),
+			cbg.NewCborReaderBytesWithOptions(enc, tc.opts),
+		} {
+			var out SimpleTypeTwo
+			err := out.UnmarshalCBOR(cr)
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%+v: expected %v, got %v", tc.opts, tc.err, err)
+			}
+		}
+	}
+}
+
+func TestDecodeOptionsDeferred(t *testing.T) {
+	// Deferred copies what it reads, even from bytes.
+	raw := cbg.AppendByteString([]byte{0x81}, make([]byte, 1000))
+	obj := &DeferredContainer{Deferred: &cbg.Deferred{Raw: raw}}
+	buf := new(bytes.Buffer)
+	if err := obj.MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+
+	for _, tc := range []struct {
+		opts cbg.DecodeOptions
+		err  error
+	}{
+		{cbg.DecodeOptions{MaxAlloc: int64(len(raw))}, nil},
+		{cbg.DecodeOptions{MaxAlloc: 1000}, cbg.ErrDecodeAllocLimit},
+	} {
+		for _, cr := range []*cbg.CborReader{
+			cbg.NewCborReaderWithOptions(bytes.NewReader(enc), tc.opts),
+			cbg.NewCborReaderBytesWithOptions(enc, tc.opts),
+		} {
+			var out DeferredContainer
+			err := out.UnmarshalCBOR(cr)
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%+v: expected %v, got %v", tc.opts, tc.err, err)
+			}
+		}
+	}
+}
+
+func TestDecodeNonMinimal(t *testing.T) {
+	enc := cbg.MustParseDiagnostic(`{_0 "foo"_1: -5_3, "beep"_0: "bar"_2, "skip"_1: [1_2]}`)
+
+	var out RenamedFields
+	if err := out.UnmarshalCBOR(bytes.NewReader(enc)); !errors.Is(err, cbg.ErrNonCanonical) {
+		t.Fatalf("expected ErrNonCanonical by default, got %v", err)
+	}
+
+	opts := cbg.DecodeOptions{AllowNonMinimal: true}
+	for _, cr := range []*cbg.CborReader{
+		cbg.NewCborReaderWithOptions(bytes.NewReader(enc), opts),
+		cbg.NewCborReaderBytesWithOptions(enc, opts),
+	} {
+		out = RenamedFields{}
+		if err := out.UnmarshalCBOR(cr); err != nil {
+			t.Fatal(err)
+		}
+		if out.Foo != -5 || out.Bar != "bar" {
+			t.Fatalf("expected {-5 bar}, got %+v", out)
+		}
+		if rest := cr.Remaining(); len(rest) > 0 {
+			t.Fatalf("expected all input to be read, %x is left", rest)
+		}
+	}
+}
+
+func TestTypedDecodeErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := (&SimpleTypeTree{}).MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var wrongType *cbg.ErrWrongMajorType
+	err := new(SimpleTypeOne).UnmarshalCBOR(bytes.NewReader(buf.Bytes()))
+	if !errors.As(err, &wrongType) || wrongType.Want != cbg.MajArray || wrongType.Got != cbg.MajMap {
+		t.Fatalf("expected ErrWrongMajorType for a map, got %v", err)
+	}
+
+	buf.Reset()
+	if err := (&LongLog{}).MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+	enc := buf.Bytes()
+	// Replace the empty Heights array header with one that's too long.
+	enc = append(enc[:len(enc)-1], cbg.CborEncodeMajorType(cbg.MajArray, 0xffffffff)...)
+
+	var tooLong *cbg.ErrTooLong
+	err = new(LongLog).UnmarshalCBOR(bytes.NewReader(enc))
+	if !errors.As(err, &tooLong) || tooLong.Limit != cbg.MaxLength || tooLong.Length != 0xffffffff {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+
+	var wrongLength *cbg.ErrWrongLength
+	err = new(SimpleTypeOne).UnmarshalCBOR(bytes.NewReader(cbg.MustParseDiagnostic(`[1, 2]`)))
+	if !errors.As(err, &wrongLength) || wrongLength.Want != 5 || wrongLength.Got != 2 {
+		t.Fatalf("expected ErrWrongLength for the fields, got %v", err)
+	}
+	err = new(FixedArrays).UnmarshalCBOR(bytes.NewReader(cbg.MustParseDiagnostic(`[h'00', h'', []]`)))
+	if !errors.As(err, &wrongLength) || wrongLength.Want != 20 || wrongLength.Got != 1 {
+		t.Fatalf("expected ErrWrongLength for the array, got %v", err)
+	}
+
+	err = new(SimpleTypeOne).UnmarshalCBOR(bytes.NewReader(cbg.MustParseDiagnostic(`["", 0, h'', "1", ""]`)))
+	if !errors.As(err, &wrongType) || wrongType.Want != cbg.MajUnsignedInt || wrongType.Got != cbg.MajTextString {
+		t.Fatalf("expected ErrWrongMajorType for the int64, got %v", err)
+	}
+
+	var overflow *cbg.ErrIntOverflow
+	err = new(SimpleTypeOne).UnmarshalCBOR(bytes.NewReader(cbg.MustParseDiagnostic(`["", 0, h'', -9223372036854775809, ""]`)))
+	if !errors.As(err, &overflow) || overflow.Type != "int64" || !overflow.Negative {
+		t.Fatalf("expected ErrIntOverflow, got %v", err)
+	}
+	if overflow.Error() != "integer -1-9223372036854775808 in input overflows int64" {
+		t.Fatalf("unexpected message: %s", overflow)
+	}
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	var inner SimpleTypeTwo
+	inner.Arrrrrghay[2].Foo = "MARK"
+	buf := new(bytes.Buffer)
+	if err := (&SimpleTypeTwo{Stuff: &inner}).MarshalCBOR(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Make Stuff.Arrrrrghay[2].Foo too long.
+	mark := bytes.Index(buf.Bytes(), []byte("\x64MARK"))
+	header := cbg.CborEncodeMajorType(cbg.MajTextString, 0xffffffff)
+	enc := append(append(append([]byte{}, buf.Bytes()[:mark]...), header...), buf.Bytes()[mark+1:]...)
+
+	_, bytesErr := new(SimpleTypeTwo).UnmarshalCBORBytes(enc)
+	for _, err := range []error{bytesErr, new(SimpleTypeTwo).UnmarshalCBOR(bytes.NewReader(enc))} {
+		var decErr *cbg.DecodeError
+		if !errors.As(err, &decErr) {
+			t.Fatalf("expected a DecodeError, got %v", err)
+		}
+		if decErr.Type != "SimpleTypeTwo" || decErr.Path != "Stuff.Arrrrrghay[2].Foo" {
+			t.Fatalf("expected the error at SimpleTypeTwo.Stuff.Arrrrrghay[2].Foo, got %v", err)
+		}
+		if decErr.Offset != int64(mark+len(header)) {
+			t.Fatalf("expected the error at byte %d, got %d", mark+len(header), decErr.Offset)
+		}
+		var tooLong *cbg.ErrTooLong
+		if !errors.As(err, &tooLong) {
+			t.Fatalf("expected ErrTooLong, got %v", err)
+		}
+	}
+
+	// Unknown fields that are skipped count towards the offset.
+	enc = cbg.MustParseDiagnostic(`{"Zunknown": h'00000000000000000000', "OldStr": 5}`)
+	for _, err := range []error{
+		new(SimpleStructV1).UnmarshalCBOR(cbg.NewCborReaderBytes(enc)),
+		new(SimpleStructV1).UnmarshalCBOR(bytes.NewReader(enc)),
+	} {
+		var decErr *cbg.DecodeError
+		if !errors.As(err, &decErr) || decErr.Path != "OldStr" {
+			t.Fatalf("expected a DecodeError at OldStr, got %v", err)
+		}
+		if decErr.Offset != int64(len(enc)) {
+			t.Fatalf("expected the error at byte %d, got %d", len(enc), decErr.Offset)
+		}
+	}
+
+	// Running out of input before the first byte is still io.EOF.
+	if err := new(SimpleTypeTwo).UnmarshalCBOR(bytes.NewReader(nil)); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
 func TestDeferredContainer(t *testing.T) {
 	zero := &DeferredContainer{}
 	recepticle := &DeferredContainer{}
@@ -108,3 +108,20 @@ type RenamedFields struct {
 type BigField struct {
 	LargeBytes []byte `cborgen:"maxlen=10000000"`
 }
+
+type LongString struct {
+	Text string `cborgen:"maxlen=20000"`
+}
+
+// LongLog can be decoded with more entries than UnmarshalCBOR allows by
+// streaming them with ForEachEntries.
+type LongLog struct {
+	Name    string
+	Entries []*SimpleTypeOne `cborgen:",foreach"`
+	Heights []uint64         `cborgen:",foreach"`
+}
+
+type LongMapLog struct {
+	Name  string
+	Links []cid.Cid `cborgen:"links,foreach"`
+}
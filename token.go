@@ -0,0 +1,256 @@
+package typegen
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// TokenType is the type of a Token.
+type TokenType uint8
+
+const (
+	// TokenUint is an unsigned integer, whose value is in Token.Value.
+	TokenUint TokenType = iota + 1
+	// TokenInt is a negative integer. Its value is -1 - Token.Value.
+	TokenInt
+	// TokenBytes is a byte string, whose contents are in Token.Bytes.
+	TokenBytes
+	// TokenText is a text string, whose UTF-8 contents are in Token.Bytes.
+	TokenText
+	// TokenArrayStart starts an array of Token.Value elements.
+	TokenArrayStart
+	// TokenMapStart starts a map of Token.Value entries, each a key
+	// followed by a value.
+	TokenMapStart
+	// TokenTag is tag number Token.Value, which applies to the next value.
+	TokenTag
+	// TokenSimple is simple value Token.Value, such as false (20), true (21),
+	// null (22) or undefined (23).
+	TokenSimple
+	// TokenFloat is a float of any width, whose value is in Token.Float.
+	TokenFloat
+	// TokenBreak ends an indefinite length item.
+	TokenBreak
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case TokenUint:
+		return "uint"
+	case TokenInt:
+		return "int"
+	case TokenBytes:
+		return "bytes"
+	case TokenText:
+		return "text"
+	case TokenArrayStart:
+		return "array start"
+	case TokenMapStart:
+		return "map start"
+	case TokenTag:
+		return "tag"
+	case TokenSimple:
+		return "simple"
+	case TokenFloat:
+		return "float"
+	case TokenBreak:
+		return "break"
+	default:
+		return fmt.Sprintf("TokenType(%d)", t)
+	}
+}
+
+// Simple values with names in CBOR.
+const (
+	SimpleFalse     = 20
+	SimpleTrue      = 21
+	SimpleNull      = 22
+	SimpleUndefined = 23
+)
+
+// Token is a single item of CBOR read by CborReader.Next: a scalar value, or
+// the start of an array or map, or a tag.
+type Token struct {
+	Type TokenType
+
+	// Value is the value of a Uint, the argument of an Int, the number of a
+	// Tag or Simple, and the number of elements or entries of an ArrayStart
+	// or MapStart.
+	Value uint64
+
+	// Float is the value of a Float.
+	Float float64
+
+	// Bytes is the contents of a Bytes or Text. When reading from a
+	// CborReader created by NewCborReaderBytes it aliases the input.
+	Bytes []byte
+
+	// Indefinite is set on an ArrayStart, MapStart, Bytes or Text of
+	// indefinite length. Its elements, entries or chunks follow until a
+	// Break.
+	Indefinite bool
+}
+
+// Int64 returns the value of a Uint or Int token as an int64, and whether it
+// is one that fits.
+func (t Token) Int64() (int64, bool) {
+	switch {
+	case t.Type == TokenUint && t.Value <= math.MaxInt64:
+		return int64(t.Value), true
+	case t.Type == TokenInt && t.Value <= math.MaxInt64:
+		return -1 - int64(t.Value), true
+	default:
+		return 0, false
+	}
+}
+
+// readTokenHeader reads a header that may be of any value, including floats
+// and indefinite lengths, but that must use the shortest encoding of its
+// argument unless the reader's options allow otherwise.
+func (cr *CborReader) readTokenHeader() (maj byte, low byte, extra uint64, err error) {
+	maj, low, extra, err = readHeaderAnyLength(cr, cr.hbuf[:])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if maj == MajOther {
+		if low == 24 && extra < 32 {
+			return 0, 0, 0, fmt.Errorf("invalid simple value %d in two bytes", extra)
+		}
+	} else if !cr.opts.AllowNonMinimal && !headerIsShortest(low, extra) {
+		return 0, 0, 0, fmt.Errorf("%w (argument %d not in its shortest encoding)", ErrNonCanonical, extra)
+	}
+	return maj, low, extra, nil
+}
+
+// Next reads the next token. Strings are read whole; those of indefinite
+// length are read as a Bytes or Text token with Indefinite set, followed by
+// their chunks and a Break. Headers must use the shortest encoding of their
+// argument, unless the reader's options allow otherwise. Next returns io.EOF
+// at the end of the input, and io.ErrUnexpectedEOF if it ends within a token.
+func (cr *CborReader) Next() (Token, error) {
+	maj, low, extra, err := cr.readTokenHeader()
+	if err != nil {
+		return Token{}, err
+	}
+
+	switch maj {
+	case MajUnsignedInt:
+		return Token{Type: TokenUint, Value: extra}, nil
+	case MajNegativeInt:
+		return Token{Type: TokenInt, Value: extra}, nil
+	case MajByteString, MajTextString:
+		typ := TokenBytes
+		if maj == MajTextString {
+			typ = TokenText
+		}
+		if low == 31 {
+			return Token{Type: typ, Indefinite: true}, nil
+		}
+		if cr.slice == nil && extra > ByteArrayMaxLen {
+			return Token{}, &ErrTooLong{Length: extra, Limit: ByteArrayMaxLen}
+		}
+		b, err := cr.ReadByteSlice(extra)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return Token{}, err
+		}
+		return Token{Type: typ, Value: extra, Bytes: b}, nil
+	case MajArray, MajMap:
+		typ := TokenArrayStart
+		if maj == MajMap {
+			typ = TokenMapStart
+		}
+		return Token{Type: typ, Value: extra, Indefinite: low == 31}, nil
+	case MajTag:
+		return Token{Type: TokenTag, Value: extra}, nil
+	default:
+		switch low {
+		case 25:
+			return Token{Type: TokenFloat, Float: halfToFloat64(uint16(extra))}, nil
+		case 26:
+			return Token{Type: TokenFloat, Float: float64(math.Float32frombits(uint32(extra)))}, nil
+		case 27:
+			return Token{Type: TokenFloat, Float: math.Float64frombits(extra)}, nil
+		case 31:
+			return Token{Type: TokenBreak}, nil
+		default:
+			return Token{Type: TokenSimple, Value: extra}, nil
+		}
+	}
+}
+
+// skipFrame is an item Skip is in the middle of.
+type skipFrame struct {
+	remaining  uint64
+	indefinite bool
+}
+
+// Skip reads past the next value, including everything nested in it,
+// without keeping any of it. It returns io.EOF if the input is empty.
+func (cr *CborReader) Skip() error {
+	stack := []skipFrame{{remaining: 1}}
+	first := true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if !top.indefinite && top.remaining == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		maj, low, extra, err := cr.readTokenHeader()
+		if err == io.EOF && !first {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		first = false
+
+		if maj == MajOther && low == 31 {
+			if !top.indefinite {
+				return fmt.Errorf("unexpected break")
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if !top.indefinite {
+			top.remaining--
+		}
+
+		switch maj {
+		case MajByteString, MajTextString:
+			if low == 31 {
+				stack = append(stack, skipFrame{indefinite: true})
+				continue
+			}
+			n := int(extra)
+			if n < 0 || uint64(n) != extra {
+				return &ErrTooLong{Length: extra, Limit: uint64(^uint(0) >> 1)}
+			}
+			if err := cr.discard(n); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+		case MajArray, MajMap:
+			if low == 31 {
+				stack = append(stack, skipFrame{indefinite: true})
+				continue
+			}
+			if maj == MajMap {
+				if extra > math.MaxUint64/2 {
+					return &ErrTooLong{Length: extra, Limit: math.MaxUint64 / 2}
+				}
+				extra *= 2
+			}
+			stack = append(stack, skipFrame{remaining: extra})
+		case MajTag:
+			stack = append(stack, skipFrame{remaining: 1})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package typegen
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCborReaderNext(t *testing.T) {
+	in := MustParseDiagnostic(`[1, -2, h'01', "a", {_ "k": 1(2)}, 1.5_1, 2.5_2, 3.5, true, null, simple(255), (_ h'02', h'03'), [_ ]]`)
+	expected := []Token{
+		{Type: TokenArrayStart, Value: 13},
+		{Type: TokenUint, Value: 1},
+		{Type: TokenInt, Value: 1},
+		{Type: TokenBytes, Value: 1, Bytes: []byte{1}},
+		{Type: TokenText, Value: 1, Bytes: []byte("a")},
+		{Type: TokenMapStart, Indefinite: true},
+		{Type: TokenText, Value: 1, Bytes: []byte("k")},
+		{Type: TokenTag, Value: 1},
+		{Type: TokenUint, Value: 2},
+		{Type: TokenBreak},
+		{Type: TokenFloat, Float: 1.5},
+		{Type: TokenFloat, Float: 2.5},
+		{Type: TokenFloat, Float: 3.5},
+		{Type: TokenSimple, Value: SimpleTrue},
+		{Type: TokenSimple, Value: SimpleNull},
+		{Type: TokenSimple, Value: 255},
+		{Type: TokenBytes, Indefinite: true},
+		{Type: TokenBytes, Value: 1, Bytes: []byte{2}},
+		{Type: TokenBytes, Value: 1, Bytes: []byte{3}},
+		{Type: TokenBreak},
+		{Type: TokenArrayStart, Indefinite: true},
+		{Type: TokenBreak},
+	}
+
+	for _, cr := range []*CborReader{NewCborReader(bytes.NewReader(in)), NewCborReaderBytes(in)} {
+		for i, want := range expected {
+			tok, err := cr.Next()
+			if err != nil {
+				t.Fatalf("token %d: %s", i, err)
+			}
+			if !reflect.DeepEqual(tok, want) {
+				t.Fatalf("token %d: expected %+v, got %+v", i, want, tok)
+			}
+		}
+		if _, err := cr.Next(); err != io.EOF {
+			t.Fatalf("expected io.EOF, got %v", err)
+		}
+	}
+
+	// Strings alias the input of a reader created from bytes.
+	cr := NewCborReaderBytes(in)
+	for {
+		tok, err := cr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.Type == TokenBytes {
+			tok.Bytes[0] = 0xaa
+			break
+		}
+	}
+	if !bytes.Contains(in, []byte{0x41, 0xaa}) {
+		t.Fatal("expected the token to alias the input")
+	}
+}
+
+func TestCborReaderNextErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in  []byte
+		err error
+	}{
+		{[]byte{0x18, 0x01}, ErrNonCanonical},
+		{[]byte{0x43, 0x01}, io.ErrUnexpectedEOF},
+		{[]byte{0x19, 0x01}, io.ErrUnexpectedEOF},
+		{[]byte{0xf8, 0x01}, nil},
+		{[]byte{0x1f}, nil},
+		{[]byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, nil},
+	} {
+		_, err := NewCborReader(bytes.NewReader(tc.in)).Next()
+		if err == nil || (tc.err != nil && !errors.Is(err, tc.err)) {
+			t.Errorf("%x: expected %v, got %v", tc.in, tc.err, err)
+		}
+	}
+}
+
+func TestCborReaderSkip(t *testing.T) {
+	in := MustParseDiagnostic(`[[1, {"a": [_ h'01', (_ "b", "c")]}], 1(2), {_ 3: [_ [4]]}]`)
+	in = append(in, MustParseDiagnostic(`"after"`)...)
+
+	for _, cr := range []*CborReader{NewCborReader(bytes.NewReader(in)), NewCborReaderBytes(in)} {
+		tok, err := cr.Next()
+		if err != nil || tok.Type != TokenArrayStart {
+			t.Fatalf("expected an array start, got %+v, %v", tok, err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := cr.Skip(); err != nil {
+				t.Fatalf("element %d: %s", i, err)
+			}
+		}
+		tok, err = cr.Next()
+		if err != nil || string(tok.Bytes) != "after" {
+			t.Fatalf("expected the value after the array, got %+v, %v", tok, err)
+		}
+		if err := cr.Skip(); err != io.EOF {
+			t.Fatalf("expected io.EOF, got %v", err)
+		}
+	}
+
+	for _, in := range [][]byte{
+		{0xff},
+		{0x82, 0x01},
+		{0x9f, 0x01},
+		{0x42, 0x01},
+		{0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	} {
+		if err := NewCborReader(bytes.NewReader(in)).Skip(); err == nil {
+			t.Errorf("%x: expected an error", in)
+		}
+	}
+}
+
+func TestTokenInt64(t *testing.T) {
+	for _, tc := range []struct {
+		tok Token
+		val int64
+		ok  bool
+	}{
+		{Token{Type: TokenUint, Value: 5}, 5, true},
+		{Token{Type: TokenInt, Value: 0}, -1, true},
+		{Token{Type: TokenInt, Value: math.MaxInt64}, math.MinInt64, true},
+		{Token{Type: TokenUint, Value: math.MaxInt64 + 1}, 0, false},
+		{Token{Type: TokenInt, Value: math.MaxInt64 + 1}, 0, false},
+		{Token{Type: TokenTag, Value: 1}, 0, false},
+	} {
+		val, ok := tc.tok.Int64()
+		if val != tc.val || ok != tc.ok {
+			t.Errorf("%+v: expected %d, %v, got %d, %v", tc.tok, tc.val, tc.ok, val, ok)
+		}
+	}
+}
@@ -47,6 +47,10 @@ func discard(br io.Reader, n int) error {
 		}
 		_, err := r.Seek(int64(n), io.SeekCurrent)
 		return err
+	case *sliceReader:
+		return r.discard(n)
+	case *CborReader:
+		return r.discard(n)
 	case *bufio.Reader:
 		discarded, err := r.Discard(n)
 		if discarded != 0 && discarded < n && err == io.EOF {
@@ -64,6 +68,14 @@ func discard(br io.Reader, n int) error {
 }
 
 func ScanForLinks(br io.Reader, cb func(cid.Cid)) (err error) {
+	if opts, ok := lenientOptions(br); ok {
+		var buf bytes.Buffer
+		if err := normalizeValue(br, &buf, opts); err != nil {
+			return err
+		}
+		return ScanForLinks(&buf, cb)
+	}
+
 	hasReadOnce := false
 	defer func() {
 		if err == io.EOF && hasReadOnce {
@@ -94,11 +106,11 @@ func ScanForLinks(br io.Reader, cb func(cid.Cid)) (err error) {
 				}
 
 				if maj != MajByteString {
-					return fmt.Errorf("expected cbor type 'byte string' in input")
+					return &ErrWrongMajorType{Want: MajByteString, Got: maj}
 				}
 
 				if extra > maxCidLength {
-					return fmt.Errorf("string in cbor input too long")
+					return &ErrTooLong{Length: extra, Limit: maxCidLength}
 				}
 
 				if _, err := io.ReadAtLeast(br, scratch[:extra], int(extra)); err != nil {
@@ -136,8 +148,6 @@ const (
 	MajOther       = 7
 )
 
-var maxLengthError = fmt.Errorf("length beyond maximum allowed")
-
 type CBORUnmarshaler interface {
 	UnmarshalCBOR(io.Reader) error
 }
@@ -162,12 +172,50 @@ func (d *Deferred) MarshalCBOR(w io.Writer) error {
 	return err
 }
 
+func (d *Deferred) CBORSize() int {
+	if d == nil {
+		return len(CborNull)
+	}
+	return len(d.Raw)
+}
+
+func (d *Deferred) AppendCBOR(b []byte) ([]byte, error) {
+	if d == nil {
+		return append(b, CborNull...), nil
+	}
+	if d.Raw == nil {
+		return nil, errors.New("cannot marshal Deferred with nil value for Raw (will not unmarshal)")
+	}
+	return append(b, d.Raw...), nil
+}
+
+func (d *Deferred) ForEachLink(fn func(cid.Cid) error) error {
+	if d == nil || len(d.Raw) == 0 {
+		return nil
+	}
+	return scanBytes(d.Raw, fn)
+}
+
+func (d *Deferred) ForEachLinkPath(prefix string, fn func(string, cid.Cid) error) error {
+	return d.ForEachLink(func(c cid.Cid) error {
+		return fn(prefix, c)
+	})
+}
+
 func (d *Deferred) UnmarshalCBOR(br io.Reader) (err error) {
 	// Reuse any existing buffers.
 	reusedBuf := d.Raw[:0]
 	d.Raw = nil
 	buf := bytes.NewBuffer(reusedBuf)
 
+	if opts, ok := lenientOptions(br); ok {
+		if err := normalizeValue(br, buf, opts); err != nil {
+			return err
+		}
+		d.Raw = buf.Bytes()
+		return nil
+	}
+
 	// Allocate some scratch space.
 	scratch := make([]byte, maxHeaderSize)
 
@@ -194,16 +242,23 @@ func (d *Deferred) UnmarshalCBOR(br io.Reader) (err error) {
 			return err
 		}
 		hasReadOnce = true
+		n := buf.Len()
 		if err := WriteMajorTypeHeaderBuf(scratch, buf, maj, extra); err != nil {
 			return err
 		}
+		if err := allocate(br, uint64(buf.Len()-n)); err != nil {
+			return err
+		}
 
 		switch maj {
 		case MajUnsignedInt, MajNegativeInt, MajOther:
 			// nothing fancy to do
 		case MajByteString, MajTextString:
 			if extra > ByteArrayMaxLen {
-				return maxLengthError
+				return &ErrTooLong{Length: extra, Limit: ByteArrayMaxLen}
+			}
+			if err := allocate(br, extra); err != nil {
+				return err
 			}
 			// Copy the bytes
 			limitedReader.N = int64(extra)
@@ -217,12 +272,12 @@ func (d *Deferred) UnmarshalCBOR(br io.Reader) (err error) {
 			remaining++
 		case MajArray:
 			if extra > MaxLength {
-				return maxLengthError
+				return &ErrTooLong{Length: extra, Limit: MaxLength}
 			}
 			remaining += extra
 		case MajMap:
 			if extra > MaxLength {
-				return maxLengthError
+				return &ErrTooLong{Length: extra, Limit: MaxLength}
 			}
 			remaining += extra * 2
 		default:
@@ -245,8 +300,10 @@ func readByte(r io.Reader) (byte, error) {
 		return r.ReadByte()
 	case *peeker:
 		return r.ReadByte()
+	case *sliceReader:
+		return r.ReadByte()
 	case *CborReader:
-		return readByte(r.r)
+		return r.ReadByte()
 	case io.ByteReader:
 		return r.ReadByte()
 	}
@@ -282,7 +339,7 @@ func CborReadHeader(br io.Reader) (byte, uint64, error) {
 			return 0, 0, err
 		}
 		if next < 24 {
-			return 0, 0, fmt.Errorf("cbor input was not canonical (lval 24 with value < 24)")
+			return 0, 0, fmt.Errorf("%w (lval 24 with value < 24)", ErrNonCanonical)
 		}
 		return maj, uint64(next), nil
 	case low == 25:
@@ -292,7 +349,7 @@ func CborReadHeader(br io.Reader) (byte, uint64, error) {
 		}
 		val := uint64(binary.BigEndian.Uint16(scratch[:2]))
 		if val <= math.MaxUint8 {
-			return 0, 0, fmt.Errorf("cbor input was not canonical (lval 25 with value <= MaxUint8)")
+			return 0, 0, fmt.Errorf("%w (lval 25 with value <= MaxUint8)", ErrNonCanonical)
 		}
 		return maj, val, nil
 	case low == 26:
@@ -302,7 +359,7 @@ func CborReadHeader(br io.Reader) (byte, uint64, error) {
 		}
 		val := uint64(binary.BigEndian.Uint32(scratch[:4]))
 		if val <= math.MaxUint16 {
-			return 0, 0, fmt.Errorf("cbor input was not canonical (lval 26 with value <= MaxUint16)")
+			return 0, 0, fmt.Errorf("%w (lval 26 with value <= MaxUint16)", ErrNonCanonical)
 		}
 		return maj, val, nil
 	case low == 27:
@@ -312,7 +369,7 @@ func CborReadHeader(br io.Reader) (byte, uint64, error) {
 		}
 		val := binary.BigEndian.Uint64(scratch)
 		if val <= math.MaxUint32 {
-			return 0, 0, fmt.Errorf("cbor input was not canonical (lval 27 with value <= MaxUint32)")
+			return 0, 0, fmt.Errorf("%w (lval 27 with value <= MaxUint32)", ErrNonCanonical)
 		}
 		return maj, val, nil
 	default:
@@ -332,8 +389,10 @@ func readByteBuf(r io.Reader, scratch []byte) (byte, error) {
 		return r.ReadByte()
 	case *peeker:
 		return r.ReadByte()
+	case *sliceReader:
+		return r.ReadByte()
 	case *CborReader:
-		return readByte(r.r)
+		return r.ReadByte()
 	case io.ByteReader:
 		return r.ReadByte()
 	}
@@ -366,7 +425,7 @@ func CborReadHeaderBuf(br io.Reader, scratch []byte) (byte, uint64, error) {
 			return 0, 0, err
 		}
 		if next < 24 {
-			return 0, 0, fmt.Errorf("cbor input was not canonical (lval 24 with value < 24)")
+			return 0, 0, fmt.Errorf("%w (lval 24 with value < 24)", ErrNonCanonical)
 		}
 		return maj, uint64(next), nil
 	case low == 25:
@@ -375,7 +434,7 @@ func CborReadHeaderBuf(br io.Reader, scratch []byte) (byte, uint64, error) {
 		}
 		val := uint64(binary.BigEndian.Uint16(scratch[:2]))
 		if val <= math.MaxUint8 {
-			return 0, 0, fmt.Errorf("cbor input was not canonical (lval 25 with value <= MaxUint8)")
+			return 0, 0, fmt.Errorf("%w (lval 25 with value <= MaxUint8)", ErrNonCanonical)
 		}
 		return maj, val, nil
 	case low == 26:
@@ -384,7 +443,7 @@ func CborReadHeaderBuf(br io.Reader, scratch []byte) (byte, uint64, error) {
 		}
 		val := uint64(binary.BigEndian.Uint32(scratch[:4]))
 		if val <= math.MaxUint16 {
-			return 0, 0, fmt.Errorf("cbor input was not canonical (lval 26 with value <= MaxUint16)")
+			return 0, 0, fmt.Errorf("%w (lval 26 with value <= MaxUint16)", ErrNonCanonical)
 		}
 		return maj, val, nil
 	case low == 27:
@@ -393,7 +452,7 @@ func CborReadHeaderBuf(br io.Reader, scratch []byte) (byte, uint64, error) {
 		}
 		val := binary.BigEndian.Uint64(scratch[:8])
 		if val <= math.MaxUint32 {
-			return 0, 0, fmt.Errorf("cbor input was not canonical (lval 27 with value <= MaxUint32)")
+			return 0, 0, fmt.Errorf("%w (lval 27 with value <= MaxUint32)", ErrNonCanonical)
 		}
 		return maj, val, nil
 	default:
@@ -401,6 +460,72 @@ func CborReadHeaderBuf(br io.Reader, scratch []byte) (byte, uint64, error) {
 	}
 }
 
+// readHeaderAnyLength reads a header like CborReadHeaderBuf, but accepts
+// arguments that are not in their shortest encoding, such as those of floats,
+// and the indefinite length indicator. It also returns the additional
+// information bits of the first byte, which tell how the argument was encoded.
+func readHeaderAnyLength(br io.Reader, scratch []byte) (maj byte, low byte, extra uint64, err error) {
+	first, err := readByteBuf(br, scratch)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	maj = (first & 0xe0) >> 5
+	low = first & 0x1f
+
+	switch {
+	case low < 24:
+		return maj, low, uint64(low), nil
+	case low == 24:
+		next, err := readByteBuf(br, scratch)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return maj, low, uint64(next), nil
+	case low == 25:
+		if _, err := io.ReadFull(br, scratch[:2]); err != nil {
+			return 0, 0, 0, err
+		}
+		return maj, low, uint64(binary.BigEndian.Uint16(scratch[:2])), nil
+	case low == 26:
+		if _, err := io.ReadFull(br, scratch[:4]); err != nil {
+			return 0, 0, 0, err
+		}
+		return maj, low, uint64(binary.BigEndian.Uint32(scratch[:4])), nil
+	case low == 27:
+		if _, err := io.ReadFull(br, scratch[:8]); err != nil {
+			return 0, 0, 0, err
+		}
+		return maj, low, binary.BigEndian.Uint64(scratch[:8]), nil
+	case low == 31 && maj != MajUnsignedInt && maj != MajNegativeInt && maj != MajTag:
+		return maj, low, 0, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("invalid header: (%x)", first)
+	}
+}
+
+// headerIsShortest reports whether a header with additional information low
+// encodes its argument extra in the fewest bytes.
+func headerIsShortest(low byte, extra uint64) bool {
+	switch low {
+	case 24:
+		return extra >= 24
+	case 25:
+		return extra > math.MaxUint8
+	case 26:
+		return extra > math.MaxUint16
+	case 27:
+		return extra > math.MaxUint32
+	default:
+		return true
+	}
+}
+
 func CborWriteHeader(w io.Writer, t byte, l uint64) error {
 	return WriteMajorTypeHeader(w, t, l)
 }
@@ -511,11 +636,11 @@ func ReadTaggedByteArray(br io.Reader, exptag uint64, maxlen uint64) (bs []byte,
 	}()
 
 	if maj != MajTag {
-		return nil, fmt.Errorf("expected cbor type 'tag' in input")
+		return nil, &ErrWrongMajorType{Want: MajTag, Got: maj}
 	}
 
 	if extra != exptag {
-		return nil, fmt.Errorf("expected tag %d", exptag)
+		return nil, &ErrUnexpectedTag{Want: exptag, Got: extra}
 	}
 
 	return ReadByteArray(br, maxlen)
@@ -528,11 +653,14 @@ func ReadByteArray(br io.Reader, maxlen uint64) ([]byte, error) {
 	}
 
 	if maj != MajByteString {
-		return nil, fmt.Errorf("expected cbor type 'byte string' in input")
+		return nil, &ErrWrongMajorType{Want: MajByteString, Got: maj}
 	}
 
 	if extra > maxlen {
-		return nil, fmt.Errorf("string in cbor input too long, maxlen: %d", maxlen)
+		return nil, &ErrTooLong{Length: extra, Limit: maxlen}
+	}
+	if err := allocate(br, extra); err != nil {
+		return nil, err
 	}
 
 	buf := make([]byte, extra)
@@ -569,17 +697,44 @@ var stringBufPool = sync.Pool{
 }
 
 func ReadString(r io.Reader) (string, error) {
+	return ReadStringWithMax(r, MaxLength)
+}
+
+// ReadStringWithMax reads a text string of at most maxlen bytes.
+func ReadStringWithMax(r io.Reader, maxlen uint64) (string, error) {
 	maj, l, err := CborReadHeader(r)
 	if err != nil {
 		return "", err
 	}
 
 	if maj != MajTextString {
-		return "", fmt.Errorf("got tag %d while reading string value (l = %d)", maj, l)
+		return "", &ErrWrongMajorType{Want: MajTextString, Got: maj}
+	}
+
+	if l > maxlen {
+		return "", &ErrTooLong{Length: l, Limit: maxlen}
+	}
+
+	if cr, ok := r.(*CborReader); ok {
+		if err := cr.Allocate(l); err != nil {
+			return "", err
+		}
+		if cr.slice != nil {
+			buf, err := cr.slice.next(l)
+			if err != nil {
+				return "", err
+			}
+			return string(buf), nil
+		}
 	}
 
 	if l > MaxLength {
-		return "", fmt.Errorf("string in input was too long")
+		// Too long for the pooled buffers.
+		buf := make([]byte, l)
+		if _, err := io.ReadAtLeast(r, buf, int(l)); err != nil {
+			return "", err
+		}
+		return string(buf), nil
 	}
 
 	bufp := stringBufPool.Get().(*[]byte)
@@ -605,6 +760,12 @@ func ReadStringBuf(r io.Reader, _ []byte) (string, error) {
 }
 
 func ReadCid(br io.Reader) (cid.Cid, error) {
+	if cr, ok := br.(*CborReader); ok && cr.slice != nil {
+		// The CID copies the bytes it keeps, so there's no need to copy
+		// them out of the input first.
+		return readCidNoCopy(cr)
+	}
+
 	buf, err := ReadTaggedByteArray(br, 42, 512)
 	if err != nil {
 		return cid.Undef, err
@@ -613,6 +774,40 @@ func ReadCid(br io.Reader) (cid.Cid, error) {
 	return bufToCid(buf)
 }
 
+func readCidNoCopy(cr *CborReader) (cid.Cid, error) {
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return cid.Undef, err
+	}
+	if maj != MajTag {
+		return cid.Undef, &ErrWrongMajorType{Want: MajTag, Got: maj}
+	}
+	if extra != 42 {
+		return cid.Undef, &ErrUnexpectedTag{Want: 42, Got: extra}
+	}
+
+	maj, extra, err = cr.ReadHeader()
+	if err != nil {
+		return cid.Undef, err
+	}
+	if maj != MajByteString {
+		return cid.Undef, &ErrWrongMajorType{Want: MajByteString, Got: maj}
+	}
+	if extra > 512 {
+		return cid.Undef, &ErrTooLong{Length: extra, Limit: 512}
+	}
+
+	buf, err := cr.slice.next(extra)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return cid.Undef, err
+	}
+
+	return bufToCid(buf)
+}
+
 func bufToCid(buf []byte) (cid.Cid, error) {
 	if len(buf) == 0 {
 		return cid.Undef, fmt.Errorf("undefined cid")
@@ -688,6 +883,14 @@ func (cb CborBool) MarshalCBOR(w io.Writer) error {
 	return WriteBool(w, bool(cb))
 }
 
+func (cb CborBool) CBORSize() int {
+	return 1
+}
+
+func (cb CborBool) AppendCBOR(b []byte) ([]byte, error) {
+	return AppendBool(b, bool(cb)), nil
+}
+
 func (cb *CborBool) UnmarshalCBOR(r io.Reader) error {
 	t, val, err := CborReadHeader(r)
 	if err != nil {
@@ -695,7 +898,7 @@ func (cb *CborBool) UnmarshalCBOR(r io.Reader) error {
 	}
 
 	if t != MajOther {
-		return fmt.Errorf("booleans should be major type 7")
+		return &ErrWrongMajorType{Want: MajOther, Got: t}
 	}
 
 	switch val {
@@ -725,6 +928,14 @@ func (ci CborInt) MarshalCBOR(w io.Writer) error {
 	return nil
 }
 
+func (ci CborInt) CBORSize() int {
+	return CborIntSize(int64(ci))
+}
+
+func (ci CborInt) AppendCBOR(b []byte) ([]byte, error) {
+	return AppendInt64(b, int64(ci)), nil
+}
+
 func (ci *CborInt) UnmarshalCBOR(r io.Reader) error {
 	maj, extra, err := CborReadHeader(r)
 	if err != nil {
@@ -735,16 +946,16 @@ func (ci *CborInt) UnmarshalCBOR(r io.Reader) error {
 	case MajUnsignedInt:
 		extraI = int64(extra)
 		if extraI < 0 {
-			return fmt.Errorf("int64 positive overflow")
+			return &ErrIntOverflow{Type: "int64", Value: extra}
 		}
 	case MajNegativeInt:
 		extraI = int64(extra)
 		if extraI < 0 {
-			return fmt.Errorf("int64 negative oveflow")
+			return &ErrIntOverflow{Type: "int64", Value: extra, Negative: true}
 		}
 		extraI = -1 - extraI
 	default:
-		return fmt.Errorf("wrong type for int64 field: %d", maj)
+		return &ErrWrongMajorType{Want: MajUnsignedInt, Got: maj}
 	}
 
 	*ci = CborInt(extraI)
@@ -761,6 +972,22 @@ func (ct CborTime) MarshalCBOR(w io.Writer) error {
 	return cbi.MarshalCBOR(w)
 }
 
+func (ct CborTime) CBORSize() int {
+	return CborIntSize(ct.Time().UnixNano())
+}
+
+func (ct CborTime) AppendCBOR(b []byte) ([]byte, error) {
+	return CborInt(ct.Time().UnixNano()).AppendCBOR(b)
+}
+
+func (ct CborTime) ForEachLink(func(cid.Cid) error) error {
+	return nil
+}
+
+func (ct CborTime) ForEachLinkPath(string, func(string, cid.Cid) error) error {
+	return nil
+}
+
 func (ct *CborTime) UnmarshalCBOR(r io.Reader) error {
 	var cbi CborInt
 	if err := cbi.UnmarshalCBOR(r); err != nil {
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -78,7 +79,8 @@ func TestDeferredMaxLengthSingle(t *testing.T) {
 
 	var deferred Deferred
 	err := deferred.UnmarshalCBOR(&header)
-	if err != maxLengthError {
+	var tooLong *ErrTooLong
+	if !errors.As(err, &tooLong) || tooLong.Limit != ByteArrayMaxLen {
 		t.Fatal("deferred: allowed more than the maximum allocation supported")
 	}
 }
@@ -108,6 +110,8 @@ func TestReadEOFSemantics(t *testing.T) {
 			{name: "bufio Reader", reader: bufio.NewReader(bytes.NewReader([]byte{0x01})), shouldFail: false},
 			{name: "bufio Reader with testReader", reader: bufio.NewReader(&testReader1Byte{b: 0x01}), shouldFail: false},
 			{name: "bufio Reader with exhausted testReader", reader: bufio.NewReader(&testReader1Byte{b: 0x01, emptied: true}), shouldFail: true},
+			{name: "CborReader over bytes", reader: NewCborReaderBytes([]byte{0x01}), shouldFail: false},
+			{name: "CborReader over empty bytes", reader: NewCborReaderBytes(nil), shouldFail: true},
 		}
 	}
 
@@ -162,6 +166,7 @@ func TestDiscardReturnsErrUnexpectedEOF(t *testing.T) {
 			{name: "Byte Reader", reader: bytes.NewReader([]byte{0x01})},
 			{name: "bufio Reader", reader: bufio.NewReader(bytes.NewReader([]byte{0x01}))},
 			{name: "bufio Reader with testReader", reader: bufio.NewReader(&testReader1Byte{b: 0x01})},
+			{name: "CborReader over bytes", reader: NewCborReaderBytes([]byte{0x01})},
 		}
 	}
 
@@ -179,6 +184,43 @@ func TestDiscardReturnsErrUnexpectedEOF(t *testing.T) {
 	}
 }
 
+func TestReadByteSlice(t *testing.T) {
+	input := []byte{0x01, 0x02, 0x03, 0x04}
+
+	cr := NewCborReaderBytes(input)
+	b, err := cr.ReadByteSlice(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte{0x01, 0x02}) {
+		t.Fatalf("expected 0102, got %x", b)
+	}
+	if &b[0] != &input[0] {
+		t.Fatal("expected ReadByteSlice to alias the input")
+	}
+	if !bytes.Equal(cr.Remaining(), []byte{0x03, 0x04}) {
+		t.Fatalf("expected 0304 remaining, got %x", cr.Remaining())
+	}
+	if _, err := cr.ReadByteSlice(3); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := cr.ReadByteSlice(1); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	cr = NewCborReader(bytes.NewReader(input))
+	b, err = cr.ReadByteSlice(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte{0x01, 0x02}) || &b[0] == &input[0] {
+		t.Fatalf("expected a copy of 0102, got %x", b)
+	}
+	if cr.Remaining() != nil {
+		t.Fatal("expected no remaining slice for a stream reader")
+	}
+}
+
 type testReader1Byte struct {
 	emptied bool
 	b       byte
@@ -24,7 +24,7 @@ func ValidateCBOR(b []byte) error {
 			// nothing fancy to do
 		case MajByteString, MajTextString:
 			if extra > ByteArrayMaxLen {
-				return maxLengthError
+				return &ErrTooLong{Length: extra, Limit: ByteArrayMaxLen}
 			}
 			if uint64(br.Len()) < extra {
 				return io.ErrUnexpectedEOF
@@ -37,12 +37,12 @@ func ValidateCBOR(b []byte) error {
 			remaining++
 		case MajArray:
 			if extra > MaxLength {
-				return maxLengthError
+				return &ErrTooLong{Length: extra, Limit: MaxLength}
 			}
 			remaining += extra
 		case MajMap:
 			if extra > MaxLength {
-				return maxLengthError
+				return &ErrTooLong{Length: extra, Limit: MaxLength}
 			}
 			remaining += extra * 2
 		default:
@@ -54,3 +54,20 @@ func ValidateCBOR(b []byte) error {
 	}
 	return nil
 }
+
+// ValidateCBORWithOptions is ValidateCBOR for input that may use the headers
+// opts allow, such as indefinite length items. Its limits don't apply.
+func ValidateCBORWithOptions(b []byte, opts DecodeOptions) error {
+	if !opts.AllowIndefinite && !opts.AllowNonMinimal {
+		return ValidateCBOR(b)
+	}
+
+	br := bytes.NewReader(b)
+	if err := normalizeValue(br, new(bytes.Buffer), opts); err != nil {
+		return err
+	}
+	if br.Len() > 0 {
+		return fmt.Errorf("unexpected %d unread bytes", br.Len())
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package typegen
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// Node is a CBOR value of a type not known in advance: a Map, Array, Bytes,
+// Text, Int, BigInt, Float, Bool, Null, Simple, Tag or Link. Trees of them
+// can be read with ReadNode, inspected and changed with type switches, and
+// written with MarshalCBOR.
+type Node interface {
+	CBORMarshaler
+	node()
+}
+
+// Map is a map, whose entries are kept in the order they were read or are
+// to be written in.
+type Map []MapEntry
+
+// MapEntry is an entry of a Map.
+type MapEntry struct {
+	Key   Node
+	Value Node
+}
+
+// Array is an array.
+type Array []Node
+
+// Bytes is a byte string.
+type Bytes []byte
+
+// Text is a text string.
+type Text string
+
+// Int is an integer that fits in an int64.
+type Int int64
+
+// BigInt is an integer that doesn't fit in an int64. Those beyond the range
+// of CBOR integers are written as bignums, with tag 2 or 3. Bignums are read
+// as a Tag, so that they are written back as they were.
+type BigInt struct {
+	*big.Int
+}
+
+// Float is a float. It is always written in 64 bits, as DAG-CBOR requires.
+type Float float64
+
+// Bool is true or false.
+type Bool bool
+
+// Null is null.
+type Null struct{}
+
+// Simple is a simple value other than false, true and null, such as
+// undefined (23).
+type Simple uint8
+
+// Tag is a tag other than 42, and the value it applies to.
+type Tag struct {
+	Number  uint64
+	Content Node
+}
+
+// Link is a CID, tag 42.
+type Link struct {
+	cid.Cid
+}
+
+func (Map) node()    {}
+func (Array) node()  {}
+func (Bytes) node()  {}
+func (Text) node()   {}
+func (Int) node()    {}
+func (BigInt) node() {}
+func (Float) node()  {}
+func (Bool) node()   {}
+func (Null) node()   {}
+func (Simple) node() {}
+func (Tag) node()    {}
+func (Link) node()   {}
+
+var errNilNode = errors.New("cannot marshal nil Node")
+
+func marshalNode(w io.Writer, n Node) error {
+	if n == nil {
+		return errNilNode
+	}
+	return n.MarshalCBOR(w)
+}
+
+func (m Map) MarshalCBOR(w io.Writer) error {
+	cw := NewCborWriter(w)
+	if err := cw.WriteMajorTypeHeader(MajMap, uint64(len(m))); err != nil {
+		return err
+	}
+	for _, e := range m {
+		if err := marshalNode(cw, e.Key); err != nil {
+			return err
+		}
+		if err := marshalNode(cw, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the first entry of m whose key is the text key.
+func (m Map) Get(key string) (Node, bool) {
+	for _, e := range m {
+		if k, ok := e.Key.(Text); ok && string(k) == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (a Array) MarshalCBOR(w io.Writer) error {
+	cw := NewCborWriter(w)
+	if err := cw.WriteMajorTypeHeader(MajArray, uint64(len(a))); err != nil {
+		return err
+	}
+	for _, n := range a {
+		if err := marshalNode(cw, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b Bytes) MarshalCBOR(w io.Writer) error {
+	cw := NewCborWriter(w)
+	if err := cw.WriteMajorTypeHeader(MajByteString, uint64(len(b))); err != nil {
+		return err
+	}
+	_, err := cw.Write(b)
+	return err
+}
+
+func (t Text) MarshalCBOR(w io.Writer) error {
+	cw := NewCborWriter(w)
+	if err := cw.WriteMajorTypeHeader(MajTextString, uint64(len(t))); err != nil {
+		return err
+	}
+	_, err := cw.WriteString(string(t))
+	return err
+}
+
+func (i Int) MarshalCBOR(w io.Writer) error {
+	return CborInt(i).MarshalCBOR(w)
+}
+
+func (b BigInt) MarshalCBOR(w io.Writer) error {
+	if b.Int == nil {
+		return errNilNode
+	}
+
+	// Negative integers are written as -1 - n.
+	maj, n, tag := byte(MajUnsignedInt), b.Int, uint64(2)
+	if b.Sign() < 0 {
+		maj, n, tag = MajNegativeInt, new(big.Int).Not(b.Int), 3
+	}
+	if n.IsUint64() {
+		return WriteMajorTypeHeader(w, maj, n.Uint64())
+	}
+
+	cw := NewCborWriter(w)
+	if err := cw.WriteMajorTypeHeader(MajTag, tag); err != nil {
+		return err
+	}
+	return Bytes(n.Bytes()).MarshalCBOR(cw)
+}
+
+func (f Float) MarshalCBOR(w io.Writer) error {
+	var buf [9]byte
+	buf[0] = MajOther<<5 | 27
+	binary.BigEndian.PutUint64(buf[1:], math.Float64bits(float64(f)))
+	_, err := w.Write(buf[:])
+	return err
+}
+
+func (b Bool) MarshalCBOR(w io.Writer) error {
+	return WriteBool(w, bool(b))
+}
+
+func (Null) MarshalCBOR(w io.Writer) error {
+	_, err := w.Write(CborNull)
+	return err
+}
+
+func (s Simple) MarshalCBOR(w io.Writer) error {
+	if s >= 24 && s < 32 {
+		return fmt.Errorf("invalid simple value %d", s)
+	}
+	return WriteMajorTypeHeader(w, MajOther, uint64(s))
+}
+
+func (t Tag) MarshalCBOR(w io.Writer) error {
+	cw := NewCborWriter(w)
+	if err := cw.WriteMajorTypeHeader(MajTag, t.Number); err != nil {
+		return err
+	}
+	return marshalNode(cw, t.Content)
+}
+
+func (l Link) MarshalCBOR(w io.Writer) error {
+	return WriteCid(w, l.Cid)
+}
+
+// Value holds a Node, so that CBOR of any type can be unmarshaled into it,
+// much as Deferred holds it undecoded.
+type Value struct {
+	Node Node
+}
+
+func (v *Value) MarshalCBOR(w io.Writer) error {
+	if v == nil {
+		_, err := w.Write(CborNull)
+		return err
+	}
+	if v.Node == nil {
+		return fmt.Errorf("cannot marshal Value with nil Node (will not unmarshal)")
+	}
+	return v.Node.MarshalCBOR(w)
+}
+
+func (v *Value) UnmarshalCBOR(r io.Reader) error {
+	n, err := ReadNode(r)
+	if err != nil {
+		return err
+	}
+	v.Node = n
+	return nil
+}
+
+// ReadNode reads a single value from r as a tree of nodes. Strings, arrays
+// and maps of indefinite length are read as definite length ones. Strings
+// may be no longer than ByteArrayMaxLen, and arrays and maps have no more
+// than MaxLength items. Every array, map and tag counts as a level of
+// nesting, which may go no deeper than MaxNesting. If r is a CborReader, its
+// options apply, with its MaxDepth in place of MaxNesting if set.
+func ReadNode(r io.Reader) (Node, error) {
+	cr := NewCborReader(r)
+	tok, err := cr.Next()
+	if err != nil {
+		return nil, err
+	}
+	return readNodeToken(cr, tok)
+}
+
+// enterNode is EnterNested, limited to MaxNesting for readers without a
+// MaxDepth of their own.
+func enterNode(cr *CborReader) error {
+	if cr.opts.MaxDepth <= 0 && cr.depth >= MaxNesting {
+		return ErrDecodeTooDeep
+	}
+	return cr.EnterNested()
+}
+
+// readNestedNode reads a node within another.
+func readNestedNode(cr *CborReader) (Node, error) {
+	tok, err := cr.Next()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tok.Type == TokenBreak {
+		return nil, fmt.Errorf("unexpected break")
+	}
+	return readNodeToken(cr, tok)
+}
+
+// readNodeToken reads the node starting with tok.
+func readNodeToken(cr *CborReader, tok Token) (Node, error) {
+	switch tok.Type {
+	case TokenUint:
+		if tok.Value <= math.MaxInt64 {
+			return Int(tok.Value), nil
+		}
+		return BigInt{new(big.Int).SetUint64(tok.Value)}, nil
+	case TokenInt:
+		if tok.Value <= math.MaxInt64 {
+			return Int(-1 - int64(tok.Value)), nil
+		}
+		return BigInt{new(big.Int).Not(new(big.Int).SetUint64(tok.Value))}, nil
+	case TokenBytes, TokenText:
+		b, err := readNodeString(cr, tok)
+		if err != nil {
+			return nil, err
+		}
+		if tok.Type == TokenText {
+			return Text(b), nil
+		}
+		return Bytes(b), nil
+	case TokenArrayStart, TokenMapStart:
+		if !tok.Indefinite && tok.Value > MaxLength {
+			return nil, &ErrTooLong{Length: tok.Value, Limit: MaxLength}
+		}
+		if err := enterNode(cr); err != nil {
+			return nil, err
+		}
+		defer cr.LeaveNested()
+		if tok.Type == TokenMapStart {
+			return readNodeMap(cr, tok)
+		}
+		return readNodeArray(cr, tok)
+	case TokenTag:
+		if err := enterNode(cr); err != nil {
+			return nil, err
+		}
+		defer cr.LeaveNested()
+		content, err := readNestedNode(cr)
+		if err != nil {
+			return nil, err
+		}
+		if tok.Value != 42 {
+			return Tag{Number: tok.Value, Content: content}, nil
+		}
+		b, ok := content.(Bytes)
+		if !ok {
+			return nil, fmt.Errorf("expected a byte string in tag 42, got %T", content)
+		}
+		c, err := bufToCid(b)
+		if err != nil {
+			return nil, err
+		}
+		return Link{c}, nil
+	case TokenSimple:
+		switch tok.Value {
+		case SimpleFalse, SimpleTrue:
+			return Bool(tok.Value == SimpleTrue), nil
+		case SimpleNull:
+			return Null{}, nil
+		default:
+			return Simple(tok.Value), nil
+		}
+	case TokenFloat:
+		return Float(tok.Float), nil
+	default:
+		return nil, fmt.Errorf("unexpected break")
+	}
+}
+
+// readNodeString returns the contents of the string starting with tok,
+// copied out of the input and with the chunks of one of indefinite length
+// joined.
+func readNodeString(cr *CborReader, tok Token) ([]byte, error) {
+	if !tok.Indefinite {
+		if cr.slice == nil {
+			return tok.Bytes, nil
+		}
+		if err := cr.Allocate(tok.Value); err != nil {
+			return nil, err
+		}
+		return append([]byte{}, tok.Bytes...), nil
+	}
+
+	b := []byte{}
+	for {
+		chunk, err := cr.Next()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunk.Type == TokenBreak {
+			return b, nil
+		}
+		if chunk.Type != tok.Type || chunk.Indefinite {
+			return nil, fmt.Errorf("invalid chunk of type %s in indefinite length %s", chunk.Type, tok.Type)
+		}
+		if chunk.Value > ByteArrayMaxLen-uint64(len(b)) {
+			return nil, &ErrTooLong{Length: uint64(len(b)) + chunk.Value, Limit: ByteArrayMaxLen}
+		}
+		if cr.slice != nil {
+			if err := cr.Allocate(chunk.Value); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, chunk.Bytes...)
+	}
+}
+
+// nextNodeItem reads the token starting the next item of the array or map
+// started by tok, having read i of them already, and reports whether there
+// is one.
+func nextNodeItem(cr *CborReader, tok Token, i uint64) (Token, bool, error) {
+	if !tok.Indefinite {
+		if i >= tok.Value {
+			return Token{}, false, nil
+		}
+	} else if i >= MaxLength {
+		return Token{}, false, &ErrTooLong{Length: i + 1, Limit: MaxLength}
+	}
+
+	next, err := cr.Next()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return Token{}, false, err
+	}
+	if next.Type == TokenBreak {
+		if !tok.Indefinite {
+			return Token{}, false, fmt.Errorf("unexpected break")
+		}
+		return Token{}, false, nil
+	}
+	return next, true, nil
+}
+
+func readNodeArray(cr *CborReader, tok Token) (Node, error) {
+	a := Array{}
+	for i := uint64(0); ; i++ {
+		next, ok, err := nextNodeItem(cr, tok, i)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return a, nil
+		}
+		n, err := readNodeToken(cr, next)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, n)
+	}
+}
+
+func readNodeMap(cr *CborReader, tok Token) (Node, error) {
+	m := Map{}
+	for i := uint64(0); ; i++ {
+		next, ok, err := nextNodeItem(cr, tok, i)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return m, nil
+		}
+		key, err := readNodeToken(cr, next)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readNestedNode(cr)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, MapEntry{Key: key, Value: value})
+	}
+}
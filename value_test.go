@@ -0,0 +1,113 @@
+package typegen
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	for _, in := range []string{
+		`[0, -1, 18446744073709551615, -18446744073709551616, 9223372036854775807, -9223372036854775808]`,
+		`{"a": h'0102', 1: [true, false, null, undefined, simple(255)], [1]: {}}`,
+		`[1.5_3, -0.0_3, 2(h'010000000000000000'), 1(1363896240), 42(cid'bafkqaaa')]`,
+		`{"b": 1, "a": 2}`,
+	} {
+		want := MustParseDiagnostic(in)
+		for _, r := range []io.Reader{bytes.NewReader(want), NewCborReaderBytes(want)} {
+			var v Value
+			if err := v.UnmarshalCBOR(r); err != nil {
+				t.Fatalf("%s: %s", in, err)
+			}
+			var got bytes.Buffer
+			if err := v.MarshalCBOR(&got); err != nil {
+				t.Fatalf("%s: %s", in, err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Fatalf("%s: expected %x, got %x", in, want, got.Bytes())
+			}
+		}
+	}
+}
+
+func TestReadNode(t *testing.T) {
+	c, _ := cid.Parse("bafkqaaa")
+	in := MustParseDiagnostic(`{_ "a": [_ 1, (_ h'01', h'02')], "b": 42(cid'bafkqaaa'), "c": 1.5_1, "d": -18446744073709551616}`)
+	n, err := ReadNode(NewCborReaderBytes(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	min := new(big.Int).Lsh(big.NewInt(-1), 64)
+	want := Map{
+		{Text("a"), Array{Int(1), Bytes{1, 2}}},
+		{Text("b"), Link{c}},
+		{Text("c"), Float(1.5)},
+		{Text("d"), BigInt{min}},
+	}
+	if !reflect.DeepEqual(n, want) {
+		t.Fatalf("expected %#v, got %#v", want, n)
+	}
+	if v, ok := n.(Map).Get("b"); !ok || v != (Link{c}) {
+		t.Fatalf("expected to get the link, got %v", v)
+	}
+
+	// Byte strings don't alias the input.
+	in[len(in)-1] = 0
+	if b := n.(Map)[0].Value.(Array)[1].(Bytes); b[1] != 2 {
+		t.Fatal("expected the bytes to be copied")
+	}
+
+	var buf bytes.Buffer
+	if err := (BigInt{new(big.Int).Lsh(big.NewInt(1), 64)}).MarshalCBOR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := MustParseDiagnostic(`2(h'010000000000000000')`); !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("expected %x, got %x", want, buf.Bytes())
+	}
+
+	for _, in := range [][]byte{
+		{0xff},
+		{0x82, 0x01},
+		{0x9f, 0x01},
+		{0x81, 0xff},
+		{0xd8, 0x2a, 0x01},
+		{0x5f, 0x61, 0x61, 0xff},
+		{0x18, 0x01},
+	} {
+		if _, err := ReadNode(bytes.NewReader(in)); err == nil {
+			t.Errorf("%x: expected an error", in)
+		}
+	}
+	if _, err := ReadNode(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	nested := MustParseDiagnostic(`[[[1]]]`)
+	if _, err := ReadNode(NewCborReaderBytesWithOptions(nested, DecodeOptions{MaxDepth: 2})); !errors.Is(err, ErrDecodeTooDeep) {
+		t.Errorf("expected ErrDecodeTooDeep, got %v", err)
+	}
+
+	// Without a MaxDepth, nesting is limited to MaxNesting.
+	deep := append(bytes.Repeat([]byte{0x81}, MaxNesting-1), 0x80)
+	if _, err := ReadNode(bytes.NewReader(deep)); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range [][]byte{
+		append(bytes.Repeat([]byte{0x81}, 1000000), 0x01),
+		append(bytes.Repeat([]byte{0xc1}, 1000000), 0x01),
+	} {
+		var v Value
+		if err := v.UnmarshalCBOR(bytes.NewReader(in)); !errors.Is(err, ErrDecodeTooDeep) {
+			t.Errorf("expected ErrDecodeTooDeep, got %v", err)
+		}
+	}
+
+	if err := (Array{nil}).MarshalCBOR(&buf); err == nil {
+		t.Error("expected an error marshaling a nil node")
+	}
+}
@@ -3,6 +3,8 @@ package typegen
 import (
 	"bytes"
 	"go/format"
+	"io"
+	"io/ioutil"
 	"os"
 
 	"golang.org/x/xerrors"
@@ -14,79 +16,131 @@ import (
 // The MarshalCBOR and UnmarshalCBOR implementations will marshal/unmarshal each type's fields as a
 // fixed-length CBOR array of field values.
 func WriteTupleEncodersToFile(fname, pkg string, types ...interface{}) error {
-	buf := new(bytes.Buffer)
+	return GenOptions{}.WriteTupleEncodersToFile(fname, pkg, types...)
+}
 
-	typeInfos := make([]*GenTypeInfo, len(types))
-	for i, t := range types {
-		gti, err := ParseTypeInfo(t)
-		if err != nil {
-			return xerrors.Errorf("failed to parse type info: %w", err)
-		}
-		typeInfos[i] = gti
+// WriteTupleEncodersToFile is like the package level WriteTupleEncodersToFile, but generates code
+// according to the options.
+func (g GenOptions) WriteTupleEncodersToFile(fname, pkg string, types ...interface{}) error {
+	data, err := g.GenerateTupleEncoders(pkg, types...)
+	if err != nil {
+		return err
 	}
 
-	if err := PrintHeaderAndUtilityMethods(buf, pkg, typeInfos); err != nil {
-		return xerrors.Errorf("failed to write header: %w", err)
-	}
+	return writeGeneratedFile(fname, data)
+}
 
-	for _, t := range typeInfos {
-		if err := GenTupleEncodersForType(t, buf); err != nil {
-			return xerrors.Errorf("failed to generate encoders: %w", err)
-		}
-	}
+// WriteMapFileEncodersToFile generates map backed MarshalCBOR and UnmarshalCBOR implementations for
+// the given types in the specified file, with the specified package name.
+//
+// The MarshalCBOR and UnmarshalCBOR implementations will marshal/unmarshal each type's fields as a
+// map of field names to field values.
+func WriteMapEncodersToFile(fname, pkg string, types ...interface{}) error {
+	return GenOptions{}.WriteMapEncodersToFile(fname, pkg, types...)
+}
 
-	data, err := format.Source(buf.Bytes())
+// WriteMapEncodersToFile is like the package level WriteMapEncodersToFile, but generates code
+// according to the options.
+func (g GenOptions) WriteMapEncodersToFile(fname, pkg string, types ...interface{}) error {
+	data, err := g.GenerateMapEncoders(pkg, types...)
 	if err != nil {
 		return err
 	}
 
-	fi, err := os.Create(fname)
+	return writeGeneratedFile(fname, data)
+}
+
+// CheckTupleEncodersFile renders the same code as WriteTupleEncodersToFile, but instead of writing it
+// compares it against the contents of fname. It returns an error containing a diff if the file is
+// missing or out of date, and never modifies the file.
+func CheckTupleEncodersFile(fname, pkg string, types ...interface{}) error {
+	return GenOptions{}.CheckTupleEncodersFile(fname, pkg, types...)
+}
+
+// CheckTupleEncodersFile is like the package level CheckTupleEncodersFile, but generates code
+// according to the options.
+func (g GenOptions) CheckTupleEncodersFile(fname, pkg string, types ...interface{}) error {
+	data, err := g.GenerateTupleEncoders(pkg, types...)
 	if err != nil {
-		return xerrors.Errorf("failed to open file: %w", err)
+		return err
 	}
 
-	_, err = fi.Write(data)
+	return checkGeneratedFile(fname, data)
+}
+
+// CheckMapEncodersFile renders the same code as WriteMapEncodersToFile, but instead of writing it
+// compares it against the contents of fname. It returns an error containing a diff if the file is
+// missing or out of date, and never modifies the file.
+func CheckMapEncodersFile(fname, pkg string, types ...interface{}) error {
+	return GenOptions{}.CheckMapEncodersFile(fname, pkg, types...)
+}
+
+// CheckMapEncodersFile is like the package level CheckMapEncodersFile, but generates code
+// according to the options.
+func (g GenOptions) CheckMapEncodersFile(fname, pkg string, types ...interface{}) error {
+	data, err := g.GenerateMapEncoders(pkg, types...)
 	if err != nil {
-		_ = fi.Close()
 		return err
 	}
-	_ = fi.Close()
 
-	return nil
+	return checkGeneratedFile(fname, data)
 }
 
-// WriteMapFileEncodersToFile generates map backed MarshalCBOR and UnmarshalCBOR implementations for
-// the given types in the specified file, with the specified package name.
-//
-// The MarshalCBOR and UnmarshalCBOR implementations will marshal/unmarshal each type's fields as a
-// map of field names to field values.
-func WriteMapEncodersToFile(fname, pkg string, types ...interface{}) error {
+// GenerateTupleEncoders returns the formatted source that WriteTupleEncodersToFile would write.
+func GenerateTupleEncoders(pkg string, types ...interface{}) ([]byte, error) {
+	return GenOptions{}.GenerateTupleEncoders(pkg, types...)
+}
+
+// GenerateTupleEncoders returns the formatted source that WriteTupleEncodersToFile would write.
+func (g GenOptions) GenerateTupleEncoders(pkg string, types ...interface{}) ([]byte, error) {
+	return g.generateFile(pkg, g.GenTupleEncodersForType, types)
+}
+
+// GenerateMapEncoders returns the formatted source that WriteMapEncodersToFile would write.
+func GenerateMapEncoders(pkg string, types ...interface{}) ([]byte, error) {
+	return GenOptions{}.GenerateMapEncoders(pkg, types...)
+}
+
+// GenerateMapEncoders returns the formatted source that WriteMapEncodersToFile would write.
+func (g GenOptions) GenerateMapEncoders(pkg string, types ...interface{}) ([]byte, error) {
+	return g.generateFile(pkg, g.GenMapEncodersForType, types)
+}
+
+func (g GenOptions) generateFile(pkg string, genfn func(*GenTypeInfo, io.Writer) error, types []interface{}) ([]byte, error) {
 	buf := new(bytes.Buffer)
 
-	typeInfos := make([]*GenTypeInfo, len(types))
-	for i, t := range types {
-		gti, err := ParseTypeInfo(t)
-		if err != nil {
-			return xerrors.Errorf("failed to parse type info: %w", err)
+	// Collect every problem with the given types before generating
+	// anything, rather than stopping at the first.
+	var errs GenErrors
+	typeInfos := make([]*GenTypeInfo, 0, len(types))
+	for _, t := range types {
+		gti, parseErrs := parseTypeInfo(t)
+		errs = append(errs, parseErrs...)
+		if gti != nil {
+			typeInfos = append(typeInfos, gti)
 		}
-		typeInfos[i] = gti
 	}
 
-	if err := PrintHeaderAndUtilityMethods(buf, pkg, typeInfos); err != nil {
-		return xerrors.Errorf("failed to write header: %w", err)
+	errs = append(errs, checkImports(g.ExtraImports)...)
+	errs = append(errs, g.checkTypeInfos(typeInfos)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if err := g.PrintHeaderAndUtilityMethods(buf, pkg, typeInfos); err != nil {
+		return nil, xerrors.Errorf("failed to write header: %w", err)
 	}
 
 	for _, t := range typeInfos {
-		if err := GenMapEncodersForType(t, buf); err != nil {
-			return xerrors.Errorf("failed to generate encoders: %w", err)
+		if err := genfn(t, buf); err != nil {
+			return nil, xerrors.Errorf("failed to generate encoders: %w", err)
 		}
 	}
 
-	data, err := format.Source(buf.Bytes())
-	if err != nil {
-		return err
-	}
+	return format.Source(buf.Bytes())
+}
 
+func writeGeneratedFile(fname string, data []byte) error {
 	fi, err := os.Create(fname)
 	if err != nil {
 		return xerrors.Errorf("failed to open file: %w", err)
@@ -101,3 +155,16 @@ func WriteMapEncodersToFile(fname, pkg string, types ...interface{}) error {
 
 	return nil
 }
+
+func checkGeneratedFile(fname string, data []byte) error {
+	existing, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return xerrors.Errorf("failed to read file: %w", err)
+	}
+
+	if bytes.Equal(existing, data) {
+		return nil
+	}
+
+	return xerrors.Errorf("%s is out of date:\n%s", fname, lineDiff(fname, existing, data))
+}
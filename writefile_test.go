@@ -0,0 +1,100 @@
+package typegen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type checkedType struct {
+	Foo string
+	Bar uint64
+}
+
+func TestCheckEncodersFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cbor-gen-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "cbor_gen.go")
+	if err := CheckTupleEncodersFile(fname, "typegen", checkedType{}); err == nil {
+		t.Fatal("expected an error checking a missing file")
+	}
+
+	if err := WriteTupleEncodersToFile(fname, "typegen", checkedType{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckTupleEncodersFile(fname, "typegen", checkedType{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Generating map encoders for the same type gives different code.
+	err = CheckMapEncodersFile(fname, "typegen", checkedType{})
+	if err == nil {
+		t.Fatal("expected an error checking an out of date file")
+	}
+	if !strings.Contains(err.Error(), "\n-") || !strings.Contains(err.Error(), "\n+") {
+		t.Fatalf("expected a diff in the error, got: %s", err)
+	}
+
+	// The check must not touch the file.
+	if err := CheckTupleEncodersFile(fname, "typegen", checkedType{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	var old, new []string
+	for i := 1; i <= 20; i++ {
+		old = append(old, fmt.Sprintf("l%d", i))
+	}
+	new = append(new, old...)
+	new[2] = "X"
+	new[17] = "Y"
+
+	expected := `--- x (on disk)
++++ x (generated)
+@@ -1,6 +1,6 @@
+ l1
+ l2
+-l3
++X
+ l4
+ l5
+ l6
+@@ -15,6 +15,6 @@
+ l15
+ l16
+ l17
+-l18
++Y
+ l19
+ l20
+`
+	d := lineDiff("x", []byte(strings.Join(old, "\n")), []byte(strings.Join(new, "\n")))
+	if d != expected {
+		t.Fatalf("unexpected diff:\n%s", d)
+	}
+}
+
+func TestGenerateReportsConflictingImports(t *testing.T) {
+	g := GenOptions{
+		ExtraImports: []Import{
+			{Name: "fmt", PkgPath: "example.com/fmt"},
+			{Name: "cid", PkgPath: "example.com/cid"},
+		},
+	}
+	_, err := g.GenerateTupleEncoders("typegen", checkedType{})
+	errs, ok := err.(GenErrors)
+	if !ok {
+		t.Fatalf("expected GenErrors, got %T: %v", err, err)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "example.com/fmt") || !strings.Contains(errs[1].Error(), "example.com/cid") {
+		t.Fatalf("expected an error for each conflicting import, got: %s", err)
+	}
+}